@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/jheader/golang_blog/config"
+	"github.com/jheader/golang_blog/mail"
+	"github.com/jheader/golang_blog/repository"
 	"github.com/jheader/golang_blog/routes"
+	"github.com/jheader/golang_blog/search"
+	"github.com/jheader/golang_blog/service"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
@@ -20,8 +27,37 @@ func main() {
 	// 初始化数据库
 	config.InitDB()
 
+	repos := repository.NewGormRepositories(config.DB)
+
+	// BOOTSTRAP_ADMINS 中的用户在启动时被设为管理员，之后通过 /admin/users/:id/role 管理角色
+	service.BootstrapAdmins(repos.Users, strings.Split(viper.GetString("BOOTSTRAP_ADMINS"), ","))
+
+	// 定时发布：每个实例都会启动，发布操作是带条件的单条 UPDATE，多副本部署也是安全的
+	interval := time.Duration(viper.GetInt("PUBLISH_SCHEDULER_INTERVAL_SECONDS")) * time.Second
+	service.NewPublishScheduler(repos.Posts, interval).Start(context.Background())
+
+	// 全文搜索：MySQL 使用 FULLTEXT 索引，其他数据库使用内存索引（启动时从数据库加载）
+	searcher, err := search.New(config.DB, viper.GetString("SEARCH_ENGINE"))
+	if err != nil {
+		log.Fatal("Failed to initialize search:", err)
+	}
+
+	// 邮件：MAIL_DRIVER 为 smtp、file 或 log
+	mailer, err := mail.New(mail.Config{
+		Driver:       viper.GetString("MAIL_DRIVER"),
+		From:         viper.GetString("MAIL_FROM"),
+		File:         viper.GetString("MAIL_FILE"),
+		SMTPHost:     viper.GetString("SMTP_HOST"),
+		SMTPPort:     viper.GetInt("SMTP_PORT"),
+		SMTPUsername: viper.GetString("SMTP_USERNAME"),
+		SMTPPassword: viper.GetString("SMTP_PASSWORD"),
+	})
+	if err != nil {
+		log.Fatal("Failed to initialize mailer:", err)
+	}
+
 	// 设置路由
-	r := routes.SetupRoutes()
+	r := routes.SetupRoutes(repos, searcher, mailer)
 
 	port := viper.GetString("PORT")
 	if port == "" {
@@ -0,0 +1,278 @@
+// mockoidc 本地开发和联调用的 OpenID Connect 身份提供方，不需要登录页面：
+// 授权请求直接通过并以配置的用户身份跳转回 redirect_uri。支持发现文档、JWKS、授权码模式和 PKCE（S256）。
+//
+//	go run ./cmd/mockoidc -addr :9999 -client-id blog -client-secret secret
+//
+// 博客服务的配置：OIDC_PROVIDERS=mock、OIDC_MOCK_ISSUER=http://localhost:9999、
+// OIDC_MOCK_CLIENT_ID=blog、OIDC_MOCK_CLIENT_SECRET=secret。
+// 在授权地址后追加 sub、email、email_verified、preferred_username、name 参数可以换一个用户登录。
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// codeTTL 授权码的有效期
+const codeTTL = time.Minute
+
+const keyID = "mock-key"
+
+type user struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Name              string
+}
+
+// authCode 授权时记录的请求参数，换取令牌时校验
+type authCode struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+	user        user
+	expiresAt   time.Time
+}
+
+type server struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	defaultUser  user
+	key          *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]authCode
+}
+
+func main() {
+
+	addr := flag.String("addr", ":9999", "listen address")
+	issuer := flag.String("issuer", "", "issuer URL, defaults to http://localhost<addr>")
+	clientID := flag.String("client-id", "blog", "accepted client_id")
+	clientSecret := flag.String("client-secret", "secret", "accepted client_secret")
+	sub := flag.String("sub", "mock-user-1", "subject of the default user")
+	email := flag.String("email", "mock@example.com", "email of the default user")
+	emailVerified := flag.Bool("email-verified", true, "whether the default user's email is verified")
+	username := flag.String("username", "mockuser", "preferred_username of the default user")
+	name := flag.String("name", "Mock User", "name of the default user")
+	flag.Parse()
+
+	if *issuer == "" {
+		*issuer = "http://localhost" + *addr
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal("Failed to generate signing key:", err)
+	}
+	s := &server{
+		issuer:       *issuer,
+		clientID:     *clientID,
+		clientSecret: *clientSecret,
+		defaultUser: user{
+			Subject:           *sub,
+			Email:             *email,
+			EmailVerified:     *emailVerified,
+			PreferredUsername: *username,
+			Name:              *name,
+		},
+		key:   key,
+		codes: make(map[string]authCode),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /jwks", s.jwks)
+	mux.HandleFunc("GET /authorize", s.authorize)
+	mux.HandleFunc("POST /token", s.token)
+
+	log.Printf("mock OIDC provider listening on %s, issuer %s", *addr, *issuer)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func (s *server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                s.issuer,
+		"authorization_endpoint":                s.issuer + "/authorize",
+		"token_endpoint":                        s.issuer + "/token",
+		"jwks_uri":                              s.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"scopes_supported":                      []string{"openid", "email", "profile"},
+	})
+}
+
+func (s *server) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize 不显示登录页面，直接以默认用户（或查询参数中指定的用户）签发授权码并跳转回去
+func (s *server) authorize(w http.ResponseWriter, r *http.Request) {
+
+	q := r.URL.Query()
+	if q.Get("client_id") != s.clientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if q.Get("response_type") != "code" {
+		redirectError(w, r, redirectURI, q.Get("state"), "unsupported_response_type")
+		return
+	}
+	if q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		redirectError(w, r, redirectURI, q.Get("state"), "invalid_request")
+		return
+	}
+
+	u := s.defaultUser
+	if v := q.Get("sub"); v != "" {
+		u.Subject = v
+	}
+	if v, ok := q["email"]; ok {
+		u.Email = v[0]
+	}
+	if v := q.Get("email_verified"); v != "" {
+		u.EmailVerified, _ = strconv.ParseBool(v)
+	}
+	if v, ok := q["preferred_username"]; ok {
+		u.PreferredUsername = v[0]
+	}
+	if v, ok := q["name"]; ok {
+		u.Name = v[0]
+	}
+
+	code := rand.Text()
+	s.mu.Lock()
+	s.codes[code] = authCode{
+		clientID:    s.clientID,
+		redirectURI: redirectURI.String(),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		user:        u,
+		expiresAt:   time.Now().Add(codeTTL),
+	}
+	s.mu.Unlock()
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// token 用授权码换取 ID Token，校验客户端凭据、redirect_uri 和 PKCE verifier，授权码只能使用一次
+func (s *server) token(w http.ResponseWriter, r *http.Request) {
+
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != s.clientID || clientSecret != s.clientSecret {
+		tokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	s.mu.Lock()
+	code, ok := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mu.Unlock()
+	if !ok || time.Now().After(code.expiresAt) || code.redirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != code.challenge {
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                s.issuer,
+		"sub":                code.user.Subject,
+		"aud":                code.clientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Hour).Unix(),
+		"email":              code.user.Email,
+		"email_verified":     code.user.EmailVerified,
+		"preferred_username": code.user.PreferredUsername,
+		"name":               code.user.Name,
+	}
+	if code.nonce != "" {
+		claims["nonce"] = code.nonce
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = keyID
+	signed, err := idToken.SignedString(s.key)
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": rand.Text(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+func redirectError(w http.ResponseWriter, r *http.Request, redirectURI *url.URL, state, code string) {
+	params := redirectURI.Query()
+	params.Set("error", code)
+	params.Set("state", state)
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func tokenError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("write response:", err)
+	}
+}
@@ -3,47 +3,132 @@ package config
 import (
 	"fmt"
 	"log"
+	"strings"
 
+	"github.com/glebarez/sqlite"
 	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/repository"
 	"github.com/spf13/viper"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
 
+// 支持的数据库驱动，通过 DB_DRIVER 选择
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 func InitDB() {
 
-	var err error
+	driver := strings.ToLower(viper.GetString("DB_DRIVER"))
+	db, err := OpenDB(driver, BuildDSN(driver))
+	if err != nil {
 
-	dbHost := viper.Get("DB_HOST")
-	dbPort := viper.Get("DB_PORT")
-	dbUser := viper.Get("DB_USER")
-	dbPassword := viper.Get("DB_PASSWORD")
-	dbName := viper.Get("DB_NAME")
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		dbUser, dbPassword, dbHost, dbPort, dbName)
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
+		log.Fatalf("Failed to connect to %s database: %v", driver, err)
+	}
+
+	if err := Migrate(db); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+
+	DB = db
+	log.Printf("%s database connected and migrated successfully\n", driver)
+}
+
+// OpenDB 按驱动名打开数据库连接
+func OpenDB(driver, dsn string) (*gorm.DB, error) {
+
+	var dialector gorm.Dialector
+	switch driver {
+	case DriverMySQL, "":
+		dialector = mysql.Open(dsn)
+	case DriverPostgres, "postgresql", "pg":
+		dialector = postgres.Open(dsn)
+	case DriverSQLite, "sqlite3":
+		dialector = sqlite.Open(dsn)
+	default:
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q (mysql, postgres, sqlite)", driver)
+	}
+
+	return gorm.Open(dialector, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
+		// 把各驱动的唯一键冲突等错误统一转换成 gorm.ErrDuplicatedKey
+		TranslateError: true,
 	})
+}
 
-	if err != nil {
+// BuildDSN 根据驱动拼接连接串，设置了 DB_DSN 时直接使用
+func BuildDSN(driver string) string {
+
+	if dsn := viper.GetString("DB_DSN"); dsn != "" {
+		return dsn
+	}
 
-		log.Fatal("Failed to connect to MySQL database:", err)
+	dbHost := viper.GetString("DB_HOST")
+	dbPort := viper.GetString("DB_PORT")
+	dbUser := viper.GetString("DB_USER")
+	dbPassword := viper.GetString("DB_PASSWORD")
+	dbName := viper.GetString("DB_NAME")
+
+	switch driver {
+	case DriverPostgres, "postgresql", "pg":
+		if dbPort == "" || dbPort == "3306" {
+			dbPort = "5432"
+		}
+		return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s TimeZone=Local",
+			dbHost, dbPort, dbUser, dbPassword, dbName, viper.GetString("DB_SSLMODE"))
+	case DriverSQLite, "sqlite3":
+		// DB_NAME 即数据库文件路径；开启外键约束并设置忙等待，避免并发写入时报 database is locked
+		path := dbName
+		if !strings.HasSuffix(path, ".db") && path != ":memory:" {
+			path += ".db"
+		}
+		return path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	default:
+		return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+			dbUser, dbPassword, dbHost, dbPort, dbName)
 	}
+}
+
+// Migrate 自动建表，所有驱动共用同一份模型定义
+func Migrate(db *gorm.DB) error {
 
-	err = DB.AutoMigrate(
+	err := db.AutoMigrate(
 		&model.Comment{},
 		&model.Post{},
+		&model.PostRevision{},
+		&model.PostSlug{},
+		&model.Tag{},
+		&model.Category{},
 		&model.User{},
+		&model.RefreshToken{},
+		&model.RevokedToken{},
+		&model.ActionToken{},
+		&model.RecoveryCode{},
+		&model.UserIdentity{},
 	)
-
 	if err != nil {
-		log.Fatal("Failed to migrate database:", err)
+		return err
+	}
+	if err := repository.BackfillPostSlugs(db); err != nil {
+		return err
 	}
 
-	log.Println("MySQL database connected and migrated successfully")
+	// 大小写不同的用户名、邮箱在规范列上冲突，不中断启动，逐条报告后由管理员处理
+	collisions, err := repository.BackfillIdentities(db)
+	if err != nil {
+		return err
+	}
+	for _, c := range collisions {
+		log.Printf("警告：用户名/邮箱冲突，%s\n", c)
+	}
+	return nil
 }
 
 func InitViper() {
@@ -63,10 +148,58 @@ func InitViper() {
 	// 5. 重要：让 Viper 自动读取环境变量（优先级高于配置文件）
 	viper.AutomaticEnv()
 
+	// 数据库驱动：mysql（默认）、postgres、sqlite（纯Go实现，DB_NAME 为文件路径）
+	viper.SetDefault("DB_DRIVER", DriverMySQL)
+	viper.SetDefault("DB_SSLMODE", "disable")
 	viper.SetDefault("DB_HOST", "localhost")
 	viper.SetDefault("DB_PORT", "3306")
 	viper.SetDefault("DB_USER", "root")
 	viper.SetDefault("DB_PASSWORD", "123456") // 开发环境默认密码，生产环境通过环境变量覆盖
 	viper.SetDefault("DB_NAME", "golang_blog")
 
+	// 登录失败锁定：连续失败次数上限与锁定时长（分钟）
+	viper.SetDefault("LOGIN_MAX_ATTEMPTS", 5)
+	viper.SetDefault("LOGIN_LOCK_MINUTES", 15)
+
+	// 令牌有效期：访问令牌（分钟）、刷新令牌（小时）
+	viper.SetDefault("ACCESS_TOKEN_TTL_MINUTES", 120)
+	viper.SetDefault("REFRESH_TOKEN_TTL_HOURS", 720)
+
+	// 启动时设为管理员的用户名，逗号分隔，用于创建第一个管理员
+	viper.SetDefault("BOOTSTRAP_ADMINS", "")
+
+	// 全文搜索引擎：auto（MySQL 使用 FULLTEXT，其他使用内存索引）、fulltext、memory
+	viper.SetDefault("SEARCH_ENGINE", "auto")
+
+	// 定时发布调度器的检查间隔（秒）
+	viper.SetDefault("PUBLISH_SCHEDULER_INTERVAL_SECONDS", 30)
+
+	// 邮件：MAIL_DRIVER 为 smtp、file（写入 MAIL_FILE）或 log（写入日志，默认）
+	viper.SetDefault("MAIL_DRIVER", "log")
+	viper.SetDefault("MAIL_FROM", "no-reply@localhost")
+	viper.SetDefault("MAIL_FILE", "mails.log")
+	viper.SetDefault("SMTP_HOST", "")
+	viper.SetDefault("SMTP_PORT", 587)
+	viper.SetDefault("SMTP_USERNAME", "")
+	viper.SetDefault("SMTP_PASSWORD", "")
+
+	// 邮件中链接的前缀（前端地址），令牌以 ?token= 附在后面
+	viper.SetDefault("APP_BASE_URL", "http://localhost:8080")
+	// 邮箱验证令牌有效期（小时）、重置密码令牌有效期（分钟）
+	viper.SetDefault("EMAIL_VERIFY_TTL_HOURS", 48)
+	viper.SetDefault("PASSWORD_RESET_TTL_MINUTES", 30)
+	// 为 true 时邮箱未验证的用户不能发表文章和评论
+	viper.SetDefault("REQUIRE_VERIFIED_EMAIL", false)
+
+	// 两步验证：验证器应用中显示的发行方，密码校验通过后提交验证码的时限（分钟）
+	viper.SetDefault("MFA_ISSUER", "golang_blog")
+	viper.SetDefault("MFA_PENDING_TTL_MINUTES", 5)
+
+	// OpenID Connect 登录：OIDC_PROVIDERS 为逗号分隔的提供方名称，每个提供方配置
+	// OIDC_<NAME>_ISSUER、OIDC_<NAME>_CLIENT_ID、OIDC_<NAME>_CLIENT_SECRET，可选 _REDIRECT_URL、_SCOPES
+	viper.SetDefault("OIDC_PROVIDERS", "")
+	// 未配置 _REDIRECT_URL 时，回调地址为 OIDC_REDIRECT_BASE_URL + /api/v1/auth/oidc/<name>/callback
+	viper.SetDefault("OIDC_REDIRECT_BASE_URL", "http://localhost:8080")
+	viper.SetDefault("OIDC_STATE_TTL_MINUTES", 10)
+
 }
@@ -0,0 +1,222 @@
+package config_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jheader/golang_blog/config"
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/repository"
+	"github.com/jheader/golang_blog/search"
+	"github.com/jheader/golang_blog/service"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openSQLite 在临时目录中创建 SQLite 数据库并建表，连接串与 DB_DRIVER=sqlite 时相同
+func openSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	viper.Set("DB_NAME", filepath.Join(t.TempDir(), "blog"))
+	t.Cleanup(func() { viper.Set("DB_NAME", nil) })
+	db, err := config.OpenDB(config.DriverSQLite, config.BuildDSN(config.DriverSQLite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = db.Session(&gorm.Session{Logger: logger.Discard})
+	if err := config.Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	// 重复迁移不报错，与每次启动时的行为一致
+	if err := config.Migrate(db); err != nil {
+		t.Fatalf("migrate again: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func createUser(t *testing.T, repos *repository.Repositories, username string) *model.User {
+	t.Helper()
+
+	u := &model.User{Username: username, Email: username + "@example.com", Password: "secret123"}
+	if err := repos.Users.Create(u); err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return u
+}
+
+func TestSQLiteUsersAndLoginFailures(t *testing.T) {
+
+	repos := repository.NewGormRepositories(openSQLite(t))
+	alice := createUser(t, repos, "alice")
+
+	// 用户名和邮箱按规范形式去重
+	dup := &model.User{Username: "ALICE", Email: "other@example.com", Password: "secret123"}
+	if err := repos.Users.Create(dup); err == nil {
+		t.Fatal("expected duplicate username error")
+	}
+	found, err := repos.Users.FindByEmail("Alice@Example.com")
+	if err != nil || found.ID != alice.ID || !found.CheckPassword("secret123") {
+		t.Fatalf("find by email: %+v, %v", found, err)
+	}
+
+	// 失败次数在 SQL 中原子累加，达到上限时锁定并清零
+	for i := 1; i < 3; i++ {
+		lockedUntil, err := repos.Users.RegisterLoginFailure(alice.ID, 3, 15*time.Minute)
+		if err != nil || lockedUntil != nil {
+			t.Fatalf("failure %d: locked_until %v, %v", i, lockedUntil, err)
+		}
+	}
+	lockedUntil, err := repos.Users.RegisterLoginFailure(alice.ID, 3, 15*time.Minute)
+	if err != nil || lockedUntil == nil || time.Until(*lockedUntil) < 14*time.Minute {
+		t.Fatalf("third failure should lock the account: %v, %v", lockedUntil, err)
+	}
+	u, err := repos.Users.FindByID(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !u.IsLocked(time.Now()) || u.FailedLoginAttempts != 0 {
+		t.Fatalf("expected locked account with reset counter, got %v / %d", u.LockedUntil, u.FailedLoginAttempts)
+	}
+}
+
+func TestSQLitePostsRevisionsAndCommentTree(t *testing.T) {
+
+	db := openSQLite(t)
+	repos := repository.NewGormRepositories(db)
+	searcher, err := search.New(db, search.EngineAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts := service.NewPostService(repos.Posts, repos.Tags, repos.Categories, searcher)
+	comments := service.NewCommentService(repos.Comments, repos.Posts, searcher)
+	alice := createUser(t, repos, "alice")
+	actor := service.Actor{UserID: alice.ID}
+
+	post, err := posts.Create(alice.ID, service.PostInput{Title: "Hello SQLite", Content: "first version"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只修改标签不产生历史版本，旧版本号更新失败
+	post, err = posts.Update(actor, post.ID, post.Version, service.PostPatch{Tags: []string{"go"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "second version"
+	if _, err := posts.Update(actor, post.ID, post.Version-1, service.PostPatch{Content: &content}); err == nil {
+		t.Fatal("expected version conflict")
+	}
+	if _, err := posts.Update(actor, post.ID, post.Version, service.PostPatch{Content: &content}); err != nil {
+		t.Fatal(err)
+	}
+	revisions, total, err := posts.ListRevisions(actor, post.ID, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || revisions[0].Revision != 3 || revisions[1].Revision != 1 || revisions[0].Author.Username != "alice" {
+		t.Fatalf("unexpected revisions: %d %+v", total, revisions)
+	}
+
+	// 递归 CTE：已删除的评论只要子树中还有未删除的回复就保留为占位
+	var parent *uint
+	var ids []uint
+	for _, text := range []string{"c1", "c2", "c3"} {
+		c, err := comments.Create(alice.ID, post.ID, parent, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, c.ID)
+		parent = &c.ID
+	}
+	for _, id := range ids[:2] {
+		if err := comments.Delete(actor, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	nodes, total, err := comments.Thread(post.ID, nil, service.MaxThreadDepth, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || !nodes[0].Deleted || nodes[0].ReplyCount != 1 ||
+		len(nodes[0].Replies) != 1 || len(nodes[0].Replies[0].Replies) != 1 || nodes[0].Replies[0].Replies[0].Content != "c3" {
+		t.Fatalf("expected two placeholders above c3, got %d nodes", total)
+	}
+	if err := comments.Delete(actor, ids[2]); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, err = comments.Thread(post.ID, nil, service.MaxThreadDepth, 1, 10); err != nil || total != 0 {
+		t.Fatalf("expected empty thread, got %d, %v", total, err)
+	}
+}
+
+// 迁移只处理规范列为空的用户；冲突的用户在管理员改名前每次迁移都会报告
+func TestSQLiteBackfillIdentitiesOnlyTouchesMissingCanonicals(t *testing.T) {
+
+	db := openSQLite(t)
+	repos := repository.NewGormRepositories(db)
+	alice := createUser(t, repos, "alice")
+	carol := createUser(t, repos, "carol")
+	legacy := createUser(t, repos, "legacy")
+	exec := func(sql string, args ...interface{}) {
+		t.Helper()
+		if err := db.Exec(sql, args...).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 模拟规范列上线前的数据：carol 没有规范形式
+	exec("UPDATE users SET username_canonical = NULL, email_canonical = NULL WHERE id = ?", carol.ID)
+	// 规范列不为空的用户不会被重新计算
+	exec("UPDATE users SET username_canonical = 'stale' WHERE id = ?", alice.ID)
+
+	canonical := func(id uint) (username, email *string) {
+		t.Helper()
+		var u model.User
+		if err := db.Unscoped().First(&u, id).Error; err != nil {
+			t.Fatal(err)
+		}
+		return u.UsernameCanonical, u.EmailCanonical
+	}
+
+	collisions, err := repository.BackfillIdentities(db)
+	if err != nil || len(collisions) != 0 {
+		t.Fatalf("expected no collisions while alice holds a stale canonical, got %v, %v", collisions, err)
+	}
+	if username, _ := canonical(alice.ID); *username != "stale" {
+		t.Fatalf("alice's canonical username was recomputed: %q", *username)
+	}
+	if username, email := canonical(carol.ID); username == nil || *username != "carol" || email == nil || *email != "carol@example.com" {
+		t.Fatalf("carol was not backfilled: %v %v", username, email)
+	}
+
+	// legacy 改名为与 alice 冲突的 ALICE，规范用户名保持为空
+	exec("UPDATE users SET username_canonical = 'alice' WHERE id = ?", alice.ID)
+	exec("UPDATE users SET username = 'ALICE', username_canonical = NULL, email_canonical = NULL WHERE id = ?", legacy.ID)
+	for range 2 {
+		collisions, err = repository.BackfillIdentities(db)
+		if err != nil || len(collisions) != 1 {
+			t.Fatalf("expected one collision, got %v, %v", collisions, err)
+		}
+		c := collisions[0]
+		if c.Column != "username" || c.Canonical != "alice" || c.KeptID != alice.ID || len(c.UserIDs) != 2 {
+			t.Fatalf("unexpected collision %s", c)
+		}
+		if username, email := canonical(legacy.ID); username != nil || email == nil {
+			t.Fatalf("legacy should keep an empty canonical username only: %v %v", username, email)
+		}
+	}
+
+	// 管理员改名后冲突消失
+	exec("UPDATE users SET username = 'legacy2' WHERE id = ?", legacy.ID)
+	if collisions, err = repository.BackfillIdentities(db); err != nil || len(collisions) != 0 {
+		t.Fatalf("expected no collisions after rename, got %v, %v", collisions, err)
+	}
+	if username, _ := canonical(legacy.ID); username == nil || *username != "legacy2" {
+		t.Fatalf("legacy was not backfilled after rename: %v", username)
+	}
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jheader/golang_blog/service"
+	"github.com/jheader/golang_blog/utils"
+)
+
+// AdminController 用户管理接口，路由上需要 users:manage 权限
+type AdminController struct {
+	users *service.UserService
+}
+
+func NewAdminController(users *service.UserService) *AdminController {
+	return &AdminController{users: users}
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user moderator admin"`
+	// Permissions 在角色之外单独授予的权限，例如 ["posts:delete:any"]
+	Permissions []string `json:"permissions" binding:"omitempty,dive,required"`
+}
+
+// SetUserRole PUT /admin/users/:id/role 修改用户角色和单独授予的权限
+func (a *AdminController) SetUserRole(c *gin.Context) {
+
+	userID, ok := uintParam(c, "id")
+	if !ok {
+		utils.BadRequest(c, "用户ID格式错误（必须为数字）")
+		return
+	}
+	var req SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+	user, err := a.users.SetRole(currentActor(c), userID, req.Role, req.Permissions)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, user)
+}
+
+// ListUsers GET /admin/users?q=&page=&size= 分页列出用户，q 按用户名或邮箱模糊匹配
+func (a *AdminController) ListUsers(c *gin.Context) {
+
+	page, size := pageParams(c)
+	users, total, err := a.users.List(c.Query("q"), page, size)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, utils.NewPageResponse(users, total, page, size))
+}
+
+// DisableUser POST /admin/users/:id/disable 停用账号，已签发的令牌立即失效
+func (a *AdminController) DisableUser(c *gin.Context) {
+
+	userID, ok := uintParam(c, "id")
+	if !ok {
+		utils.BadRequest(c, "用户ID格式错误（必须为数字）")
+		return
+	}
+	user, err := a.users.Disable(currentActor(c), userID)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, user)
+}
+
+// EnableUser POST /admin/users/:id/enable 重新启用账号
+func (a *AdminController) EnableUser(c *gin.Context) {
+
+	userID, ok := uintParam(c, "id")
+	if !ok {
+		utils.BadRequest(c, "用户ID格式错误（必须为数字）")
+		return
+	}
+	user, err := a.users.Enable(userID)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, user)
+}
+
+// ForcePasswordReset POST /admin/users/:id/force-password-reset 要求用户重置密码后才能登录
+func (a *AdminController) ForcePasswordReset(c *gin.Context) {
+
+	userID, ok := uintParam(c, "id")
+	if !ok {
+		utils.BadRequest(c, "用户ID格式错误（必须为数字）")
+		return
+	}
+	user, err := a.users.ForcePasswordReset(userID)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, user)
+}
+
+// DeleteUser DELETE /admin/users/:id?mode=anonymize|cascade 彻底删除用户，
+// anonymize 保留文章和评论并改为匿名，cascade 一并删除
+func (a *AdminController) DeleteUser(c *gin.Context) {
+
+	userID, ok := uintParam(c, "id")
+	if !ok {
+		utils.BadRequest(c, "用户ID格式错误（必须为数字）")
+		return
+	}
+	if err := a.users.Delete(currentActor(c), userID, c.Query("mode")); err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, "删除用户成功")
+}
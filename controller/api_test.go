@@ -0,0 +1,135 @@
+package controller_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jheader/golang_blog/mail"
+	"github.com/jheader/golang_blog/repository"
+	"github.com/jheader/golang_blog/routes"
+	"github.com/jheader/golang_blog/search"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// TestMain 设置测试用的配置，不读取 .env，也不输出请求日志
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	logrus.SetOutput(io.Discard)
+	viper.Set("JWT_SECRET", "test-secret")
+	viper.Set("LOGIN_MAX_ATTEMPTS", 5)
+	viper.Set("LOGIN_LOCK_MINUTES", 15)
+	viper.Set("ACCESS_TOKEN_TTL_MINUTES", 120)
+	viper.Set("REFRESH_TOKEN_TTL_HOURS", 720)
+	os.Exit(m.Run())
+}
+
+// apiServer 基于内存仓储的完整路由，handler 与生产环境相同
+type apiServer struct {
+	t      *testing.T
+	engine *gin.Engine
+	repos  *repository.Repositories
+}
+
+func newAPIServer(t *testing.T) *apiServer {
+	repos := repository.NewMemoryRepositories()
+	return &apiServer{t: t, engine: routes.SetupRoutes(repos, search.NewIndex(), &mail.LogMailer{}), repos: repos}
+}
+
+// apiResponse 统一响应格式中测试关心的字段
+type apiResponse struct {
+	Status    int
+	Header    http.Header
+	ErrorCode string          `json:"error_code"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// do 发送请求，body 不为 nil 时编码为 JSON；headers 依次为键、值
+func (s *apiServer) do(method, path, token string, body any, headers ...string) apiResponse {
+	s.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := s.serve(req)
+
+	resp := apiResponse{Status: w.Code, Header: w.Header()}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		s.t.Fatalf("%s %s: invalid JSON response %q", method, path, w.Body.String())
+	}
+	return resp
+}
+
+// serve 直接交给路由处理，用于不返回 JSON 的接口（例如跳转）
+func (s *apiServer) serve(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.engine.ServeHTTP(w, req)
+	return w
+}
+
+// expect 检查状态码，wantCode 不为空时同时检查错误码
+func (r apiResponse) expect(t *testing.T, status int, wantCode string) {
+	t.Helper()
+
+	if r.Status != status || r.ErrorCode != wantCode {
+		t.Fatalf("expected %d %q, got %d %q: %s", status, wantCode, r.Status, r.ErrorCode, r.Data)
+	}
+}
+
+// decode 把 data 解析到 v
+func (r apiResponse) decode(t *testing.T, v any) {
+	t.Helper()
+
+	if err := json.Unmarshal(r.Data, v); err != nil {
+		t.Fatalf("decode data %s: %v", r.Data, err)
+	}
+}
+
+// register 注册用户并返回访问令牌
+func (s *apiServer) register(username string) string {
+	s.t.Helper()
+
+	resp := s.do(http.MethodPost, "/api/v1/auth/register", "", map[string]string{
+		"username": username,
+		"email":    username + "@example.com",
+		"password": "secret123",
+	})
+	resp.expect(s.t, http.StatusOK, "")
+	var data struct{ Token string }
+	resp.decode(s.t, &data)
+	return data.Token
+}
+
+// createPost 发布一篇文章，返回文章 id
+func (s *apiServer) createPost(token, title string) uint {
+	s.t.Helper()
+
+	resp := s.do(http.MethodPost, "/api/v1/posts", token, map[string]string{
+		"title":   title,
+		"content": title + " content",
+		"status":  "published",
+	})
+	resp.expect(s.t, http.StatusCreated, "")
+	var post struct{ ID uint }
+	resp.decode(s.t, &post)
+	return post.ID
+}
@@ -1,22 +1,56 @@
 package controller
 
 import (
+	"strings"
+
 	"github.com/gin-gonic/gin"
-	"github.com/jheader/golang_blog/config"
-	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/dto"
+	"github.com/jheader/golang_blog/service"
 	"github.com/jheader/golang_blog/utils"
 )
 
-type AuthController struct{}
+type AuthController struct {
+	auth *service.AuthService
+}
+
+func NewAuthController(auth *service.AuthService) *AuthController {
+	return &AuthController{auth: auth}
+}
 
 type RegisterRequest struct {
-	Username string `json:"username" binding:"required,min=3,max=20"`
+	Username string `json:"username" binding:"required,min=3,max=20,username"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required,min=6,password_strength"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6,password_strength"`
+}
+
+// MFARequest 登录第二步，code 为验证器应用中的6位验证码，丢失设备时改用 recovery_code
+type MFARequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code" binding:"required_without=RecoveryCode,max=10"`
+	RecoveryCode string `json:"recovery_code" binding:"required_without=Code,max=32"`
+}
+
+// LoginRequest login 为用户名或邮箱，不区分大小写；username 为旧字段名，二者传一个即可
 type LoginRequest struct {
-	Username string `json:"username" binding:"required,min=3,max=20"`
+	Login    string `json:"login" binding:"required_without=Username,max=255"`
+	Username string `json:"username" binding:"required_without=Login,max=255"`
 	Password string `json:"password" binding:"required,min=6"`
 }
 
@@ -24,80 +58,168 @@ func (ac *AuthController) Register(c *gin.Context) {
 
 	var req RegisterRequest
 
-	err := c.Bind(&req)
+	err := c.ShouldBindJSON(&req)
 	if err != nil {
-		utils.BadRequest(c, err.Error())
+		utils.BindError(c, err)
 		return
 	}
 
-	//查询用户是否存在
-	u, err := model.GetUserByUsername(req.Username, config.DB)
-	if err == nil {
-		utils.BadRequest(c, (*u).Username+"already exists")
+	user, pair, err := ac.auth.Register(req.Username, req.Email, req.Password)
+	if err != nil {
+		utils.RespondError(c, err)
 		return
 	}
 
-	var existingUser model.User
-	// 检查邮箱是否已存在
-	if err := config.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
-		utils.BadRequest(c, "Email already exists")
-		return
-	}
+	utils.Success(c, map[string]any{
+		"Token":        pair.AccessToken,
+		"RefreshToken": pair.RefreshToken,
+		"User":         user,
+	})
 
-	// 创建新用户
-	user := model.User{
-		Username: req.Username,
-		Email:    req.Email,
-		Password: req.Password, // 密码会在BeforeCreate钩子中自动加密
-	}
+}
+
+func (ac *AuthController) Login(c *gin.Context) {
 
-	errorMsgs := (&user).Save(config.DB)
-	if errorMsgs != nil {
-		utils.InternalServerError(c, errorMsgs.Error())
+	var req LoginRequest
+
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.BindError(c, err)
 		return
 	}
 
-	// 生成JWT token
-	token, err := utils.GenerateToken(user.ID, user.Username)
+	login := req.Login
+	if login == "" {
+		login = req.Username
+	}
+	u, pair, err := ac.auth.Login(login, req.Password, c.ClientIP())
 	if err != nil {
-		utils.InternalServerError(c, "Failed to generate token")
+		utils.RespondLoginError(c, err)
+		return
+	}
+	// 开启了两步验证，需要再调用 POST /auth/mfa 提交验证码
+	if pair.MFAToken != "" {
+		utils.Success(c, map[string]any{
+			"MFARequired": true,
+			"MFAToken":    pair.MFAToken,
+		})
 		return
 	}
 
 	utils.Success(c, map[string]any{
-		"Token": token,
-		"User":  user,
+		"Token":        pair.AccessToken,
+		"RefreshToken": pair.RefreshToken,
+		"User":         u,
 	})
 
 }
 
-func (ac *AuthController) Login(c *gin.Context) {
+// VerifyMFA POST /auth/mfa 用登录返回的 MFAToken 和验证码换取令牌对
+func (ac *AuthController) VerifyMFA(c *gin.Context) {
 
-	var req LoginRequest
+	var req MFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
 
-	err := c.Bind(&req)
+	u, pair, err := ac.auth.VerifyMFA(req.MFAToken, req.Code, req.RecoveryCode, c.ClientIP())
 	if err != nil {
-		utils.BadRequest(c, err.Error())
+		utils.RespondLoginError(c, err)
 		return
 	}
 
-	//查询用户是否存在
-	u, err := model.GetUserByUsername(req.Username, config.DB)
-	if err != nil {
-		utils.UserNotExsit(c)
+	utils.Success(c, map[string]any{
+		"Token":        pair.AccessToken,
+		"RefreshToken": pair.RefreshToken,
+		"User":         u,
+	})
+}
+
+// Refresh 用刷新令牌换取新的令牌对
+func (ac *AuthController) Refresh(c *gin.Context) {
+
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
 		return
 	}
 
-	// 生成JWT token
-	token, err := utils.GenerateToken(u.ID, u.Username)
+	pair, err := ac.auth.Refresh(req.RefreshToken, c.ClientIP())
 	if err != nil {
-		utils.InternalServerError(c, "Failed to generate token")
+		utils.RespondError(c, err)
 		return
 	}
 
 	utils.Success(c, map[string]any{
-		"Token": token,
-		"User":  u,
+		"Token":        pair.AccessToken,
+		"RefreshToken": pair.RefreshToken,
 	})
+}
+
+// Logout 吊销刷新令牌；若携带了访问令牌，同时将其拉黑
+func (ac *AuthController) Logout(c *gin.Context) {
+
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+
+	accessToken, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if err := ac.auth.Logout(req.RefreshToken, accessToken); err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+
+	utils.Success(c, "logout success")
+}
+
+// VerifyEmail 使用邮件中的令牌验证邮箱
+func (ac *AuthController) VerifyEmail(c *gin.Context) {
+
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+
+	user, err := ac.auth.VerifyEmail(req.Token)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, dto.NewProfile(*user))
+}
+
+// ForgotPassword 发送重置密码邮件，无论邮箱是否注册都返回成功
+func (ac *AuthController) ForgotPassword(c *gin.Context) {
+
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+
+	if err := ac.auth.ForgotPassword(req.Email); err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, "if the email is registered, a password reset link has been sent")
+}
 
+// ResetPassword 使用邮件中的令牌设置新密码，之后需要重新登录
+func (ac *AuthController) ResetPassword(c *gin.Context) {
+
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+
+	if err := ac.auth.ResetPassword(req.Token, req.NewPassword); err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, "password has been reset")
 }
@@ -0,0 +1,27 @@
+package controller_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestLoginStatuses(t *testing.T) {
+
+	s := newAPIServer(t)
+	s.register("alice")
+	login := func(username, password, ip string) apiResponse {
+		return s.do(http.MethodPost, "/api/v1/auth/login", "",
+			map[string]string{"login": username, "password": password}, "X-Forwarded-For", ip)
+	}
+
+	// 登录接口保留旧的 601/602 状态码，其余接口使用标准状态码（LOGIN_MAX_ATTEMPTS=5）
+	login("nobody", "secret123", "10.0.0.1").expect(t, 602, "invalid_credentials")
+	for range 4 {
+		login("alice", "wrong-password", "10.0.0.2").expect(t, 602, "invalid_credentials")
+	}
+	login("alice", "wrong-password", "10.0.0.2").expect(t, 601, "account_locked")
+	// 账号已锁定，换一个 IP、密码正确也不能登录
+	login("alice", "secret123", "10.0.0.3").expect(t, 601, "account_locked")
+
+	s.do(http.MethodGet, "/api/v1/users/nobody", "", nil).expect(t, http.StatusNotFound, "user_not_found")
+}
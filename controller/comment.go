@@ -1,24 +1,32 @@
 package controller
 
 import (
-	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jheader/golang_blog/config"
-	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/dto"
+	"github.com/jheader/golang_blog/service"
 	"github.com/jheader/golang_blog/utils"
 )
 
-type CommentController struct{}
+type CommentController struct {
+	comments *service.CommentService
+}
+
+func NewCommentController(comments *service.CommentService) *CommentController {
+	return &CommentController{comments: comments}
+}
 
 type CreateCommentRequest struct {
 	Content string `json:"content" binding:"required,min=1,max=1000"`
+	// ParentID 回复的评论，不传表示直接评论文章
+	ParentID *uint `json:"parent_id" binding:"omitempty,min=1"`
 }
 
 func (com *CommentController) CreateComment(c *gin.Context) {
 
-	userID, exists := c.Get("user_id")
+	userID, exists := currentUserID(c)
 	if !exists {
 		utils.Unauthorized(c, "User not authenticated")
 		return
@@ -31,46 +39,65 @@ func (com *CommentController) CreateComment(c *gin.Context) {
 	}
 	var req CreateCommentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.BadRequest(c, err.Error())
+		utils.BindError(c, err)
 		return
 	}
 
-	// 检查文章是否存在
-	var post model.Post
-	if err := config.DB.First(&post, postID).Error; err != nil {
-		utils.NotFound(c, "Post not found")
+	comment, err := com.comments.Create(userID, uint(postID), req.ParentID, req.Content)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+
+	utils.Success(c, dto.NewComment(*comment))
+}
+
+type UpdateCommentRequest struct {
+	Content string `json:"content" binding:"required,min=1,max=1000"`
+}
+
+// UpdateComment PUT /comments/:id 修改评论内容，只有评论作者和管理员可以修改，响应中带 edited_at
+func (com *CommentController) UpdateComment(c *gin.Context) {
+
+	commentID, ok := uintParam(c, "id")
+	if !ok {
+		utils.BadRequest(c, "评论ID格式错误（必须为数字）")
 		return
 	}
-	comment := model.Comment{
-		Content: req.Content,
-		UserID:  userID.(uint),
-		PostID:  uint(postID),
+	var req UpdateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
 	}
 
-	if err := config.DB.Create(&comment).Error; err != nil {
-		utils.InternalServerError(c, "Failed to create comment")
+	comment, err := com.comments.Update(currentActor(c), commentID, req.Content)
+	if err != nil {
+		utils.RespondError(c, err)
 		return
 	}
+	utils.Success(c, dto.NewComment(*comment))
+}
 
-	// 预加载用户信息
-	config.DB.Preload("User").First(&comment, comment.ID)
+// DeleteComment DELETE /comments/:id 软删除评论，评论作者、文章作者和管理员可以删除
+func (com *CommentController) DeleteComment(c *gin.Context) {
 
-	utils.Success(c, comment)
+	commentID, ok := uintParam(c, "id")
+	if !ok {
+		utils.BadRequest(c, "评论ID格式错误（必须为数字）")
+		return
+	}
+	if err := com.comments.Delete(currentActor(c), commentID); err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, "删除评论成功")
 }
 
+// GetComments GET /posts/commentsByPostId?postId= 评论列表。
+// 默认 view=flat 返回平铺的列表；view=tree 返回评论树，按顶层评论分页，depth 为展开的层数，parent_id 指定从哪条评论开始展开
 func (com *CommentController) GetComments(c *gin.Context) {
 
-	pageStr := c.DefaultQuery("page", "1") // 默认第1页
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page <= 0 {
-		page = 1 // 解析失败或页码无效，默认第1页
-	}
-
-	sizeStr := c.DefaultQuery("size", "10") // 默认每页10条
-	size, err := strconv.Atoi(sizeStr)
-	if err != nil || size <= 0 || size > 100 {
-		size = 10 // 解析失败或条数无效，默认10条（限制最大100）
-	}
+	page, size := pageParams(c)
 	//文章id
 	postIDStr := c.Query("postId")
 	if postIDStr == "" {
@@ -83,35 +110,72 @@ func (com *CommentController) GetComments(c *gin.Context) {
 		utils.BadRequest(c, "文章ID格式不对")
 		return
 	}
-	var total int64
-	if err := config.DB.Model(&model.Comment{}).Where("post_id = ?", postId).Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code": 500,
-			"msg":  "统计文章总数失败：" + err.Error(),
-			"data": nil,
-		})
+	switch c.DefaultQuery("view", "flat") {
+	case "flat":
+	case "tree":
+		com.getThread(c, uint(postId), page, size)
+		return
+	default:
+		utils.BadRequest(c, "view 只能是 flat 或 tree")
+		return
+	}
+	query, err := utils.ParseListQuery(c.Request.URL.Query(), service.CommentListSpec)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	cq, useCursor, err := utils.ParseCursorQuery(c.Request.URL.Query(), query)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	if useCursor {
+		comments, err := com.comments.ListByPostCursor(uint(postId), query, cq)
+		if err != nil {
+			utils.RespondError(c, err)
+			return
+		}
+		respondCursor(c, utils.NewCursorResponse(comments, cq, func(cm dto.Comment) (time.Time, uint) { return cm.CreatedAt, cm.ID }), query.Fields)
 		return
 	}
-
 	//分页查询
-	var comments []model.Comment
-	if err := config.DB.Scopes(utils.Paginate(page, size)).Where("post_id = ?", postId).Find(&comments).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code": 500,
-			"msg":  "查询文章列表失败：" + err.Error(),
-			"data": nil,
-		})
+	comments, total, err := com.comments.ListByPost(uint(postId), query, page, size)
+	if err != nil {
+		utils.RespondError(c, err)
 		return
 	}
 
-	// 3. 构建分页响应（使用工具类统一格式）
-	pageResp := utils.NewPageResponse(comments, total, page, size)
+	// 3. 构建分页响应（使用工具类统一格式），指定了 fields 时只返回这些字段
+	respondPage(c, comments, query.Fields, total, page, size)
+
+}
+
+func (com *CommentController) getThread(c *gin.Context, postID uint, page, size int) {
 
-	// 4. 成功响应
-	c.JSON(http.StatusOK, gin.H{
-		"code": 200,
-		"msg":  "查询成功",
-		"data": pageResp,
-	})
+	depth := service.DefaultThreadDepth
+	if raw := c.Query("depth"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			utils.BadRequest(c, "depth 必须是数字")
+			return
+		}
+		depth = v
+	}
+	var parentID *uint
+	if raw := c.Query("parent_id"); raw != "" {
+		v, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || v == 0 {
+			utils.BadRequest(c, "parent_id 格式不对")
+			return
+		}
+		id := uint(v)
+		parentID = &id
+	}
 
+	nodes, total, err := com.comments.Thread(postID, parentID, depth, page, size)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, utils.NewPageResponse(nodes, total, page, size))
 }
@@ -0,0 +1,69 @@
+package controller_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// commentNode 评论树中测试关心的字段
+type commentNode struct {
+	ID         uint
+	Content    string
+	Deleted    bool
+	ReplyCount int64 `json:"reply_count"`
+	Replies    []commentNode
+}
+
+func TestCommentTreeKeepsDeletedAncestors(t *testing.T) {
+
+	s := newAPIServer(t)
+	alice := s.register("alice")
+	bob := s.register("bob")
+	postID := s.createPost(alice, "Discussion")
+
+	comment := func(token, content string, parentID uint) uint {
+		t.Helper()
+		body := map[string]any{"content": content}
+		if parentID != 0 {
+			body["parent_id"] = parentID
+		}
+		resp := s.do(http.MethodPost, fmt.Sprintf("/api/v1/posts/%d/comment", postID), token, body)
+		resp.expect(t, http.StatusOK, "")
+		var c struct{ ID uint }
+		resp.decode(t, &c)
+		return c.ID
+	}
+	tree := func() []commentNode {
+		t.Helper()
+		resp := s.do(http.MethodGet, fmt.Sprintf("/api/v1/posts/commentsByPostId?postId=%d&view=tree&depth=5", postID), "", nil)
+		resp.expect(t, http.StatusOK, "")
+		var page struct{ List []commentNode }
+		resp.decode(t, &page)
+		return page.List
+	}
+
+	root := comment(alice, "root", 0)
+	middle := comment(bob, "middle", root)
+	leaf := comment(alice, "leaf", middle)
+
+	// 只有作者（或文章作者）能删除评论
+	s.do(http.MethodDelete, fmt.Sprintf("/api/v1/comments/%d", middle), alice, nil).expect(t, http.StatusOK, "")
+	s.do(http.MethodDelete, fmt.Sprintf("/api/v1/comments/%d", root), bob, nil).expect(t, http.StatusForbidden, "comment_not_owned")
+	s.do(http.MethodDelete, fmt.Sprintf("/api/v1/comments/%d", root), alice, nil).expect(t, http.StatusOK, "")
+
+	nodes := tree()
+	if len(nodes) != 1 || !nodes[0].Deleted || nodes[0].Content != "[deleted]" || nodes[0].ReplyCount != 1 {
+		t.Fatalf("expected deleted root placeholder, got %+v", nodes)
+	}
+	second := nodes[0].Replies
+	if len(second) != 1 || !second[0].Deleted || len(second[0].Replies) != 1 || second[0].Replies[0].Content != "leaf" {
+		t.Fatalf("expected deleted middle placeholder above the live leaf, got %+v", second)
+	}
+
+	s.do(http.MethodDelete, fmt.Sprintf("/api/v1/comments/%d", leaf), alice, nil).expect(t, http.StatusOK, "")
+	if nodes := tree(); len(nodes) != 0 {
+		t.Fatalf("expected empty tree once every comment is deleted, got %+v", nodes)
+	}
+	s.do(http.MethodDelete, fmt.Sprintf("/api/v1/comments/%d", leaf), alice, nil).expect(t, http.StatusNotFound, "comment_not_found")
+}
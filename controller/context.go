@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jheader/golang_blog/middleware"
+	"github.com/jheader/golang_blog/service"
+	"github.com/jheader/golang_blog/utils"
+)
+
+// currentUserID 读取 AuthMiddleware 写入上下文的用户ID
+func currentUserID(c *gin.Context) (uint, bool) {
+	v, exists := c.Get("user_id")
+	if !exists {
+		return 0, false
+	}
+	id, ok := v.(uint)
+	return id, ok
+}
+
+// currentActor 当前用户及其权限，传给 service 做权限判断
+func currentActor(c *gin.Context) service.Actor {
+	userID, _ := currentUserID(c)
+	return service.Actor{UserID: userID, Permissions: middleware.Permissions(c)}
+}
+
+// maxPage 页码上限，避免 (page-1)*size 计算偏移量时溢出
+const maxPage = 100000
+
+// pageParams 解析分页参数 page/size，非法值回退到默认值（第1页，每页10条，最大100），页码超过上限按上限处理
+func pageParams(c *gin.Context) (int, int) {
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		page = 1 // 解析失败或页码无效，默认第1页
+	}
+	page = min(page, maxPage)
+	size, err := strconv.Atoi(c.DefaultQuery("size", "10"))
+	if err != nil || size <= 0 || size > 100 {
+		size = 10 // 解析失败或条数无效，默认10条（限制最大100）
+	}
+	return page, size
+}
+
+// uintParam 解析路由中的数字参数
+func uintParam(c *gin.Context, name string) (uint, bool) {
+	v, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(v), true
+}
+
+// respondPage 返回分页响应，fields 不为空时每一项只保留这些字段
+func respondPage[T any](c *gin.Context, list []T, fields []string, total int64, page, size int) {
+
+	if len(fields) == 0 {
+		utils.Success(c, utils.NewPageResponse(list, total, page, size))
+		return
+	}
+	selected, err := utils.SelectFields(list, fields)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, utils.NewPageResponse(selected, total, page, size))
+}
+
+// respondCursor 返回游标分页响应，fields 不为空时每一项只保留这些字段
+func respondCursor[T any](c *gin.Context, resp *utils.CursorResponse[T], fields []string) {
+
+	if len(fields) == 0 {
+		utils.Success(c, resp)
+		return
+	}
+	selected, err := utils.SelectFields(resp.List, fields)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, &utils.CursorResponse[map[string]json.RawMessage]{
+		List:       selected,
+		NextCursor: resp.NextCursor,
+		PrevCursor: resp.PrevCursor,
+		Limit:      resp.Limit,
+	})
+}
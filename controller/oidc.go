@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jheader/golang_blog/service"
+	"github.com/jheader/golang_blog/utils"
+)
+
+// oidcCookiePath 登录状态的 Cookie 只在发起登录和回调时发送
+const oidcCookiePath = "/api/v1/auth/oidc"
+
+type OIDCController struct {
+	oidc *service.OIDCService
+}
+
+func NewOIDCController(oidc *service.OIDCService) *OIDCController {
+	return &OIDCController{oidc: oidc}
+}
+
+// Providers GET /auth/oidc/providers 已配置的身份提供方，前端据此显示登录按钮
+func (oc *OIDCController) Providers(c *gin.Context) {
+	utils.Success(c, map[string]any{"providers": oc.oidc.Providers()})
+}
+
+// Login GET /auth/oidc/:provider/login 跳转到身份提供方的登录页面
+func (oc *OIDCController) Login(c *gin.Context) {
+
+	url, state, err := oc.oidc.AuthCodeURL(c.Param("provider"))
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	// 回调是从身份提供方跳转回来的顶层 GET 请求，SameSite=Lax 下 Cookie 会被发送
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(utils.OIDCStateCookie, state, int(utils.OIDCStateTTL().Seconds()), oidcCookiePath, "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, url)
+}
+
+// Callback GET /auth/oidc/:provider/callback 身份提供方登录完成后跳转回来，返回与密码登录相同的令牌
+func (oc *OIDCController) Callback(c *gin.Context) {
+
+	// state 只能使用一次，无论成功与否都删除
+	cookie, _ := c.Cookie(utils.OIDCStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(utils.OIDCStateCookie, "", -1, oidcCookiePath, "", c.Request.TLS != nil, true)
+
+	// 用户在身份提供方拒绝授权等情况
+	if errCode := c.Query("error"); errCode != "" {
+		utils.RespondError(c, utils.NewAppError(utils.ErrUnauthorized, "oidc_error", errCode+": "+c.Query("error_description")))
+		return
+	}
+	code := c.Query("code")
+	if code == "" {
+		utils.BadRequest(c, "code is required")
+		return
+	}
+
+	u, pair, err := oc.oidc.Callback(c.Request.Context(), c.Param("provider"), code, c.Query("state"), cookie, c.ClientIP())
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+
+	if pair.MFAToken != "" {
+		utils.Success(c, map[string]any{
+			"MFARequired": true,
+			"MFAToken":    pair.MFAToken,
+		})
+		return
+	}
+	utils.Success(c, map[string]any{
+		"Token":        pair.AccessToken,
+		"RefreshToken": pair.RefreshToken,
+		"User":         u,
+	})
+}
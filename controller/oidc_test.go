@@ -0,0 +1,278 @@
+package controller_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jheader/golang_blog/utils"
+	"github.com/spf13/viper"
+)
+
+// mockProvider 测试用的 OpenID Connect 身份提供方，与 cmd/mockoidc 的行为一致：
+// 授权请求直接通过，换取令牌时校验客户端凭据、redirect_uri 和 PKCE verifier，授权码只能使用一次
+type mockProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]url.Values // 授权码 -> 授权请求的参数
+	// nonce 不为空时签发的 ID Token 使用这个 nonce，模拟被替换的令牌
+	nonce string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &mockProvider{t: t, key: key, codes: make(map[string]url.Values)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	mux.HandleFunc("GET /authorize", p.authorize)
+	mux.HandleFunc("POST /token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *mockProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := p.server.URL
+	writeProviderJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"jwks_uri":                              issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *mockProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeProviderJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *mockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	code := rand.Text()
+	p.mu.Lock()
+	p.codes[code] = q
+	p.mu.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeProviderJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != "blog" || clientSecret != "secret" {
+		writeProviderJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.mu.Lock()
+	auth, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	nonce := p.nonce
+	p.mu.Unlock()
+	if !ok || auth.Get("redirect_uri") != r.PostForm.Get("redirect_uri") {
+		writeProviderJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.Get("code_challenge") {
+		writeProviderJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if nonce == "" {
+		nonce = auth.Get("nonce")
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                p.server.URL,
+		"sub":                "subject-1",
+		"aud":                clientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Hour).Unix(),
+		"nonce":              nonce,
+		"email":              "carol@example.com",
+		"email_verified":     true,
+		"preferred_username": "carol",
+		"name":               "Carol",
+	})
+	idToken.Header["kid"] = "test"
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		p.t.Error(err)
+		writeProviderJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeProviderJSON(w, http.StatusOK, map[string]any{
+		"access_token": rand.Text(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+func writeProviderJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// oidcLogin 发起登录，返回身份提供方的授权地址和 state Cookie
+func (s *apiServer) oidcLogin() (*url.URL, string) {
+	s.t.Helper()
+
+	w := s.serve(httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/mock/login", nil))
+	if w.Code != http.StatusFound {
+		s.t.Fatalf("login: expected 302, got %d: %s", w.Code, w.Body.String())
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == utils.OIDCStateCookie {
+			if !c.HttpOnly || c.Path != "/api/v1/auth/oidc" {
+				s.t.Fatalf("state cookie must be HttpOnly and scoped to the oidc routes: %+v", c)
+			}
+			return location, c.Value
+		}
+	}
+	s.t.Fatal("login did not set the state cookie")
+	return nil, ""
+}
+
+// authorize 模拟浏览器访问授权地址，返回身份提供方跳转回来的回调地址
+func authorize(t *testing.T, authURL *url.URL) *url.URL {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: expected 302, got %d", resp.StatusCode)
+	}
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return callback
+}
+
+func TestOIDCCallbackChecksStateNonceAndPKCE(t *testing.T) {
+
+	provider := newMockProvider(t)
+	viper.Set("OIDC_PROVIDERS", "mock")
+	viper.Set("OIDC_MOCK_ISSUER", provider.server.URL)
+	viper.Set("OIDC_MOCK_CLIENT_ID", "blog")
+	viper.Set("OIDC_MOCK_CLIENT_SECRET", "secret")
+	viper.Set("OIDC_REDIRECT_BASE_URL", "http://blog.test")
+	viper.Set("OIDC_STATE_TTL_MINUTES", 10)
+	t.Cleanup(func() { viper.Set("OIDC_PROVIDERS", "") })
+	s := newAPIServer(t)
+
+	callback := func(u *url.URL, cookie string) apiResponse {
+		t.Helper()
+		var headers []string
+		if cookie != "" {
+			headers = []string{"Cookie", utils.OIDCStateCookie + "=" + cookie}
+		}
+		return s.do(http.MethodGet, u.RequestURI(), "", nil, headers...)
+	}
+
+	// 授权请求带有 state、nonce 和 S256 的 code_challenge
+	authURL, cookie := s.oidcLogin()
+	q := authURL.Query()
+	if q.Get("state") == "" || q.Get("nonce") == "" || q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("authorization request is missing state, nonce or PKCE: %s", authURL)
+	}
+	if q.Get("redirect_uri") != "http://blog.test/api/v1/auth/oidc/mock/callback" {
+		t.Fatalf("unexpected redirect_uri %q", q.Get("redirect_uri"))
+	}
+	cb := authorize(t, authURL)
+
+	// 没有 Cookie，或 state 与 Cookie 不一致
+	callback(cb, "").expect(t, http.StatusBadRequest, "invalid_oidc_state")
+	tampered := *cb
+	params := tampered.Query()
+	params.Set("state", "forged")
+	tampered.RawQuery = params.Encode()
+	callback(&tampered, cookie).expect(t, http.StatusBadRequest, "invalid_oidc_state")
+
+	// 校验通过：首次登录自动创建用户
+	ok := callback(cb, cookie)
+	ok.expect(t, http.StatusOK, "")
+	var login struct {
+		Token string
+		User  struct{ Username string }
+	}
+	ok.decode(t, &login)
+	if login.Token == "" || login.User.Username != "carol" {
+		t.Fatalf("unexpected login response %s", ok.Data)
+	}
+	// 授权码只能使用一次
+	callback(cb, cookie).expect(t, http.StatusUnauthorized, "oidc_exchange_failed")
+
+	// 授权码属于另一次登录：state 和 Cookie 匹配，但 PKCE verifier 与授权请求的 code_challenge 不符
+	firstURL, _ := s.oidcLogin()
+	stolen := authorize(t, firstURL)
+	secondURL, cookie := s.oidcLogin()
+	params = stolen.Query()
+	params.Set("state", secondURL.Query().Get("state"))
+	stolen.RawQuery = params.Encode()
+	callback(stolen, cookie).expect(t, http.StatusUnauthorized, "oidc_exchange_failed")
+
+	// ID Token 中的 nonce 与本次登录不符
+	provider.mu.Lock()
+	provider.nonce = "replayed-nonce"
+	provider.mu.Unlock()
+	authURL, cookie = s.oidcLogin()
+	callback(authorize(t, authURL), cookie).expect(t, http.StatusUnauthorized, "invalid_id_token")
+}
@@ -2,192 +2,274 @@ package controller
 
 import (
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jheader/golang_blog/config"
+	"github.com/jheader/golang_blog/dto"
 	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/service"
 	"github.com/jheader/golang_blog/utils"
 )
 
-type PostController struct{}
+type PostController struct {
+	posts *service.PostService
+}
+
+func NewPostController(posts *service.PostService) *PostController {
+	return &PostController{posts: posts}
+}
+
+type CreatePostRequest struct {
+	Title   string `json:"title" binding:"required,min=1,max=100"`
+	Content string `json:"content" binding:"required,min=1,max=100000"`
+	// 状态：draft、scheduled、published（默认）、archived，scheduled 需要同时指定 publish_at
+	Status    string     `json:"status" binding:"omitempty,oneof=draft scheduled published archived"`
+	PublishAt *time.Time `json:"publish_at"`
+	// 标签名称，不存在的标签自动创建；PUT 时未传表示保持不变，[] 表示清空
+	Tags       []string `json:"tags" binding:"omitempty,max=10,dive,max=30"`
+	CategoryID *uint    `json:"category_id"`
+}
 
-type PostCreatOrUpdateRequest struct {
-	ID      *uint  `json:"postID"` // 指针类型，nil 表示未传
-	Title   string `json:"title" binding:"required,min=1"`
-	Content string `json:"content" binding:"required,min=1"`
+// PATCH 请求，未传的字段保持不变
+type PatchPostRequest struct {
+	Title      *string    `json:"title" binding:"omitempty,min=1,max=100"`
+	Content    *string    `json:"content" binding:"omitempty,min=1,max=100000"`
+	Status     *string    `json:"status" binding:"omitempty,oneof=draft scheduled published archived"`
+	PublishAt  *time.Time `json:"publish_at"`
+	Tags       []string   `json:"tags" binding:"omitempty,max=10,dive,max=30"`
+	CategoryID *uint      `json:"category_id"` // 0 表示取消分类
 }
 
-func (p *PostController) CreateOrUpdate(c *gin.Context) {
+// CreatePost POST /posts 发表文章，返回保存后的文章
+func (p *PostController) CreatePost(c *gin.Context) {
 
-	var req PostCreatOrUpdateRequest
-	err := c.ShouldBind(&req)
+	var req CreatePostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+	userID, _ := currentUserID(c)
+	post, err := p.posts.Create(userID, service.PostInput{
+		Title:      req.Title,
+		Content:    req.Content,
+		Status:     req.Status,
+		PublishAt:  req.PublishAt,
+		Tags:       req.Tags,
+		CategoryID: req.CategoryID,
+	})
 	if err != nil {
-		utils.BadRequest(c, err.Error())
+		utils.RespondError(c, err)
 		return
 	}
-	if req.ID != nil {
-		postID := *req.ID
 
-		if !checkIsOwner(uint64(postID), c) {
-			// 检查不通过，直接返回
-			return
-		}
-		//方式1：使用结构体更新（仅更新非零值字段） 2使用 map 更新（更新所有指定字段，包括零值）
-		if config.DB.Model(&model.Post{}).Where("id = ?", postID).Updates(model.Post{
-			Title:   req.Title,
-			Content: req.Content,
-		}).Error != nil {
-			utils.BadRequest(c, "更新的数据失败")
-			return
-		}
-		utils.Success(c, "更新数据成功")
+	c.Header("ETag", postETag(post))
+	c.Header("Location", "/api/v1/posts/"+strconv.FormatUint(uint64(post.ID), 10))
+	utils.Created(c, dto.NewPostDetail(*post))
+}
+
+// UpdatePost PUT /posts/:post_id 整体替换标题和内容，需要携带 If-Match
+func (p *PostController) UpdatePost(c *gin.Context) {
+
+	var req CreatePostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
 		return
 	}
-	//新增
-	user_id, _ := c.Get("user_id")
-	newPost := model.Post{
-		Title:   req.Title,
-		Content: req.Content,
-		UserID:  user_id.(uint),
+	patch := service.PostPatch{
+		Title:      &req.Title,
+		Content:    &req.Content,
+		PublishAt:  req.PublishAt,
+		Tags:       req.Tags,
+		CategoryID: req.CategoryID,
 	}
-	//忽略数据中的主键（即使设置了也会生成新主键，除非禁用自增）
-	if err := config.DB.Create(&newPost).Error; err != nil {
-		utils.BadRequest(c, "新增文章失败："+err.Error())
+	// 未指定状态时保持原状态
+	if req.Status != "" {
+		patch.Status = &req.Status
+	}
+	p.update(c, patch)
+}
+
+// PatchPost PATCH /posts/:post_id 只更新传入的字段，需要携带 If-Match
+func (p *PostController) PatchPost(c *gin.Context) {
+
+	var req PatchPostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
 		return
 	}
+	p.update(c, service.PostPatch{
+		Title:      req.Title,
+		Content:    req.Content,
+		Status:     req.Status,
+		PublishAt:  req.PublishAt,
+		Tags:       req.Tags,
+		CategoryID: req.CategoryID,
+	})
+}
+
+func (p *PostController) update(c *gin.Context, patch service.PostPatch) {
 
-	utils.Success(c, "新增数据成功")
+	postID, err := strconv.ParseUint(c.Param("post_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "文章ID格式错误（必须为数字）")
+		return
+	}
+	version, err := parseIfMatch(c.GetHeader("If-Match"))
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
 
+	post, err := p.posts.Update(currentActor(c), uint(postID), version, patch)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+
+	c.Header("ETag", postETag(post))
+	utils.Success(c, dto.NewPostDetail(*post))
 }
 
-// 查询全部文章 支持分页
-func (p *PostController) GetAllPosts(c *gin.Context) {
+// postETag 文章的 ETag 即版本号
+func postETag(post *model.Post) string {
+	return `"` + strconv.FormatUint(uint64(post.Version), 10) + `"`
+}
+
+// parseIfMatch 解析 If-Match 请求头，"*" 表示不校验版本。
+// If-Match 只能使用强比较（RFC 7232 3.1），弱校验器 W/"..." 永远不匹配；有多个 ETag 时取第一个强校验器
+func parseIfMatch(header string) (uint, error) {
 
-	var posts []model.Post
-	pageStr := c.DefaultQuery("page", "1") // 默认第1页
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page <= 0 {
-		page = 1 // 解析失败或页码无效，默认第1页
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0, utils.NewAppError(utils.ErrPreconditionRequired, "if_match_required",
+			"If-Match header is required, use the ETag returned by GET /posts/:post_id")
+	}
+	if header == "*" {
+		return service.AnyVersion, nil
+	}
+	for _, etag := range strings.Split(header, ",") {
+		etag = strings.TrimSpace(etag)
+		if strings.HasPrefix(etag, "W/") || len(etag) < 2 || !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) {
+			continue
+		}
+		version, err := strconv.ParseUint(etag[1:len(etag)-1], 10, 64)
+		if err == nil && version > 0 {
+			return uint(version), nil
+		}
 	}
+	return 0, utils.NewAppError(utils.ErrPreconditionFailed, "version_mismatch", "If-Match does not match the current version")
+}
+
+// 查询全部已发布的文章 支持分页
+func (p *PostController) GetAllPosts(c *gin.Context) {
 
-	sizeStr := c.DefaultQuery("size", "10") // 默认每页10条
-	size, err := strconv.Atoi(sizeStr)
-	if err != nil || size <= 0 || size > 100 {
-		size = 10 // 解析失败或条数无效，默认10条（限制最大100）
+	page, size := pageParams(c)
+	// sort、fields 及过滤参数，按白名单校验
+	query, err := utils.ParseListQuery(c.Request.URL.Query(), service.PostListSpec)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
 	}
-	var total int64
-	// 步骤1：统计总条数（不含 LIMIT/OFFSET）
-	if err := config.DB.Model(&model.Post{}).Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code": 500,
-			"msg":  "统计文章总数失败：" + err.Error(),
-			"data": nil,
-		})
+	// 带 cursor/limit 参数时使用游标分页，否则仍按 page/size 分页
+	cq, useCursor, err := utils.ParseCursorQuery(c.Request.URL.Query(), query)
+	if err != nil {
+		utils.RespondError(c, err)
 		return
 	}
-	// 步骤2：使用分页中间件查询当前页数据
-	if err := config.DB.Preload("User").Preload("Comments").Scopes(utils.Paginate(page, size)).Find(&posts).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code": 500,
-			"msg":  "查询文章列表失败：" + err.Error(),
-			"data": nil,
-		})
+	if useCursor {
+		posts, err := p.posts.ListByCursor(query, cq)
+		if err != nil {
+			utils.RespondError(c, err)
+			return
+		}
+		respondCursor(c, utils.NewCursorResponse(posts, cq, func(post dto.PostSummary) (time.Time, uint) { return post.CreatedAt, post.ID }), query.Fields)
+		return
+	}
+	posts, total, err := p.posts.List(query, page, size)
+	if err != nil {
+		utils.RespondError(c, err)
 		return
 	}
 
-	// 3. 构建分页响应（使用工具类统一格式）
-	pageResp := utils.NewPageResponse(posts, total, page, size)
+	// 3. 构建分页响应（使用工具类统一格式），指定了 fields 时只返回这些字段
+	respondPage(c, posts, query.Fields, total, page, size)
+}
 
-	// 4. 成功响应
-	c.JSON(http.StatusOK, gin.H{
-		"code": 200,
-		"msg":  "查询成功",
-		"data": pageResp,
-	})
+// MyPosts GET /posts/drafts?status=draft,scheduled 当前用户的草稿箱，默认列出草稿和定时发布的文章
+func (p *PostController) MyPosts(c *gin.Context) {
+
+	page, size := pageParams(c)
+	var statuses []string
+	if status := c.Query("status"); status != "" {
+		statuses = strings.Split(status, ",")
+	}
+
+	userID, _ := currentUserID(c)
+	posts, total, err := p.posts.ListMine(userID, statuses, page, size)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, utils.NewPageResponse(posts, total, page, size))
 }
 
-// 查询单个文章的详细信息。
+// 查询单个文章的详细信息，未发布的文章只有携带令牌的作者本人可以查看
 func (p *PostController) GetPostById(c *gin.Context) {
 
 	// 1. 解析路由参数（post_id）
 	postIDStr := c.Param("post_id")
 	postID, err := strconv.ParseUint(postIDStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code": 400,
-			"msg":  "文章ID格式错误（必须为数字）",
-			"data": nil,
-		})
+		utils.BadRequest(c, "文章ID格式错误（必须为数字）")
 		return
 	}
-	var post model.Post
-	if err := config.DB.Preload("User").Preload("Comments").First(&post, postID).Error; err != nil {
-		utils.BadRequest(c, "查询文章失败："+err.Error())
+	viewerID, _ := currentUserID(c)
+	post, err := p.posts.Get(viewerID, uint(postID))
+	if err != nil {
+		utils.RespondError(c, err)
 		return
 	}
 
-	utils.Success(c, post)
+	c.Header("ETag", postETag(post))
+	utils.Success(c, dto.NewPostDetail(*post))
 
 }
 
-func (p *PostController) DeletedById(c *gin.Context) {
+// GetPostBySlug GET /posts/by-slug/:slug 按 slug 查询文章，旧 slug 301 跳转到当前 slug
+func (p *PostController) GetPostBySlug(c *gin.Context) {
 
-	// 1. 解析路由参数（post_id）
-	postIDStr := c.Param("post_id")
-	postID, err := strconv.ParseUint(postIDStr, 10, 64)
+	viewerID, _ := currentUserID(c)
+	post, moved, err := p.posts.GetBySlug(viewerID, c.Param("slug"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code": 400,
-			"msg":  "文章ID格式错误（必须为数字）",
-			"data": nil,
-		})
+		utils.RespondError(c, err)
 		return
 	}
-
-	if !checkIsOwner(postID, c) {
-		// 检查不通过，直接返回
+	if moved {
+		c.Redirect(http.StatusMovedPermanently, "/api/v1/posts/by-slug/"+url.PathEscape(post.Slug))
 		return
 	}
-	var post model.Post
-	result := config.DB.Delete(&post, postID)
 
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code": 500,
-			"msg":  "删除文章失败：" + result.Error.Error(),
-			"data": nil,
-		})
+	c.Header("ETag", postETag(post))
+	utils.Success(c, dto.NewPostDetail(*post))
+}
+
+func (p *PostController) DeletedById(c *gin.Context) {
+
+	// 1. 解析路由参数（post_id）
+	postIDStr := c.Param("post_id")
+	postID, err := strconv.ParseUint(postIDStr, 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "文章ID格式错误（必须为数字）")
 		return
 	}
 
-	// 检查是否有数据被删除
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code": 404,
-			"msg":  "文章不存在或已被删除",
-			"data": nil,
-		})
+	if err := p.posts.Delete(currentActor(c), uint(postID)); err != nil {
+		utils.RespondError(c, err)
 		return
 	}
 	utils.Success(c, "删除数据成功")
 
 }
-
-func checkIsOwner(postID uint64, c *gin.Context) bool {
-
-	//检查是否是自己的文章
-	var post model.Post
-	// 用 First 替代 Find（按主键查询单条数据，未找到会返回 ErrRecordNotFound）
-	if err := config.DB.Preload("User").First(&post, postID).Error; err != nil {
-		utils.BadRequest(c, "not found post id"+strconv.FormatUint(postID, 10)+"的数据")
-		return false
-	}
-	currentUsername, _ := c.Get("current_username")
-	userna, _ := currentUsername.(string) // 断言为结构体类型
-	if userna != post.User.Username {
-		utils.BadRequest(c, "不能修改用户"+post.User.Username+"的数据")
-		return false
-	}
-	return true
-}
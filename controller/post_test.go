@@ -0,0 +1,67 @@
+package controller_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestPatchPostRequiresMatchingIfMatch(t *testing.T) {
+
+	s := newAPIServer(t)
+	alice := s.register("alice")
+	id := s.createPost(alice, "Versioned")
+	path := fmt.Sprintf("/api/v1/posts/%d", id)
+
+	got := s.do(http.MethodGet, path, alice, nil)
+	got.expect(t, http.StatusOK, "")
+	if etag := got.Header.Get("ETag"); etag != `"1"` {
+		t.Fatalf("expected ETag \"1\", got %q", etag)
+	}
+
+	patch := map[string]string{"title": "Versioned v2"}
+	s.do(http.MethodPatch, path, alice, patch).expect(t, http.StatusPreconditionRequired, "if_match_required")
+	// 弱校验器不能用于 If-Match
+	s.do(http.MethodPatch, path, alice, patch, "If-Match", `W/"1"`).expect(t, http.StatusPreconditionFailed, "version_mismatch")
+	s.do(http.MethodPatch, path, alice, patch, "If-Match", `"2"`).expect(t, http.StatusPreconditionFailed, "version_mismatch")
+
+	updated := s.do(http.MethodPatch, path, alice, patch, "If-Match", `"1"`)
+	updated.expect(t, http.StatusOK, "")
+	if etag := updated.Header.Get("ETag"); etag != `"2"` {
+		t.Fatalf("expected ETag \"2\" after update, got %q", etag)
+	}
+	// 旧版本号已过期
+	s.do(http.MethodPatch, path, alice, patch, "If-Match", `"1"`).expect(t, http.StatusPreconditionFailed, "version_mismatch")
+	s.do(http.MethodPatch, path, alice, patch, "If-Match", `W/"2", "2"`).expect(t, http.StatusOK, "")
+}
+
+func TestPostOwnership(t *testing.T) {
+
+	s := newAPIServer(t)
+	alice := s.register("alice")
+	bob := s.register("bob")
+	id := s.createPost(alice, "Alice's post")
+	path := fmt.Sprintf("/api/v1/posts/%d", id)
+	patch := map[string]string{"title": "Taken over"}
+
+	s.do(http.MethodPatch, path, bob, patch, "If-Match", "*").expect(t, http.StatusForbidden, "post_not_owned")
+	s.do(http.MethodDelete, path, bob, nil).expect(t, http.StatusForbidden, "post_not_owned")
+	s.do(http.MethodGet, path+"/revisions", bob, nil).expect(t, http.StatusForbidden, "post_not_owned")
+
+	missing := fmt.Sprintf("/api/v1/posts/%d", id+100)
+	s.do(http.MethodPatch, missing, alice, patch, "If-Match", "*").expect(t, http.StatusNotFound, "post_not_found")
+	s.do(http.MethodDelete, missing, alice, nil).expect(t, http.StatusNotFound, "post_not_found")
+
+	// 草稿只有作者能看到，其他人看到的与不存在相同
+	draft := s.do(http.MethodPost, "/api/v1/posts", alice, map[string]string{"title": "Draft", "content": "draft content", "status": "draft"})
+	draft.expect(t, http.StatusCreated, "")
+	var post struct{ ID uint }
+	draft.decode(t, &post)
+	draftPath := fmt.Sprintf("/api/v1/posts/%d", post.ID)
+	s.do(http.MethodGet, draftPath, bob, nil).expect(t, http.StatusNotFound, "post_not_found")
+	s.do(http.MethodGet, draftPath, "", nil).expect(t, http.StatusNotFound, "post_not_found")
+	s.do(http.MethodGet, draftPath, alice, nil).expect(t, http.StatusOK, "")
+
+	s.do(http.MethodDelete, path, alice, nil).expect(t, http.StatusOK, "")
+	s.do(http.MethodGet, path, alice, nil).expect(t, http.StatusNotFound, "post_not_found")
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jheader/golang_blog/dto"
+	"github.com/jheader/golang_blog/utils"
+)
+
+// ListRevisions GET /posts/:post_id/revisions 分页列出文章的历史版本（仅作者）
+func (p *PostController) ListRevisions(c *gin.Context) {
+
+	postID, ok := uintParam(c, "post_id")
+	if !ok {
+		utils.BadRequest(c, "文章ID格式错误（必须为数字）")
+		return
+	}
+	page, size := pageParams(c)
+
+	revisions, total, err := p.posts.ListRevisions(currentActor(c), postID, page, size)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, utils.NewPageResponse(revisions, total, page, size))
+}
+
+// GetRevision GET /posts/:post_id/revisions/:rev 查询某个历史版本的完整内容
+func (p *PostController) GetRevision(c *gin.Context) {
+
+	postID, ok := uintParam(c, "post_id")
+	if !ok {
+		utils.BadRequest(c, "文章ID格式错误（必须为数字）")
+		return
+	}
+	revision, ok := uintParam(c, "rev")
+	if !ok {
+		utils.BadRequest(c, "版本号格式错误（必须为数字）")
+		return
+	}
+
+	rev, err := p.posts.GetRevision(currentActor(c), postID, revision)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, rev)
+}
+
+// DiffRevisions GET /posts/:post_id/revisions/diff?from=1&to=2 比较两个历史版本
+func (p *PostController) DiffRevisions(c *gin.Context) {
+
+	postID, ok := uintParam(c, "post_id")
+	if !ok {
+		utils.BadRequest(c, "文章ID格式错误（必须为数字）")
+		return
+	}
+	from, errFrom := strconv.ParseUint(c.Query("from"), 10, 64)
+	to, errTo := strconv.ParseUint(c.Query("to"), 10, 64)
+	if errFrom != nil || errTo != nil {
+		utils.BadRequest(c, "from 和 to 必须为版本号（数字）")
+		return
+	}
+
+	diff, err := p.posts.DiffRevisions(currentActor(c), postID, uint(from), uint(to))
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, diff)
+}
+
+// RestoreRevision POST /posts/:post_id/revisions/:rev/restore 恢复到某个历史版本，生成新版本
+func (p *PostController) RestoreRevision(c *gin.Context) {
+
+	postID, ok := uintParam(c, "post_id")
+	if !ok {
+		utils.BadRequest(c, "文章ID格式错误（必须为数字）")
+		return
+	}
+	revision, ok := uintParam(c, "rev")
+	if !ok {
+		utils.BadRequest(c, "版本号格式错误（必须为数字）")
+		return
+	}
+
+	post, err := p.posts.RestoreRevision(currentActor(c), postID, revision)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+
+	c.Header("ETag", postETag(post))
+	utils.Success(c, dto.NewPostDetail(*post))
+}
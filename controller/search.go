@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jheader/golang_blog/service"
+	"github.com/jheader/golang_blog/utils"
+)
+
+type SearchController struct {
+	search *service.SearchService
+}
+
+func NewSearchController(search *service.SearchService) *SearchController {
+	return &SearchController{search: search}
+}
+
+// Search GET /search?q=&author=&author_id=&tag=&from=&to=&page=&size=
+// 搜索已发布文章的标题、正文和评论，按相关度排序，from/to 支持 2006-01-02 或 RFC3339
+func (s *SearchController) Search(c *gin.Context) {
+
+	page, size := pageParams(c)
+	in := service.SearchInput{
+		Text:   c.Query("q"),
+		Author: c.Query("author"),
+		Tag:    c.Query("tag"),
+		Page:   page,
+		Size:   size,
+	}
+	if v := c.Query("author_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			utils.BadRequest(c, "author_id 必须为数字")
+			return
+		}
+		in.AuthorID = uint(id)
+	}
+	var err error
+	if in.From, err = parseDateParam(c.Query("from"), false); err != nil {
+		utils.BadRequest(c, "from 格式错误，应为 2006-01-02 或 RFC3339")
+		return
+	}
+	if in.To, err = parseDateParam(c.Query("to"), true); err != nil {
+		utils.BadRequest(c, "to 格式错误，应为 2006-01-02 或 RFC3339")
+		return
+	}
+
+	hits, total, err := s.search.Search(in)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, utils.NewPageResponse(hits, total, page, size))
+}
+
+// parseDateParam 解析日期参数，只有日期时 endOfDay 为 true 表示取当天结束时间（包含当天）
+func parseDateParam(value string, endOfDay bool) (*time.Time, error) {
+
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
+}
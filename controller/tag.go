@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jheader/golang_blog/service"
+	"github.com/jheader/golang_blog/utils"
+)
+
+type TagController struct {
+	tags       *service.TagService
+	categories *service.CategoryService
+}
+
+func NewTagController(tags *service.TagService, categories *service.CategoryService) *TagController {
+	return &TagController{tags: tags, categories: categories}
+}
+
+type RenameTagRequest struct {
+	Name string `json:"name" binding:"required,max=30"`
+}
+
+type MergeTagsRequest struct {
+	From []string `json:"from" binding:"required,min=1,dive,required"`
+	Into string   `json:"into" binding:"required,max=30"`
+}
+
+type CreateCategoryRequest struct {
+	Name        string `json:"name" binding:"required,max=50"`
+	Description string `json:"description" binding:"max=255"`
+}
+
+// ListTags GET /tags 标签及已发布文章数量
+func (t *TagController) ListTags(c *gin.Context) {
+
+	tags, err := t.tags.List()
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, tags)
+}
+
+// TagPosts GET /tags/:name/posts 带有该标签的已发布文章，支持分页
+func (t *TagController) TagPosts(c *gin.Context) {
+
+	page, size := pageParams(c)
+	posts, total, err := t.tags.Posts(c.Param("name"), page, size)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, utils.NewPageResponse(posts, total, page, size))
+}
+
+// RenameTag PATCH /admin/tags/:name 重命名标签（管理员）
+func (t *TagController) RenameTag(c *gin.Context) {
+
+	var req RenameTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+	tag, err := t.tags.Rename(c.Param("name"), req.Name)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, tag)
+}
+
+// MergeTags POST /admin/tags/merge 把 from 中的标签合并到 into（管理员）
+func (t *TagController) MergeTags(c *gin.Context) {
+
+	var req MergeTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+	tag, err := t.tags.Merge(req.From, req.Into)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, tag)
+}
+
+// ListCategories GET /categories 全部分类
+func (t *TagController) ListCategories(c *gin.Context) {
+
+	categories, err := t.categories.List()
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, categories)
+}
+
+// CategoryPosts GET /categories/:id/posts 该分类下已发布的文章，支持分页
+func (t *TagController) CategoryPosts(c *gin.Context) {
+
+	id, ok := uintParam(c, "id")
+	if !ok {
+		utils.BadRequest(c, "分类ID格式错误（必须为数字）")
+		return
+	}
+	page, size := pageParams(c)
+	posts, total, err := t.categories.Posts(id, page, size)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, utils.NewPageResponse(posts, total, page, size))
+}
+
+// CreateCategory POST /admin/categories 新增分类（管理员）
+func (t *TagController) CreateCategory(c *gin.Context) {
+
+	var req CreateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+	category, err := t.categories.Create(req.Name, req.Description)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Created(c, category)
+}
@@ -2,29 +2,217 @@ package controller
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/jheader/golang_blog/config"
-	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/dto"
+	"github.com/jheader/golang_blog/service"
 	"github.com/jheader/golang_blog/utils"
 )
 
-type User struct{}
+type User struct {
+	users *service.UserService
+	auth  *service.AuthService
+}
+
+func NewUser(users *service.UserService, auth *service.AuthService) *User {
+	return &User{users: users, auth: auth}
+}
+
+// UpdateProfileRequest 只修改请求中出现的字段，传空字符串表示清空（邮箱不能为空）
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name" binding:"omitempty,max=50"`
+	Bio         *string `json:"bio" binding:"omitempty,max=500"`
+	AvatarURL   *string `json:"avatar_url" binding:"omitempty,max=500"`
+	Email       *string `json:"email" binding:"omitempty,email,max=255"`
+}
 
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6,password_strength"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// DisableTOTPRequest 除了密码，还需要验证码或恢复码中的一个
+type DisableTOTPRequest struct {
+	Password     string `json:"password" binding:"required"`
+	Code         string `json:"code" binding:"max=10"`
+	RecoveryCode string `json:"recovery_code" binding:"max=32"`
+}
+
+// GetProfile GET /me 当前登录用户的资料
 func (u *User) GetProfile(c *gin.Context) {
 
-	var user model.User
-	userID, b := c.GetQuery("userID")
-	if b && userID != "" {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "User not authenticated")
+		return
+	}
+	user, err := u.users.Profile(userID)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, dto.NewProfile(*user))
+}
+
+// UpdateProfile PATCH /me 修改显示名称、简介、头像和邮箱
+func (u *User) UpdateProfile(c *gin.Context) {
 
-		if err := config.DB.First(&user, userID).Error; err != nil {
-			utils.UserNotExsit(c)
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "User not authenticated")
+		return
+	}
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+	user, err := u.users.UpdateProfile(userID, service.ProfileUpdate{
+		DisplayName: req.DisplayName,
+		Bio:         req.Bio,
+		AvatarURL:   req.AvatarURL,
+		Email:       req.Email,
+	})
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	// 修改邮箱后需要重新验证
+	if req.Email != nil && !user.IsEmailVerified() {
+		if err := u.auth.ResendVerification(userID); err != nil {
+			utils.RespondError(c, err)
 			return
 		}
+	}
+	utils.Success(c, dto.NewProfile(*user))
+}
+
+// ChangePassword POST /me/password 修改密码，其他设备上的会话全部失效，返回当前会话的新令牌
+func (u *User) ChangePassword(c *gin.Context) {
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "User not authenticated")
+		return
+	}
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+	pair, err := u.auth.ChangePassword(userID, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, map[string]any{
+		"Token":        pair.AccessToken,
+		"RefreshToken": pair.RefreshToken,
+	})
+}
+
+// GetByUsername GET /users/:username 公开的用户资料
+func (u *User) GetByUsername(c *gin.Context) {
+
+	user, err := u.users.PublicProfile(c.Param("username"))
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, dto.NewPublicProfile(*user))
+}
+
+// ResendVerification POST /me/verify-email 重新发送邮箱验证邮件
+func (u *User) ResendVerification(c *gin.Context) {
 
-	} else {
-		utils.InternalServerError(c, "userID 为空")
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "User not authenticated")
+		return
+	}
+	if err := u.auth.ResendVerification(userID); err != nil {
+		utils.RespondError(c, err)
 		return
 	}
+	utils.Success(c, "verification email has been sent")
+}
 
-	utils.Success(c, user)
+// EnrollTOTP POST /me/mfa/totp 生成两步验证的密钥和二维码，确认之前不生效
+func (u *User) EnrollTOTP(c *gin.Context) {
 
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "User not authenticated")
+		return
+	}
+	enrollment, err := u.auth.EnrollTOTP(userID)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, enrollment)
+}
+
+// ConfirmTOTP POST /me/mfa/totp/confirm 提交第一个验证码开启两步验证，返回只显示一次的恢复码
+func (u *User) ConfirmTOTP(c *gin.Context) {
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "User not authenticated")
+		return
+	}
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+	codes, err := u.auth.ConfirmTOTP(userID, req.Code)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, map[string]any{"recovery_codes": codes})
+}
+
+// DisableTOTP DELETE /me/mfa/totp 关闭两步验证，需要输入密码和验证码（或恢复码）
+func (u *User) DisableTOTP(c *gin.Context) {
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "User not authenticated")
+		return
+	}
+	var req DisableTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+	if err := u.auth.DisableTOTP(userID, req.Password, req.Code, req.RecoveryCode); err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, "two-factor authentication has been disabled")
+}
+
+// RegenerateRecoveryCodes POST /me/mfa/recovery-codes 重新生成恢复码，旧的全部失效
+func (u *User) RegenerateRecoveryCodes(c *gin.Context) {
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "User not authenticated")
+		return
+	}
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindError(c, err)
+		return
+	}
+	codes, err := u.auth.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+	utils.Success(c, map[string]any{"recovery_codes": codes})
 }
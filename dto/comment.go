@@ -0,0 +1,58 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/jheader/golang_blog/model"
+)
+
+// Comment 评论，作者只包含 id 和用户名，需要预加载 Comment.User。
+// 已删除的评论只保留位置：内容为 "[deleted]"，不返回作者
+type Comment struct {
+	ID        uint       `json:"id"`
+	Content   string     `json:"content"`
+	PostID    uint       `json:"post_id"`
+	ParentID  *uint      `json:"parent_id"`
+	Author    *Author    `json:"author"`
+	Deleted   bool       `json:"deleted,omitempty"`
+	EditedAt  *time.Time `json:"edited_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CommentNode 评论树中的一个节点，ReplyCount 为直接回复的数量，
+// 超过请求的深度时 Replies 为空，但 ReplyCount 仍然有值，客户端可以按 parent_id 继续加载
+type CommentNode struct {
+	Comment
+	Depth      int            `json:"depth"`
+	ReplyCount int64          `json:"reply_count"`
+	Replies    []*CommentNode `json:"replies"`
+}
+
+func NewComment(c model.Comment) Comment {
+	comment := Comment{
+		ID:        c.ID,
+		Content:   c.Content,
+		PostID:    c.PostID,
+		ParentID:  c.ParentID,
+		EditedAt:  c.EditedAt,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+	if c.IsDeleted() {
+		comment.Content = model.DeletedPlaceholder
+		comment.Deleted = true
+	} else {
+		author := NewAuthor(c.User)
+		comment.Author = &author
+	}
+	return comment
+}
+
+func NewComments(comments []model.Comment) []Comment {
+	list := make([]Comment, 0, len(comments))
+	for _, c := range comments {
+		list = append(list, NewComment(c))
+	}
+	return list
+}
@@ -0,0 +1,148 @@
+package dto
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jheader/golang_blog/model"
+)
+
+// ExcerptLength 列表中文章摘要的最大字符数
+const ExcerptLength = 200
+
+// CategoryRef 文章所属的分类
+type CategoryRef struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// PostSummary 文章列表中的一项：只有摘要和评论数，不包含正文和评论
+type PostSummary struct {
+	ID           uint         `json:"id"`
+	Title        string       `json:"title"`
+	Slug         string       `json:"slug"`
+	Excerpt      string       `json:"excerpt"`
+	Status       string       `json:"status"`
+	PublishAt    *time.Time   `json:"publish_at"`
+	Author       Author       `json:"author"`
+	Category     *CategoryRef `json:"category"`
+	Tags         []string     `json:"tags"`
+	CommentCount int64        `json:"comment_count"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+// PostDetail 文章详情：完整正文和评论
+type PostDetail struct {
+	ID           uint         `json:"id"`
+	Title        string       `json:"title"`
+	Slug         string       `json:"slug"`
+	Content      string       `json:"content"`
+	Version      uint         `json:"version"`
+	Status       string       `json:"status"`
+	PublishAt    *time.Time   `json:"publish_at"`
+	Author       Author       `json:"author"`
+	Category     *CategoryRef `json:"category"`
+	Tags         []string     `json:"tags"`
+	Comments     []Comment    `json:"comments"`
+	CommentCount int64        `json:"comment_count"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+// Revision 文章的一个历史版本，作者只包含 id 和用户名
+type Revision struct {
+	ID        uint      `json:"id"`
+	PostID    uint      `json:"post_id"`
+	Revision  uint      `json:"revision"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Author    Author    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewPostSummary 需要预加载 User、Category 和 Tags，commentCount 由调用方批量统计
+func NewPostSummary(p model.Post, commentCount int64) PostSummary {
+	return PostSummary{
+		ID:           p.ID,
+		Title:        p.Title,
+		Slug:         p.Slug,
+		Excerpt:      Excerpt(p.Content, ExcerptLength),
+		Status:       p.Status,
+		PublishAt:    p.PublishAt,
+		Author:       NewAuthor(p.User),
+		Category:     newCategoryRef(p.Category),
+		Tags:         tagNames(p.Tags),
+		CommentCount: commentCount,
+		CreatedAt:    p.CreatedAt,
+		UpdatedAt:    p.UpdatedAt,
+	}
+}
+
+// NewPostDetail 评论取自 p.Comments，未预加载评论时 comments 为空数组
+func NewPostDetail(p model.Post) PostDetail {
+	return PostDetail{
+		ID:           p.ID,
+		Title:        p.Title,
+		Slug:         p.Slug,
+		Content:      p.Content,
+		Version:      p.Version,
+		Status:       p.Status,
+		PublishAt:    p.PublishAt,
+		Author:       NewAuthor(p.User),
+		Category:     newCategoryRef(p.Category),
+		Tags:         tagNames(p.Tags),
+		Comments:     NewComments(p.Comments),
+		CommentCount: int64(len(p.Comments)),
+		CreatedAt:    p.CreatedAt,
+		UpdatedAt:    p.UpdatedAt,
+	}
+}
+
+// NewRevision 需要预加载 Author
+func NewRevision(r model.PostRevision) Revision {
+	return Revision{
+		ID:        r.ID,
+		PostID:    r.PostID,
+		Revision:  r.Revision,
+		Title:     r.Title,
+		Content:   r.Content,
+		Author:    NewAuthor(r.Author),
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+func NewRevisions(revisions []model.PostRevision) []Revision {
+	out := make([]Revision, 0, len(revisions))
+	for _, r := range revisions {
+		out = append(out, NewRevision(r))
+	}
+	return out
+}
+
+// Excerpt 合并连续空白后截取前 n 个字符，截断时以省略号结尾
+func Excerpt(content string, n int) string {
+
+	text := strings.Join(strings.Fields(content), " ")
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:n])) + "…"
+}
+
+func newCategoryRef(c *model.Category) *CategoryRef {
+	if c == nil {
+		return nil
+	}
+	return &CategoryRef{ID: c.ID, Name: c.Name}
+}
+
+func tagNames(tags []model.Tag) []string {
+	names := make([]string, 0, len(tags))
+	for _, t := range tags {
+		names = append(names, t.Name)
+	}
+	return names
+}
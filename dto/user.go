@@ -0,0 +1,62 @@
+// Package dto 接口响应使用的数据结构，与 model 分开，避免把数据库字段（如密码哈希）直接暴露给客户端
+package dto
+
+import (
+	"time"
+
+	"github.com/jheader/golang_blog/model"
+)
+
+// Author 列表、评论中嵌入的作者信息
+type Author struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+}
+
+func NewAuthor(u model.User) Author {
+	return Author{ID: u.ID, Username: u.Username}
+}
+
+// PublicProfile 任何人都可以查看的用户信息，不包含邮箱、角色等
+type PublicProfile struct {
+	ID          uint      `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Profile 用户自己的资料，包含邮箱、两步验证状态、角色和有效权限
+type Profile struct {
+	PublicProfile
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	MFAEnabled      bool       `json:"mfa_enabled"`
+	Role            string     `json:"role"`
+	Permissions     []string   `json:"permissions"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func NewPublicProfile(u model.User) PublicProfile {
+	return PublicProfile{
+		ID:          u.ID,
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		AvatarURL:   u.AvatarURL,
+		CreatedAt:   u.CreatedAt,
+	}
+}
+
+func NewProfile(u model.User) Profile {
+	return Profile{
+		PublicProfile:   NewPublicProfile(u),
+		Email:           u.Email,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		MFAEnabled:      u.MFAEnabled(),
+		Role:            u.Role,
+		Permissions:     u.EffectivePermissions(),
+		UpdatedAt:       u.UpdatedAt,
+	}
+}
@@ -0,0 +1,56 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FileMailer 把邮件追加写入文件，用于本地开发和测试
+type FileMailer struct {
+	mu   sync.Mutex
+	path string
+	from string
+}
+
+func (m *FileMailer) Send(msg Message) error {
+
+	if err := validHeader(msg.To, msg.Subject); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("写入邮件失败(file: %s):%w", m.path, err)
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "Date: %s\nFrom: %s\nTo: %s\nSubject: %s\n\n%s\n\n----------\n",
+		time.Now().Format(time.RFC3339), m.from, msg.To, msg.Subject, msg.Body)
+	if err != nil {
+		return fmt.Errorf("写入邮件失败(file: %s):%w", m.path, err)
+	}
+	return nil
+}
+
+// LogMailer 把邮件写入日志，不真正发送
+type LogMailer struct {
+	from string
+}
+
+func (m *LogMailer) Send(msg Message) error {
+
+	if err := validHeader(msg.To, msg.Subject); err != nil {
+		return err
+	}
+	logrus.WithFields(logrus.Fields{
+		"from":    m.from,
+		"to":      msg.To,
+		"subject": msg.Subject,
+	}).Info("mail: ", msg.Body)
+	return nil
+}
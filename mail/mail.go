@@ -0,0 +1,76 @@
+// Package mail 发送邮件。生产环境使用 SMTP，本地开发可以把邮件写到文件或日志中，
+// 从中复制邮箱验证、重置密码的链接
+package mail
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 邮件发送方式
+const (
+	DriverSMTP = "smtp"
+	DriverFile = "file"
+	DriverLog  = "log"
+)
+
+// Message 一封纯文本邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer 发送邮件
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Config 邮件配置，由 main 从环境变量读取
+type Config struct {
+	Driver string
+	From   string
+	// File driver 为 file 时邮件追加写入的文件
+	File string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// New 按 cfg.Driver 创建 Mailer
+func New(cfg Config) (Mailer, error) {
+
+	switch strings.ToLower(cfg.Driver) {
+	case DriverSMTP:
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required when MAIL_DRIVER is smtp")
+		}
+		return &SMTPMailer{
+			host:     cfg.SMTPHost,
+			port:     cfg.SMTPPort,
+			username: cfg.SMTPUsername,
+			password: cfg.SMTPPassword,
+			from:     cfg.From,
+		}, nil
+	case DriverFile:
+		if cfg.File == "" {
+			return nil, fmt.Errorf("MAIL_FILE is required when MAIL_DRIVER is file")
+		}
+		return &FileMailer{path: cfg.File, from: cfg.From}, nil
+	case DriverLog, "":
+		return &LogMailer{from: cfg.From}, nil
+	}
+	return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+}
+
+// validHeader 收件人和标题不能包含换行，防止邮件头注入
+func validHeader(values ...string) error {
+	for _, v := range values {
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("mail header contains line break: %q", v)
+		}
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/jheader/golang_blog/utils"
 	"github.com/sirupsen/logrus"
 )
 
@@ -12,15 +13,13 @@ func ErrorHandleMiddleWare() gin.HandlerFunc {
 			if err := recover(); err != nil {
 
 				logrus.WithFields(logrus.Fields{
-					"error":  err,
-					"path":   ctx.Request.URL.Path,
-					"method": ctx.Request.Method,
+					"error":      err,
+					"path":       ctx.Request.URL.Path,
+					"method":     ctx.Request.Method,
+					"request_id": ctx.GetString(utils.RequestIDKey),
 				}).Error("Panic recovered")
 
-				ctx.JSON(500, gin.H{
-					"code":    500,
-					"message": "Internal server error",
-				})
+				utils.InternalServerError(ctx, "Internal server error")
 				ctx.Abort()
 			}
 		}()
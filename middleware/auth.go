@@ -1,44 +1,112 @@
 package middleware
 
 import (
+	"errors"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/repository"
 	"github.com/jheader/golang_blog/utils"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// errTokenCheck 查询吊销记录失败，属于服务端错误而不是令牌无效
+var errTokenCheck = errors.New("Failed to verify token")
 
-	return func(ctx *gin.Context) {
-
-		authHeader := ctx.GetHeader("Authorization")
+// AuthMiddleware 校验访问令牌，并确认用户仍然存在、未被停用且不需要重置密码：
+// 账号被删除、停用或被要求重置密码后，尚未过期的令牌也会立即失效
+func AuthMiddleware(tokens repository.TokenRepository, users repository.UserRepository) gin.HandlerFunc {
 
-		if authHeader == "" {
-			utils.Unauthorized(ctx, "Authorization header is required")
-			ctx.Abort()
-			return
-		}
+	return func(ctx *gin.Context) {
 
-		// 检查Bearer前缀
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			utils.Unauthorized(ctx, "Bearer token is required")
-			ctx.Abort()
-			return
-		}
-		claims, err := utils.ParseToken(tokenString)
+		user, err := authenticate(ctx, tokens, users)
 		if err != nil {
-			utils.Unauthorized(ctx, err.Error())
+			var appErr *utils.AppError
+			if errors.As(err, &appErr) {
+				utils.RespondError(ctx, err)
+			} else if errors.Is(err, errTokenCheck) {
+				utils.InternalServerError(ctx, err.Error())
+			} else {
+				utils.Unauthorized(ctx, err.Error())
+			}
 			ctx.Abort()
 			return
 		}
 
 		// 将用户信息存储到上下文中
-		ctx.Set("user_id", claims.UserID)
-		ctx.Set("current_username", claims.Username)
+		setUser(ctx, user)
 
 		ctx.Next()
 
 	}
 
 }
+
+// OptionalAuthMiddleware 用于公开路由：携带有效令牌时写入用户信息，否则按匿名用户处理，不会拒绝请求
+func OptionalAuthMiddleware(tokens repository.TokenRepository, users repository.UserRepository) gin.HandlerFunc {
+
+	return func(ctx *gin.Context) {
+
+		if user, err := authenticate(ctx, tokens, users); err == nil {
+			setUser(ctx, user)
+		}
+		ctx.Next()
+	}
+}
+
+// setUser 角色和权限以数据库为准，管理员修改角色后不必等用户刷新令牌
+func setUser(ctx *gin.Context, u *model.User) {
+	ctx.Set("user_id", u.ID)
+	ctx.Set("current_username", u.Username)
+	ctx.Set("role", u.Role)
+	ctx.Set("permissions", u.EffectivePermissions())
+	ctx.Set("email_verified", u.IsEmailVerified())
+}
+
+// authenticate 解析 Bearer 令牌，检查是否已被吊销（登出），再加载令牌对应的用户
+func authenticate(ctx *gin.Context, tokens repository.TokenRepository, users repository.UserRepository) (*model.User, error) {
+
+	authHeader := ctx.GetHeader("Authorization")
+	if authHeader == "" {
+		return nil, errors.New("Authorization header is required")
+	}
+
+	// 检查Bearer前缀
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		return nil, errors.New("Bearer token is required")
+	}
+	claims, err := utils.ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := tokens.IsAccessTokenRevoked(claims.ID)
+	if err != nil {
+		return nil, errTokenCheck
+	}
+	if revoked {
+		return nil, errors.New("token has been revoked")
+	}
+
+	u, err := users.FindByID(claims.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, errors.New("user no longer exists")
+		}
+		return nil, errTokenCheck
+	}
+	// iat 只精确到秒，与修改时间比较时截断到秒，修改密码时为当前会话签发的新令牌不会被误判
+	if u.PasswordChangedAt != nil && claims.IssuedAt != nil &&
+		claims.IssuedAt.Time.Before(u.PasswordChangedAt.Truncate(time.Second)) {
+		return nil, errors.New("token was issued before the password was changed")
+	}
+	if u.IsDisabled() {
+		return nil, utils.NewAppError(utils.ErrForbidden, "account_disabled", "account has been disabled")
+	}
+	if u.MustResetPassword {
+		return nil, utils.NewAppError(utils.ErrForbidden, "password_reset_required", "password must be reset")
+	}
+	return u, nil
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jheader/golang_blog/utils"
 	"github.com/sirupsen/logrus"
 )
 
@@ -23,6 +24,7 @@ func LoggerMiddleWare() gin.HandlerFunc {
 			"user_agent":  param.Request.UserAgent(),
 			"error":       param.ErrorMessage,
 			"timestamp":   param.TimeStamp.Format(time.RFC3339),
+			"request_id":  param.Keys[utils.RequestIDKey],
 		}).Info("HTTP Request")
 		return ""
 	})
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jheader/golang_blog/utils"
+)
+
+// RequirePermission 只允许拥有 permission 的用户访问，例如 RequirePermission("posts:delete:any")，
+// 权限由 AuthMiddleware 从数据库中的用户读取，需放在 AuthMiddleware 之后
+func RequirePermission(permission string) gin.HandlerFunc {
+
+	return func(ctx *gin.Context) {
+
+		if !HasPermission(ctx, permission) {
+			utils.Forbidden(ctx, "permission required: "+permission)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// HasPermission 当前用户是否拥有某个权限，未认证时返回 false
+func HasPermission(ctx *gin.Context, permission string) bool {
+	return slices.Contains(Permissions(ctx), permission)
+}
+
+// Permissions 当前用户的有效权限
+func Permissions(ctx *gin.Context) []string {
+	return ctx.GetStringSlice("permissions")
+}
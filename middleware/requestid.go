@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jheader/golang_blog/utils"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// RequestIDMiddleware 为每个请求分配请求ID，沿用客户端传入的 X-Request-ID，
+// 写入上下文供响应体和日志使用，并通过响应头回传
+func RequestIDMiddleware() gin.HandlerFunc {
+
+	return func(ctx *gin.Context) {
+		requestID := ctx.GetHeader(requestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID, _ = utils.RandomToken(12)
+		}
+		ctx.Set(utils.RequestIDKey, requestID)
+		ctx.Header(requestIDHeader, requestID)
+		ctx.Next()
+	}
+}
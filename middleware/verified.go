@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jheader/golang_blog/utils"
+	"github.com/spf13/viper"
+)
+
+// RequireVerifiedEmail 配置 REQUIRE_VERIFIED_EMAIL=true 时，邮箱未验证的用户不能访问，
+// 用于发表文章和评论；未开启时直接放行。需放在 AuthMiddleware 之后
+func RequireVerifiedEmail() gin.HandlerFunc {
+
+	required := viper.GetBool("REQUIRE_VERIFIED_EMAIL")
+	return func(ctx *gin.Context) {
+
+		if required && !ctx.GetBool("email_verified") {
+			utils.RespondError(ctx, utils.NewAppError(utils.ErrForbidden, "email_not_verified", "please verify your email first"))
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
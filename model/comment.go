@@ -7,14 +7,24 @@ import (
 )
 
 type Comment struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
+	ID        uint           `json:"id" gorm:"primaryKey;index:idx_comment_post_created_at_id,priority:3"`
 	Content   string         `json:"content" gorm:"type:text;not null"`
 	UserID    uint           `json:"user_id" gorm:"not null"`
-	PostID    uint           `json:"post_id" gorm:"not null"`
-	CreatedAt time.Time      `json:"created_at"`
+	PostID    uint           `json:"post_id" gorm:"not null;index:idx_comment_post_created_at_id,priority:1"`
+	ParentID  *uint          `json:"parent_id" gorm:"index"`                                            // 回复的评论，为空表示直接评论文章
+	CreatedAt time.Time      `json:"created_at" gorm:"index:idx_comment_post_created_at_id,priority:2"` // 与 post_id、id 组成游标分页的索引
 	UpdatedAt time.Time      `json:"updated_at"`
+	EditedAt  *time.Time     `json:"edited_at"` // 最后一次修改内容的时间，从未修改过为空
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
 	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	Post Post `json:"post,omitempty" gorm:"foreignKey:PostID"`
 }
+
+// DeletedPlaceholder 已删除但仍有回复的评论在评论树中显示的内容
+const DeletedPlaceholder = "[deleted]"
+
+// IsDeleted 评论是否已被（软）删除
+func (c *Comment) IsDeleted() bool {
+	return c.DeletedAt.Valid
+}
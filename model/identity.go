@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// UserIdentity 外部身份提供方（OIDC）的账号与本站用户的关联，同一提供方的 subject 只能关联一个用户
+type UserIdentity struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	UserID   uint   `json:"user_id" gorm:"not null;index"`
+	Provider string `json:"provider" gorm:"not null;size:50;uniqueIndex:idx_identity_provider_subject"`
+	// Subject 提供方 ID Token 中的 sub，提供方内唯一且不会变化
+	Subject     string     `json:"subject" gorm:"not null;size:255;uniqueIndex:idx_identity_provider_subject"`
+	Email       string     `json:"email" gorm:"size:255"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// RecoveryCodeCount 开启两步验证时生成的恢复码数量
+const RecoveryCodeCount = 10
+
+// RecoveryCode 两步验证的恢复码，丢失验证器时代替 TOTP 验证码登录，每个只能使用一次。
+// 只保存哈希，明文只在生成时返回一次
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"not null;size:64;uniqueIndex"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
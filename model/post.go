@@ -6,21 +6,44 @@ import (
 	"gorm.io/gorm"
 )
 
+// 文章状态：草稿和定时发布的文章只有作者可见，到达 publish_at 后由调度器改为已发布
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+	PostStatusArchived  = "archived"
+)
+
 type Post struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Title     string         `json:"title" gorm:"not null;size 100"`
-	Content   string         `json:"content" gorm:"type:text;not null"`
-	UserID    uint           `json:"user_id" gorm:"not null"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID         uint           `json:"id" gorm:"primaryKey;index:idx_post_created_at_id,priority:2"`
+	Title      string         `json:"title" gorm:"not null;size 100"`
+	Slug       string         `json:"slug" gorm:"size:191;default:null;uniqueIndex"` // 由标题生成，修改标题后旧 slug 记录在 PostSlug
+	Content    string         `json:"content" gorm:"type:text;not null"`
+	UserID     uint           `json:"user_id" gorm:"not null"`
+	Version    uint           `json:"version" gorm:"not null;default:1"` // 乐观锁版本号，每次更新加一
+	Status     string         `json:"status" gorm:"size:20;not null;default:published;index:idx_post_status_publish_at"`
+	PublishAt  *time.Time     `json:"publish_at" gorm:"index:idx_post_status_publish_at"` // 发布时间，定时发布时为计划时间
+	CategoryID *uint          `json:"category_id" gorm:"index"`
+	CreatedAt  time.Time      `json:"created_at" gorm:"index:idx_post_created_at_id,priority:1"` // 与 id 组成游标分页的排序键
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
 
 	User     User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Category *Category `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
+	Tags     []Tag     `json:"tags,omitempty" gorm:"many2many:post_tags"` // 为 nil 时更新文章不修改标签
 	Comments []Comment `json:"comments,omitempty" gorm:"foreignKey:PostID"`
 }
 
-// 如果没有错误信息 就是保存更新成，更新是有ID加自己文章才能更新
-func (p *Post) SaveOrUpdate(db *gorm.DB) error {
+// ValidPostStatus 检查状态是否合法
+func ValidPostStatus(status string) bool {
+	switch status {
+	case PostStatusDraft, PostStatusScheduled, PostStatusPublished, PostStatusArchived:
+		return true
+	}
+	return false
+}
 
-	return nil
+// IsPublished 已发布的文章才会出现在公开接口中
+func (p *Post) IsPublished() bool {
+	return p.Status == PostStatusPublished
 }
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+)
+
+// PostRevision 文章的历史版本。文章每次创建或更新都会保存一份快照，
+// Revision 与保存时文章的 Version 相同
+type PostRevision struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	PostID    uint      `json:"post_id" gorm:"not null;uniqueIndex:idx_post_revision"`
+	Revision  uint      `json:"revision" gorm:"not null;uniqueIndex:idx_post_revision"`
+	Title     string    `json:"title" gorm:"not null;size:100"`
+	Content   string    `json:"content" gorm:"type:text;not null"`
+	AuthorID  uint      `json:"author_id" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Author User `json:"author,omitempty" gorm:"foreignKey:AuthorID"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import "slices"
+
+// 用户角色：普通用户只能管理自己的内容，版主可以处理任何评论，管理员拥有全部权限
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// 权限，格式为 资源:操作[:范围]，"any" 表示可以操作其他人的内容。
+// 修改、删除自己的文章和评论不需要权限
+const (
+	PermPostsUpdateAny    = "posts:update:any"
+	PermPostsDeleteAny    = "posts:delete:any"
+	PermCommentsUpdateAny = "comments:update:any"
+	PermCommentsDeleteAny = "comments:delete:any"
+	PermTagsManage        = "tags:manage"
+	PermUsersManage       = "users:manage"
+)
+
+// AllPermissions 系统中定义的全部权限
+var AllPermissions = []string{
+	PermPostsUpdateAny,
+	PermPostsDeleteAny,
+	PermCommentsUpdateAny,
+	PermCommentsDeleteAny,
+	PermTagsManage,
+	PermUsersManage,
+}
+
+// RolePermissions 每个角色默认拥有的权限
+var RolePermissions = map[string][]string{
+	RoleUser:      {},
+	RoleModerator: {PermCommentsDeleteAny},
+	RoleAdmin:     AllPermissions,
+}
+
+// ValidRole 检查角色是否合法
+func ValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// ValidPermission 检查权限是否已定义
+func ValidPermission(permission string) bool {
+	return slices.Contains(AllPermissions, permission)
+}
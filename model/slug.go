@@ -0,0 +1,14 @@
+package model
+
+import (
+	"time"
+)
+
+// PostSlug 文章用过的旧 slug，通过旧 slug 访问时 301 跳转到当前 slug。
+// 旧 slug 不会再分配给其他文章，保证已经发出去的链接不会指向别的内容
+type PostSlug struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	PostID    uint      `json:"post_id" gorm:"not null;index"`
+	Slug      string    `json:"slug" gorm:"size:191;not null;uniqueIndex"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+)
+
+// RefreshToken 刷新令牌，只保存哈希值。
+// 同一次登录产生的令牌共享 FamilyID，每次刷新都会轮换出新令牌并吊销旧令牌，
+// 已吊销的令牌再次被使用时视为泄露，整个 family 一并吊销
+type RefreshToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash  string     `json:"-" gorm:"uniqueIndex;not null;size:64"`
+	FamilyID   string     `json:"family_id" gorm:"not null;index;size:64"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	ReplacedBy *uint      `json:"replaced_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// RevokedToken 被提前吊销的访问令牌(jti)，过期后即可清理
+type RevokedToken struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	JTI       string    `json:"jti" gorm:"uniqueIndex;not null;size:64"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 一次性令牌的用途，mfa_pending 为密码校验通过、等待提交两步验证码的登录
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+	TokenPurposeMFAPending    = "mfa_pending"
+)
+
+// ActionToken 通过邮件发出的一次性令牌（邮箱验证、重置密码）。令牌本身是签名的 JWT，
+// 这里只记录 jti 和使用时间，保证每个令牌只能使用一次
+type ActionToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	JTI       string     `json:"-" gorm:"uniqueIndex;not null;size:64"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	Purpose   string     `json:"purpose" gorm:"size:20;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (t *RefreshToken) IsActive(now time.Time) bool {
+	return t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
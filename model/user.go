@@ -1,84 +1,144 @@
 package model
 
 import (
-	"errors"
 	"fmt"
+	"slices"
 	"time"
 
+	"github.com/jheader/golang_blog/utils"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// GhostUsername 用户被删除后，保留下来的文章和评论归属于这个占位用户
+const GhostUsername = "[deleted]"
+
 type User struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Username  string         `json:"username" gorm:"uniqueIndex; not null; size:50"`
-	Password  string         `json:"password,omitempty" gorm:"not null"`
-	Email     string         `json:"email" gorm:"uniqueIndex;not null;size:255"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Username    string         `json:"username" gorm:"uniqueIndex; not null; size:50"`
+	Password    string         `json:"-" gorm:"not null"` // bcrypt 哈希，任何响应都不能包含
+	Email       string         `json:"email" gorm:"uniqueIndex;not null;size:255"`
+	DisplayName string         `json:"display_name" gorm:"size:50"`
+	Bio         string         `json:"bio" gorm:"size:500"`
+	AvatarURL   string         `json:"avatar_url" gorm:"size:500"`
+	Role        string         `json:"role" gorm:"size:20;not null;default:user"`
+	Permissions []string       `json:"permissions,omitempty" gorm:"serializer:json;type:text"` // 在角色之外单独授予的权限
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// 用户名、邮箱的规范形式（utils.CanonicalIdentity），唯一索引建在这两列上，大小写不同的用户名视为重复。
+	// 上线前已存在且互相冲突的用户此列为空，迁移时报告，见 repository.BackfillIdentities
+	UsernameCanonical *string `json:"-" gorm:"size:50;uniqueIndex"`
+	EmailCanonical    *string `json:"-" gorm:"size:255;uniqueIndex"`
+
+	// 登录失败次数与锁定截止时间，持久化保存，服务重启后锁定依然有效
+	FailedLoginAttempts int        `json:"-" gorm:"not null;default:0"`
+	LockedUntil         *time.Time `json:"-"`
+
+	// 邮箱验证通过的时间，修改邮箱后清空
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+
+	// 最后一次修改密码的时间，在此之前签发的访问令牌失效
+	PasswordChangedAt *time.Time `json:"-"`
+
+	// TOTP 两步验证：TOTPSecret 在开启流程中生成，TOTPEnabledAt 不为空表示已确认开启；
+	// TOTPLastStep 为最后一次使用的时间步，同一个验证码不能使用两次
+	TOTPSecret    string     `json:"-" gorm:"size:64"`
+	TOTPEnabledAt *time.Time `json:"-"`
+	TOTPLastStep  int64      `json:"-" gorm:"not null;default:0"`
+
+	// 管理员停用账号的时间，停用后已签发的令牌也会被拒绝
+	DisabledAt *time.Time `json:"disabled_at"`
+	// 管理员要求重置密码，重置之前不能登录
+	MustResetPassword bool `json:"must_reset_password" gorm:"not null;default:false"`
 
 	Posts    []Post    `json:"posts,omitempty"`
 	Comments []Comment `json:"comments,omitempty"`
 }
 
-func GetUserByMap(mp map[string]interface{}, db *gorm.DB) ([]User, error) {
-
-	var users []User
-	result := db.Find(&users, mp)
-	if result.Error != nil {
-		return nil, fmt.Errorf("查询失败：%w", result.Error)
+func (u *User) HashPassword() error {
+	// 1. 检查明文密码是否为空（避免哈希空字符串）
+	if u.Password == "" {
+		return fmt.Errorf("password cannot be empty")
+	}
 
+	// 2. 生成 bcrypt 盐值（cost 越大越安全，但耗时更长，推荐 10-14）
+	salt, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err) // 包装错误，保留原始错误信息
 	}
-	return users, nil
 
+	// 3. 将哈希后的密码（字节切片）转为字符串，覆盖原明文密码
+	u.Password = string(salt)
+	return nil
 }
 
-// result.Error == nil 只能说明「查询执行成功（无数据库异常）」，
-// 但不能 100% 等同于「查询到了数据」—— 仅当使用 First/Take/Last 这类「查询单条记录」的方法时，
-// result.Error == nil 才意味着「查询到了数据」；若使用 Find（查询多条）或 Count（统计数量），则需要额外判断结果长度 / 数量
-func GetUserByUsername(username string, db *gorm.DB) (*User, error) {
+// CheckPassword 比较明文密码与数据库中保存的 bcrypt 哈希
+func (u *User) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)) == nil
+}
+
+// EffectivePermissions 角色的默认权限加上单独授予的权限，已去重
+func (u *User) EffectivePermissions() []string {
 
-	var u User
-	result := db.Where("username = ?", username).First(&u)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("用户不存在(username: %s):%w", username, result.Error)
+	role := u.Role
+	if role == "" {
+		role = RoleUser
+	}
+	perms := slices.Clone(RolePermissions[role])
+	for _, p := range u.Permissions {
+		if !slices.Contains(perms, p) {
+			perms = append(perms, p)
 		}
-		return nil, fmt.Errorf("查询用户失败(username: %s):%w", username, result.Error)
 	}
-	return &u, nil
+	slices.Sort(perms)
+	return perms
 }
 
-func (u *User) Save(db *gorm.DB) error {
+// HasPermission 用户是否拥有某个权限
+func (u *User) HasPermission(permission string) bool {
+	return slices.Contains(u.EffectivePermissions(), permission)
+}
 
-	result := db.Create(u)
-	if result.Error != nil {
-		return fmt.Errorf("创建(sername: %s):%w", (*u).Username, result.Error)
-	}
+// IsEmailVerified 邮箱是否已验证
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
 
-	return nil
+// MFAEnabled 是否已开启两步验证
+func (u *User) MFAEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
 
+// IsDisabled 账号是否已被管理员停用
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
 }
 
-func (u *User) HashPassword() error {
-	// 1. 检查明文密码是否为空（避免哈希空字符串）
-	if u.Password == "" {
-		return fmt.Errorf("password cannot be empty")
-	}
+// IsLocked 账号是否处于锁定期内
+func (u *User) IsLocked(now time.Time) bool {
+	return u.LockedUntil != nil && now.Before(*u.LockedUntil)
+}
 
-	// 2. 生成 bcrypt 盐值（cost 越大越安全，但耗时更长，推荐 10-14）
-	salt, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err) // 包装错误，保留原始错误信息
+// ClearLoginFailures 登录成功后清空失败计数和锁定状态，返回是否有变化
+func (u *User) ClearLoginFailures() bool {
+	if u.FailedLoginAttempts == 0 && u.LockedUntil == nil {
+		return false
 	}
+	u.FailedLoginAttempts = 0
+	u.LockedUntil = nil
+	return true
+}
 
-	// 3. 将哈希后的密码（字节切片）转为字符串，覆盖原明文密码
-	u.Password = string(salt)
-	return nil
+// Canonicalize 根据用户名和邮箱计算规范形式，创建用户和修改邮箱时调用
+func (u *User) Canonicalize() {
+	username, email := utils.CanonicalIdentity(u.Username), utils.CanonicalIdentity(u.Email)
+	u.UsernameCanonical, u.EmailCanonical = &username, &email
 }
 
-// BeforeCreate GORM钩子，在创建用户前自动哈希密码
+// BeforeCreate GORM钩子，在创建用户前自动哈希密码并计算用户名、邮箱的规范形式
 func (u *User) BeforeCreate(tx *gorm.DB) error {
+	u.Canonicalize()
 	return u.HashPassword()
 }
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/utils"
+	"gorm.io/gorm"
+)
+
+type gormCommentRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormCommentRepository) FindByID(id uint) (*model.Comment, error) {
+
+	var comment model.Comment
+	if err := r.db.Preload("User").First(&comment, id).Error; err != nil {
+		return nil, fmt.Errorf("查询评论失败(id: %d):%w", id, translate(err))
+	}
+	return &comment, nil
+}
+
+func (r *gormCommentRepository) ListByPost(postID uint, q utils.ListQuery, page, size int) ([]model.Comment, int64, error) {
+
+	query := r.db.Model(&model.Comment{}).Where("post_id = ?", postID).Scopes(q.FilterScope())
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计评论总数失败：%w", err)
+	}
+
+	//分页查询
+	var comments []model.Comment
+	if err := query.Preload("User").Scopes(q.SortScope(), utils.Paginate(page, size)).Find(&comments).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询评论列表失败：%w", err)
+	}
+	return comments, total, nil
+}
+
+func (r *gormCommentRepository) ListByPostCursor(postID uint, q utils.ListQuery, cq utils.CursorQuery) ([]model.Comment, error) {
+
+	var comments []model.Comment
+	err := r.db.Preload("User").Where("post_id = ?", postID).Scopes(q.FilterScope(), cq.Scope()).Find(&comments).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询评论列表失败：%w", err)
+	}
+	return comments, nil
+}
+
+// visibleInThread 只保留在楼中楼视图里可见的候选评论：未删除，或已删除但任意深度的回复中还有未删除的（显示为占位）。
+// cond 是候选评论的查询条件，递归 CTE 从候选评论向下展开整棵子树，子树中有未删除评论的候选评论可见
+func visibleInThread(cond string, args ...interface{}) func(db *gorm.DB) *gorm.DB {
+
+	return func(db *gorm.DB) *gorm.DB {
+		visible := db.Session(&gorm.Session{NewDB: true}).Raw(`WITH RECURSIVE subtree (root_id, id, deleted_at) AS (
+	SELECT id, id, deleted_at FROM comments WHERE `+cond+`
+	UNION ALL
+	SELECT s.root_id, c.id, c.deleted_at FROM comments c JOIN subtree s ON c.parent_id = s.id
+)
+SELECT DISTINCT root_id FROM subtree WHERE deleted_at IS NULL`, args...)
+		return db.Where(cond, args...).Where("comments.id IN (?)", visible)
+	}
+}
+
+func (r *gormCommentRepository) ListThreads(postID uint, parentID *uint, page, size int) ([]model.Comment, int64, error) {
+
+	query := r.db.Unscoped().Model(&model.Comment{})
+	if parentID != nil {
+		query = query.Scopes(visibleInThread("post_id = ? AND parent_id = ?", postID, *parentID))
+	} else {
+		query = query.Scopes(visibleInThread("post_id = ? AND parent_id IS NULL", postID))
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计评论总数失败：%w", err)
+	}
+	var comments []model.Comment
+	err := query.Preload("User").Order("created_at, id").Scopes(utils.Paginate(page, size)).Find(&comments).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询评论列表失败：%w", err)
+	}
+	return comments, total, nil
+}
+
+func (r *gormCommentRepository) ListReplies(parentIDs []uint) ([]model.Comment, error) {
+
+	if len(parentIDs) == 0 {
+		return nil, nil
+	}
+	var comments []model.Comment
+	err := r.db.Unscoped().Preload("User").
+		Scopes(visibleInThread("parent_id IN ?", parentIDs)).Order("created_at, id").Find(&comments).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询评论回复失败：%w", err)
+	}
+	return comments, nil
+}
+
+func (r *gormCommentRepository) CountReplies(ids []uint) (map[uint]int64, error) {
+
+	counts := make(map[uint]int64, len(ids))
+	if len(ids) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		ParentID uint
+		Count    int64
+	}
+	err := r.db.Unscoped().Model(&model.Comment{}).Select("parent_id, COUNT(*) AS count").
+		Scopes(visibleInThread("parent_id IN ?", ids)).Group("parent_id").Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("统计评论回复数失败：%w", err)
+	}
+	for _, row := range rows {
+		counts[row.ParentID] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *gormCommentRepository) Create(c *model.Comment) error {
+
+	if err := r.db.Create(c).Error; err != nil {
+		return fmt.Errorf("创建评论失败：%w", err)
+	}
+	return nil
+}
+
+func (r *gormCommentRepository) UpdateContent(id uint, content string, editedAt time.Time) error {
+
+	result := r.db.Model(&model.Comment{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"content": content, "edited_at": editedAt})
+	if result.Error != nil {
+		return fmt.Errorf("修改评论失败(id: %d):%w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("修改评论失败(id: %d):%w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func (r *gormCommentRepository) Delete(id uint) error {
+
+	result := r.db.Delete(&model.Comment{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除评论失败(id: %d):%w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("删除评论失败(id: %d):%w", id, ErrNotFound)
+	}
+	return nil
+}
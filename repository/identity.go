@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/utils"
+	"gorm.io/gorm"
+)
+
+// IdentityCollision 规范形式相同的一组用户，例如 "Alice" 和 "alice"。
+// KeptID（最早注册的用户）获得规范形式，其余用户的规范列保持为空，只能用原始用户名/邮箱精确登录，需要管理员处理
+type IdentityCollision struct {
+	Column    string // username 或 email
+	Canonical string
+	UserIDs   []uint
+	KeptID    uint
+}
+
+func (c IdentityCollision) String() string {
+	return fmt.Sprintf("%s %q is shared by users %v, kept for user %d", c.Column, c.Canonical, c.UserIDs, c.KeptID)
+}
+
+// BackfillIdentities 为规范列上线前创建的用户填充用户名、邮箱的规范形式，迁移时调用。
+// 遇到冲突不会失败，而是跳过冲突的用户并返回冲突列表，由调用方报告
+func BackfillIdentities(db *gorm.DB) ([]IdentityCollision, error) {
+
+	var collisions []IdentityCollision
+	for _, column := range []string{"username", "email"} {
+		found, err := backfillIdentity(db, column)
+		if err != nil {
+			return nil, err
+		}
+		collisions = append(collisions, found...)
+	}
+	return collisions, nil
+}
+
+// identityLookupBatch 查询已占用规范形式的用户时每批的数量，避免 IN 列表过长
+const identityLookupBatch = 500
+
+// backfillIdentity 只处理规范列为空的用户：规范列上线后注册的用户在创建时已经填好，
+// 正常启动时这里查不到任何行；冲突中未获得规范形式的用户保持为空，在管理员处理前每次启动都会再报告
+func backfillIdentity(db *gorm.DB, column string) ([]IdentityCollision, error) {
+
+	canonicalColumn := column + "_canonical"
+	var users []model.User
+	// 已删除的用户也要计算，唯一索引对软删除的行同样生效
+	err := db.Unscoped().Select("id", column).Where(canonicalColumn + " IS NULL").Order("id").Find(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询用户失败：%w", err)
+	}
+	if len(users) == 0 {
+		return nil, nil
+	}
+
+	value := func(u model.User) string {
+		if column == "username" {
+			return u.Username
+		}
+		return u.Email
+	}
+
+	// 按规范形式分组
+	groups := make(map[string][]uint)
+	var order []string
+	for _, u := range users {
+		canonical := utils.CanonicalIdentity(value(u))
+		if _, ok := groups[canonical]; !ok {
+			order = append(order, canonical)
+		}
+		groups[canonical] = append(groups[canonical], u.ID)
+	}
+
+	// 已有规范形式的用户优先占用
+	owners := make(map[string]uint)
+	for batch := range slices.Chunk(order, identityLookupBatch) {
+		var owned []struct {
+			ID        uint
+			Canonical string
+		}
+		err := db.Unscoped().Model(&model.User{}).Select("id", canonicalColumn+" AS canonical").
+			Where(canonicalColumn+" IN ?", batch).Find(&owned).Error
+		if err != nil {
+			return nil, fmt.Errorf("查询%s失败：%w", canonicalColumn, err)
+		}
+		for _, o := range owned {
+			owners[o.Canonical] = o.ID
+		}
+	}
+
+	var collisions []IdentityCollision
+	for _, canonical := range order {
+		ids := groups[canonical]
+		owner, owned := owners[canonical]
+		if owned {
+			ids = append([]uint{owner}, ids...)
+		} else {
+			owner = ids[0]
+			err := db.Unscoped().Model(&model.User{}).Where("id = ?", owner).UpdateColumn(canonicalColumn, canonical).Error
+			if err != nil {
+				return nil, fmt.Errorf("填充%s失败(id: %d)：%w", canonicalColumn, owner, err)
+			}
+		}
+		if len(ids) > 1 {
+			slices.Sort(ids)
+			collisions = append(collisions, IdentityCollision{Column: column, Canonical: canonical, UserIDs: ids, KeptID: owner})
+		}
+	}
+	return collisions, nil
+}
@@ -0,0 +1,1494 @@
+package repository
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/utils"
+	"gorm.io/gorm"
+)
+
+// memoryStore 内存仓储共用的数据，所有读写都在同一把锁下进行，
+// 返回给调用方的都是副本，调用方修改后需要显式调用仓储方法保存
+type memoryStore struct {
+	mu sync.Mutex
+
+	nextIDs       map[string]uint
+	users         map[uint]model.User
+	posts         map[uint]model.Post
+	comments      map[uint]model.Comment
+	refreshTokens map[uint]model.RefreshToken
+	revokedJTIs   map[string]time.Time
+	actionTokens  map[string]model.ActionToken // jti -> token
+	recoveryCodes map[uint]model.RecoveryCode
+	identities    map[uint]model.UserIdentity
+	revisions     map[uint]model.PostRevision
+	oldSlugs      map[string]model.PostSlug
+	tags          map[uint]model.Tag
+	categories    map[uint]model.Category
+	postTags      map[uint][]uint // post_id -> tag_id
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		nextIDs:       make(map[string]uint),
+		users:         make(map[uint]model.User),
+		posts:         make(map[uint]model.Post),
+		comments:      make(map[uint]model.Comment),
+		refreshTokens: make(map[uint]model.RefreshToken),
+		revokedJTIs:   make(map[string]time.Time),
+		actionTokens:  make(map[string]model.ActionToken),
+		recoveryCodes: make(map[uint]model.RecoveryCode),
+		identities:    make(map[uint]model.UserIdentity),
+		revisions:     make(map[uint]model.PostRevision),
+		oldSlugs:      make(map[string]model.PostSlug),
+		tags:          make(map[uint]model.Tag),
+		categories:    make(map[uint]model.Category),
+		postTags:      make(map[uint][]uint),
+	}
+}
+
+// newID 按表分别自增，与数据库主键的行为一致
+func (s *memoryStore) newID(table string) uint {
+	s.nextIDs[table]++
+	return s.nextIDs[table]
+}
+
+// paginate 与 utils.Paginate 保持一致的页码规则
+func paginate[T any](items []T, page, size int) []T {
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 {
+		size = 10
+	}
+	start := (page - 1) * size
+	if start >= len(items) {
+		return []T{}
+	}
+	end := start + size
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[start:end]
+}
+
+func sortedValues[T any](m map[uint]T) []T {
+	ids := make([]uint, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	values := make([]T, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, m[id])
+	}
+	return values
+}
+
+// applyListQuery 在内存中执行 ListQuery 的过滤和排序，column 返回记录中某一列的值
+func applyListQuery[T any](items []T, q utils.ListQuery, column func(T, string) interface{}) []T {
+
+	filtered := items[:0:0]
+	for _, item := range items {
+		keep := true
+		for _, f := range q.Filters {
+			c := compareValues(column(item, f.Column), f.Value)
+			switch f.Op {
+			case ">=":
+				keep = keep && c >= 0
+			case "<":
+				keep = keep && c < 0
+			default:
+				keep = keep && c == 0
+			}
+		}
+		if keep {
+			filtered = append(filtered, item)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		for _, s := range q.Sorts {
+			c := compareValues(column(filtered[i], s.Column), column(filtered[j], s.Column))
+			if c != 0 {
+				return (c < 0) != s.Desc
+			}
+		}
+		if len(q.Sorts) == 0 {
+			return false
+		}
+		// 与 SQL 实现一致，排序字段相同时按主键排序，方向跟随最后一个排序字段
+		return (compareValues(column(filtered[i], "id"), column(filtered[j], "id")) < 0) != q.TieBreakDesc()
+	})
+	return filtered
+}
+
+// applyCursor 在内存中执行 CursorQuery：取游标之后的记录，按扫描方向排序，至多 Limit+1 条
+func applyCursor[T any](items []T, cq utils.CursorQuery, key func(T) (time.Time, uint)) []T {
+
+	var matched []T
+	for _, item := range items {
+		if cq.After(key(item)) {
+			matched = append(matched, item)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		at, aID := key(matched[i])
+		bt, bID := key(matched[j])
+		return cq.Less(at, aID, bt, bID)
+	})
+	if len(matched) > cq.Limit+1 {
+		matched = matched[:cq.Limit+1]
+	}
+	return matched
+}
+
+func compareValues(a, b interface{}) int {
+	switch av := a.(type) {
+	case uint:
+		bv, _ := b.(uint)
+		return cmp.Compare(av, bv)
+	case string:
+		bv, _ := b.(string)
+		return strings.Compare(av, bv)
+	case time.Time:
+		bv, _ := b.(time.Time)
+		return av.Compare(bv)
+	}
+	return 0
+}
+
+func postColumn(p model.Post, column string) interface{} {
+	switch column {
+	case "id":
+		return p.ID
+	case "user_id":
+		return p.UserID
+	case "title":
+		return p.Title
+	case "created_at":
+		return p.CreatedAt
+	case "updated_at":
+		return p.UpdatedAt
+	case "publish_at":
+		if p.PublishAt == nil {
+			return time.Time{}
+		}
+		return *p.PublishAt
+	}
+	return nil
+}
+
+func commentColumn(c model.Comment, column string) interface{} {
+	switch column {
+	case "id":
+		return c.ID
+	case "user_id":
+		return c.UserID
+	case "created_at":
+		return c.CreatedAt
+	case "updated_at":
+		return c.UpdatedAt
+	}
+	return nil
+}
+
+type memoryUserRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryUserRepository) FindByID(id uint) (*model.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	u, ok := r.s.users[id]
+	if !ok {
+		return nil, fmt.Errorf("查询用户失败(id: %d):%w", id, ErrNotFound)
+	}
+	return &u, nil
+}
+
+func (r *memoryUserRepository) FindByUsername(username string) (*model.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if u, ok := findIdentity(r.s.users, username, func(u model.User) (*string, string) { return u.UsernameCanonical, u.Username }); ok {
+		return &u, nil
+	}
+	return nil, fmt.Errorf("查询用户失败(username: %s):%w", username, ErrNotFound)
+}
+
+func (r *memoryUserRepository) FindByEmail(email string) (*model.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if u, ok := findIdentity(r.s.users, email, func(u model.User) (*string, string) { return u.EmailCanonical, u.Email }); ok {
+		return &u, nil
+	}
+	return nil, fmt.Errorf("查询用户失败(email: %s):%w", email, ErrNotFound)
+}
+
+// findIdentity 与 GORM 实现的 byIdentity 一致：精确匹配的用户优先，其次按规范形式匹配
+func findIdentity(users map[uint]model.User, query string, column func(model.User) (*string, string)) (model.User, bool) {
+	var found *model.User
+	for _, u := range sortedValues(users) {
+		canonical, value := column(u)
+		if !matchIdentity(canonical, value, query) {
+			continue
+		}
+		if value == query {
+			return u, true
+		}
+		if found == nil {
+			found = &u
+		}
+	}
+	if found == nil {
+		return model.User{}, false
+	}
+	return *found, true
+}
+
+// matchIdentity 有规范形式时按规范形式比较，否则精确比较
+func matchIdentity(canonical *string, value, query string) bool {
+	if canonical != nil {
+		return *canonical == utils.CanonicalIdentity(query)
+	}
+	return value == query
+}
+
+// FindByMap 内存实现只支持 id、username、email 三列
+func (r *memoryUserRepository) FindByMap(conds map[string]interface{}) ([]model.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var users []model.User
+	for _, u := range sortedValues(r.s.users) {
+		match := true
+		for column, value := range conds {
+			switch column {
+			case "id":
+				match = match && fmt.Sprint(u.ID) == fmt.Sprint(value)
+			case "username":
+				match = match && u.Username == fmt.Sprint(value)
+			case "email":
+				match = match && u.Email == fmt.Sprint(value)
+			default:
+				return nil, fmt.Errorf("查询失败：unsupported column %q", column)
+			}
+		}
+		if match {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+func (r *memoryUserRepository) Create(u *model.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	// 与 BeforeCreate 钩子保持一致
+	u.Canonicalize()
+	for _, existing := range r.s.users {
+		if matchIdentity(existing.UsernameCanonical, existing.Username, u.Username) ||
+			matchIdentity(existing.EmailCanonical, existing.Email, u.Email) {
+			return fmt.Errorf("创建用户失败(username: %s):%w", u.Username, ErrDuplicate)
+		}
+	}
+	if err := u.HashPassword(); err != nil {
+		return fmt.Errorf("创建用户失败(username: %s):%w", u.Username, err)
+	}
+	if u.Role == "" {
+		u.Role = model.RoleUser
+	}
+	now := time.Now()
+	u.ID = r.s.newID("users")
+	u.CreatedAt, u.UpdatedAt = now, now
+	r.s.users[u.ID] = *u
+	return nil
+}
+
+func (r *memoryUserRepository) UpdateLoginState(u *model.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.users[u.ID]
+	if !ok {
+		return fmt.Errorf("更新登录状态失败(username: %s):%w", u.Username, ErrNotFound)
+	}
+	stored.FailedLoginAttempts = u.FailedLoginAttempts
+	stored.LockedUntil = u.LockedUntil
+	r.s.users[u.ID] = stored
+	return nil
+}
+
+func (r *memoryUserRepository) RegisterLoginFailure(id uint, maxAttempts int, lockFor time.Duration) (*time.Time, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.users[id]
+	if !ok {
+		return nil, fmt.Errorf("记录登录失败次数失败(id: %d):%w", id, ErrNotFound)
+	}
+	stored.FailedLoginAttempts++
+	if maxAttempts > 0 && stored.FailedLoginAttempts >= maxAttempts {
+		until := time.Now().Add(lockFor)
+		stored.LockedUntil = &until
+		stored.FailedLoginAttempts = 0
+	}
+	r.s.users[id] = stored
+	return stored.LockedUntil, nil
+}
+
+func (r *memoryUserRepository) UpdateProfile(u *model.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.users[u.ID]
+	if !ok {
+		return fmt.Errorf("修改用户资料失败(id: %d):%w", u.ID, ErrNotFound)
+	}
+	for _, existing := range r.s.users {
+		if existing.ID != u.ID && matchIdentity(existing.EmailCanonical, existing.Email, u.Email) {
+			return fmt.Errorf("修改用户资料失败(id: %d):%w", u.ID, ErrDuplicate)
+		}
+	}
+	stored.DisplayName, stored.Bio, stored.AvatarURL, stored.Email = u.DisplayName, u.Bio, u.AvatarURL, u.Email
+	stored.EmailCanonical, stored.EmailVerifiedAt = u.EmailCanonical, u.EmailVerifiedAt
+	stored.UpdatedAt = time.Now()
+	r.s.users[u.ID] = stored
+	return nil
+}
+
+func (r *memoryUserRepository) MarkEmailVerified(id uint, email string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.users[id]
+	if !ok || stored.Email != email {
+		return false, nil
+	}
+	now := time.Now()
+	stored.EmailVerifiedAt = &now
+	r.s.users[id] = stored
+	return true, nil
+}
+
+func (r *memoryUserRepository) UpdatePassword(id uint, password string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.users[id]
+	if !ok {
+		return fmt.Errorf("修改密码失败(id: %d):%w", id, ErrNotFound)
+	}
+	stored.Password = password
+	if err := stored.HashPassword(); err != nil {
+		return fmt.Errorf("修改密码失败(id: %d):%w", id, err)
+	}
+	now := time.Now()
+	stored.PasswordChangedAt = &now
+	stored.MustResetPassword = false
+	r.s.users[id] = stored
+	return nil
+}
+
+func (r *memoryUserRepository) UpdateRole(id uint, role string, permissions []string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.users[id]
+	if !ok {
+		return fmt.Errorf("修改用户角色失败(id: %d):%w", id, ErrNotFound)
+	}
+	stored.Role, stored.Permissions = role, slices.Clone(permissions)
+	r.s.users[id] = stored
+	return nil
+}
+
+func (r *memoryUserRepository) List(search string, page, size int) ([]model.User, int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	search = strings.ToLower(search)
+	var users []model.User
+	for _, u := range sortedValues(r.s.users) {
+		if u.Username == model.GhostUsername {
+			continue
+		}
+		if search == "" || strings.Contains(strings.ToLower(u.Username), search) || strings.Contains(strings.ToLower(u.Email), search) {
+			users = append(users, u)
+		}
+	}
+	return paginate(users, page, size), int64(len(users)), nil
+}
+
+func (r *memoryUserRepository) SetDisabled(id uint, disabledAt *time.Time) error {
+	return r.update(id, func(u *model.User) { u.DisabledAt = disabledAt })
+}
+
+func (r *memoryUserRepository) SetMustResetPassword(id uint, must bool) error {
+	return r.update(id, func(u *model.User) { u.MustResetPassword = must })
+}
+
+func (r *memoryUserRepository) update(id uint, apply func(u *model.User)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.users[id]
+	if !ok {
+		return fmt.Errorf("更新用户失败(id: %d):%w", id, ErrNotFound)
+	}
+	apply(&stored)
+	r.s.users[id] = stored
+	return nil
+}
+
+func (r *memoryUserRepository) Delete(id uint, cascade bool) (*UserContent, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.users[id]; !ok {
+		return nil, fmt.Errorf("删除用户失败(id: %d):%w", id, ErrNotFound)
+	}
+	content := &UserContent{}
+	for _, p := range sortedValues(r.s.posts) {
+		if p.UserID == id {
+			content.PostIDs = append(content.PostIDs, p.ID)
+		}
+	}
+	for _, c := range sortedValues(r.s.comments) {
+		if c.UserID == id {
+			content.CommentIDs = append(content.CommentIDs, c.ID)
+		}
+	}
+	ghostID := r.ghostUserID()
+
+	if cascade {
+		for _, postID := range content.PostIDs {
+			delete(r.s.posts, postID)
+			delete(r.s.postTags, postID)
+			for cid, c := range r.s.comments {
+				if c.PostID == postID {
+					delete(r.s.comments, cid)
+				}
+			}
+			for rid, rev := range r.s.revisions {
+				if rev.PostID == postID {
+					delete(r.s.revisions, rid)
+				}
+			}
+			for slug, old := range r.s.oldSlugs {
+				if old.PostID == postID {
+					delete(r.s.oldSlugs, slug)
+				}
+			}
+		}
+		// 先找出有回复的评论，再删除其余的，与 GORM 实现一致
+		replied := make(map[uint]bool)
+		for _, c := range r.s.comments {
+			if c.ParentID != nil {
+				replied[*c.ParentID] = true
+			}
+		}
+		for cid, c := range r.s.comments {
+			if c.UserID != id {
+				continue
+			}
+			if !replied[cid] {
+				delete(r.s.comments, cid)
+				continue
+			}
+			c.UserID, c.Content = ghostID, ""
+			if !c.IsDeleted() {
+				c.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+			}
+			r.s.comments[cid] = c
+		}
+	} else {
+		for pid, p := range r.s.posts {
+			if p.UserID == id {
+				p.UserID = ghostID
+				r.s.posts[pid] = p
+			}
+		}
+		for cid, c := range r.s.comments {
+			if c.UserID == id {
+				c.UserID = ghostID
+				r.s.comments[cid] = c
+			}
+		}
+	}
+	for rid, rev := range r.s.revisions {
+		if rev.AuthorID == id {
+			rev.AuthorID = ghostID
+			r.s.revisions[rid] = rev
+		}
+	}
+	for tid, t := range r.s.refreshTokens {
+		if t.UserID == id {
+			delete(r.s.refreshTokens, tid)
+		}
+	}
+	for jti, t := range r.s.actionTokens {
+		if t.UserID == id {
+			delete(r.s.actionTokens, jti)
+		}
+	}
+	r.deleteRecoveryCodes(id)
+	for iid, identity := range r.s.identities {
+		if identity.UserID == id {
+			delete(r.s.identities, iid)
+		}
+	}
+	delete(r.s.users, id)
+	return content, nil
+}
+
+// ghostUserID 查询或创建占位用户，调用方需持有锁
+func (r *memoryUserRepository) ghostUserID() uint {
+	for _, u := range r.s.users {
+		if u.Username == model.GhostUsername {
+			return u.ID
+		}
+	}
+	now := time.Now()
+	ghost := model.User{
+		ID:         r.s.newID("users"),
+		Username:   model.GhostUsername,
+		Email:      "deleted@invalid",
+		Role:       model.RoleUser,
+		DisabledAt: &now,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	ghost.Canonicalize()
+	r.s.users[ghost.ID] = ghost
+	return ghost.ID
+}
+
+func (r *memoryUserRepository) FindByIdentity(provider, subject string) (*model.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, identity := range r.s.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			if u, ok := r.s.users[identity.UserID]; ok {
+				return &u, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("查询用户失败(provider: %s):%w", provider, ErrNotFound)
+}
+
+func (r *memoryUserRepository) CreateWithIdentity(u *model.User, identity *model.UserIdentity) error {
+
+	if err := r.Create(u); err != nil {
+		return err
+	}
+	identity.UserID = u.ID
+	if err := r.LinkIdentity(identity); err != nil {
+		r.s.mu.Lock()
+		delete(r.s.users, u.ID)
+		r.s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) LinkIdentity(identity *model.UserIdentity) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, existing := range r.s.identities {
+		if existing.Provider == identity.Provider && existing.Subject == identity.Subject {
+			return fmt.Errorf("关联外部身份失败(provider: %s):%w", identity.Provider, ErrDuplicate)
+		}
+	}
+	identity.ID = r.s.newID("user_identities")
+	identity.CreatedAt = time.Now()
+	r.s.identities[identity.ID] = *identity
+	return nil
+}
+
+func (r *memoryUserRepository) TouchIdentity(provider, subject, email string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, identity := range r.s.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			now := time.Now()
+			identity.Email, identity.LastLoginAt = email, &now
+			r.s.identities[id] = identity
+		}
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) SetTOTPSecret(id uint, secret string) error {
+	return r.update(id, func(u *model.User) {
+		u.TOTPSecret, u.TOTPEnabledAt, u.TOTPLastStep = secret, nil, 0
+	})
+}
+
+func (r *memoryUserRepository) EnableTOTP(id uint, step int64, recoveryCodeHashes []string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.users[id]
+	if !ok {
+		return fmt.Errorf("开启两步验证失败(id: %d):%w", id, ErrNotFound)
+	}
+	now := time.Now()
+	stored.TOTPEnabledAt, stored.TOTPLastStep = &now, step
+	r.s.users[id] = stored
+	r.replaceRecoveryCodes(id, recoveryCodeHashes)
+	return nil
+}
+
+func (r *memoryUserRepository) DisableTOTP(id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.users[id]
+	if !ok {
+		return fmt.Errorf("关闭两步验证失败(id: %d):%w", id, ErrNotFound)
+	}
+	stored.TOTPSecret, stored.TOTPEnabledAt, stored.TOTPLastStep = "", nil, 0
+	r.s.users[id] = stored
+	r.deleteRecoveryCodes(id)
+	return nil
+}
+
+func (r *memoryUserRepository) UseTOTPStep(id uint, step int64) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.users[id]
+	if !ok || stored.TOTPLastStep >= step {
+		return false, nil
+	}
+	stored.TOTPLastStep = step
+	r.s.users[id] = stored
+	return true, nil
+}
+
+func (r *memoryUserRepository) ReplaceRecoveryCodes(userID uint, hashes []string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.replaceRecoveryCodes(userID, hashes)
+	return nil
+}
+
+func (r *memoryUserRepository) UseRecoveryCode(userID uint, hash string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, c := range r.s.recoveryCodes {
+		if c.UserID == userID && c.CodeHash == hash && c.UsedAt == nil {
+			now := time.Now()
+			c.UsedAt = &now
+			r.s.recoveryCodes[id] = c
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// replaceRecoveryCodes 调用方需持有锁
+func (r *memoryUserRepository) replaceRecoveryCodes(userID uint, hashes []string) {
+	r.deleteRecoveryCodes(userID)
+	for _, h := range hashes {
+		c := model.RecoveryCode{ID: r.s.newID("recovery_codes"), UserID: userID, CodeHash: h, CreatedAt: time.Now()}
+		r.s.recoveryCodes[c.ID] = c
+	}
+}
+
+// deleteRecoveryCodes 调用方需持有锁
+func (r *memoryUserRepository) deleteRecoveryCodes(userID uint) {
+	for id, c := range r.s.recoveryCodes {
+		if c.UserID == userID {
+			delete(r.s.recoveryCodes, id)
+		}
+	}
+}
+
+type memoryPostRepository struct {
+	s *memoryStore
+}
+
+// withAuthor 填充作者、分类和标签，调用方需持有锁
+func (r *memoryPostRepository) withAuthor(p model.Post) model.Post {
+	p.User = r.s.users[p.UserID]
+	p.Category = nil
+	if p.CategoryID != nil {
+		if c, ok := r.s.categories[*p.CategoryID]; ok {
+			p.Category = &c
+		}
+	}
+	p.Tags = nil
+	for _, id := range r.s.postTags[p.ID] {
+		p.Tags = append(p.Tags, r.s.tags[id])
+	}
+	sort.Slice(p.Tags, func(i, j int) bool { return p.Tags[i].Name < p.Tags[j].Name })
+	return p
+}
+
+// setTags 保存文章的标签，调用方需持有锁
+func (r *memoryPostRepository) setTags(postID uint, tags []model.Tag) {
+	ids := make([]uint, 0, len(tags))
+	for _, t := range tags {
+		if !slices.Contains(ids, t.ID) {
+			ids = append(ids, t.ID)
+		}
+	}
+	r.s.postTags[postID] = ids
+}
+
+func (r *memoryPostRepository) withComments(p model.Post) model.Post {
+	p.Comments = nil
+	for _, c := range sortedValues(r.s.comments) {
+		if c.PostID == p.ID && !c.IsDeleted() {
+			c.User = r.s.users[c.UserID]
+			p.Comments = append(p.Comments, c)
+		}
+	}
+	return p
+}
+
+func (r *memoryPostRepository) FindByID(id uint) (*model.Post, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p, ok := r.s.posts[id]
+	if !ok {
+		return nil, fmt.Errorf("查询文章失败(id: %d):%w", id, ErrNotFound)
+	}
+	p = r.withAuthor(p)
+	return &p, nil
+}
+
+func (r *memoryPostRepository) FindDetail(id uint) (*model.Post, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p, ok := r.s.posts[id]
+	if !ok {
+		return nil, fmt.Errorf("查询文章失败(id: %d):%w", id, ErrNotFound)
+	}
+	p = r.withComments(r.withAuthor(p))
+	return &p, nil
+}
+
+func (r *memoryPostRepository) FindBySlug(slug string) (*model.Post, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, p := range r.s.posts {
+		if p.Slug == slug {
+			p = r.withComments(r.withAuthor(p))
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("查询文章失败(slug: %s):%w", slug, ErrNotFound)
+}
+
+func (r *memoryPostRepository) FindOldSlug(slug string) (*model.PostSlug, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	old, ok := r.s.oldSlugs[slug]
+	if !ok {
+		return nil, fmt.Errorf("查询 slug 历史失败(slug: %s):%w", slug, ErrNotFound)
+	}
+	return &old, nil
+}
+
+// uniqueSlug 与 GORM 实现的规则一致：base、base-2 …，调用方需持有锁。
+// 内存中删除文章是物理删除，不会保留 slug
+func (r *memoryPostRepository) uniqueSlug(base string, postID uint) string {
+	taken := func(slug string) bool {
+		for _, p := range r.s.posts {
+			if p.Slug == slug && p.ID != postID {
+				return true
+			}
+		}
+		old, ok := r.s.oldSlugs[slug]
+		return ok && old.PostID != postID
+	}
+	slug := base
+	for i := 2; taken(slug); i++ {
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+	return slug
+}
+
+func (r *memoryPostRepository) List(filter PostFilter, page, size int) ([]model.Post, int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	all := applyListQuery(r.published(filter), filter.Query, postColumn)
+	posts := make([]model.Post, 0, size)
+	for _, p := range paginate(all, page, size) {
+		posts = append(posts, r.withAuthor(p))
+	}
+	return posts, int64(len(all)), nil
+}
+
+func (r *memoryPostRepository) ListByCursor(filter PostFilter, cq utils.CursorQuery) ([]model.Post, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	all := applyCursor(applyListQuery(r.published(filter), filter.Query, postColumn), cq,
+		func(p model.Post) (time.Time, uint) { return p.CreatedAt, p.ID })
+	posts := make([]model.Post, 0, len(all))
+	for _, p := range all {
+		posts = append(posts, r.withAuthor(p))
+	}
+	return posts, nil
+}
+
+func (r *memoryPostRepository) CountComments(postIDs []uint) (map[uint]int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	counts := make(map[uint]int64, len(postIDs))
+	for _, c := range r.s.comments {
+		if slices.Contains(postIDs, c.PostID) && !c.IsDeleted() {
+			counts[c.PostID]++
+		}
+	}
+	return counts, nil
+}
+
+// published 满足过滤条件的已发布文章，调用方需持有锁
+func (r *memoryPostRepository) published(filter PostFilter) []model.Post {
+
+	var all []model.Post
+	for _, p := range sortedValues(r.s.posts) {
+		if !p.IsPublished() {
+			continue
+		}
+		if filter.TagID != 0 && !slices.Contains(r.s.postTags[p.ID], filter.TagID) {
+			continue
+		}
+		if filter.CategoryID != 0 && (p.CategoryID == nil || *p.CategoryID != filter.CategoryID) {
+			continue
+		}
+		all = append(all, p)
+	}
+	return all
+}
+
+func (r *memoryPostRepository) ListByAuthor(userID uint, statuses []string, page, size int) ([]model.Post, int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var matched []model.Post
+	for _, p := range sortedValues(r.s.posts) {
+		if p.UserID == userID && slices.Contains(statuses, p.Status) {
+			matched = append(matched, r.withAuthor(p))
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].UpdatedAt.After(matched[j].UpdatedAt) })
+	return paginate(matched, page, size), int64(len(matched)), nil
+}
+
+func (r *memoryPostRepository) PublishDue(now time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var published int64
+	for id, p := range r.s.posts {
+		if p.Status == model.PostStatusScheduled && p.PublishAt != nil && !p.PublishAt.After(now) {
+			p.Status = model.PostStatusPublished
+			p.UpdatedAt = now
+			r.s.posts[id] = p
+			published++
+		}
+	}
+	return published, nil
+}
+
+func (r *memoryPostRepository) Create(p *model.Post) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now()
+	p.ID = r.s.newID("posts")
+	p.Slug = r.uniqueSlug(utils.Slugify(p.Title), p.ID)
+	p.CreatedAt, p.UpdatedAt = now, now
+	if p.Version == 0 {
+		p.Version = 1
+	}
+	if p.Status == "" {
+		p.Status = model.PostStatusPublished
+	}
+	stored := *p
+	r.setTags(p.ID, p.Tags)
+	stored.User, stored.Category, stored.Tags, stored.Comments = model.User{}, nil, nil, nil
+	r.s.posts[p.ID] = stored
+	r.addRevision(stored, p.UserID)
+	return nil
+}
+
+// addRevision 记录文章当前内容为一个历史版本，调用方需持有锁
+func (r *memoryPostRepository) addRevision(p model.Post, authorID uint) {
+	id := r.s.newID("post_revisions")
+	r.s.revisions[id] = model.PostRevision{
+		ID:        id,
+		PostID:    p.ID,
+		Revision:  p.Version,
+		Title:     p.Title,
+		Content:   p.Content,
+		AuthorID:  authorID,
+		CreatedAt: time.Now(),
+	}
+}
+
+func (r *memoryPostRepository) ListRevisions(postID uint, page, size int) ([]model.PostRevision, int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var matched []model.PostRevision
+	for _, rev := range sortedValues(r.s.revisions) {
+		if rev.PostID == postID {
+			rev.Author = r.s.users[rev.AuthorID]
+			matched = append(matched, rev)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Revision > matched[j].Revision })
+	return paginate(matched, page, size), int64(len(matched)), nil
+}
+
+func (r *memoryPostRepository) FindRevision(postID, revision uint) (*model.PostRevision, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, rev := range r.s.revisions {
+		if rev.PostID == postID && rev.Revision == revision {
+			rev.Author = r.s.users[rev.AuthorID]
+			return &rev, nil
+		}
+	}
+	return nil, fmt.Errorf("查询文章历史版本失败(id: %d, revision: %d):%w", postID, revision, ErrNotFound)
+}
+
+func (r *memoryPostRepository) Update(p *model.Post, expectedVersion, editorID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.posts[p.ID]
+	if !ok {
+		return fmt.Errorf("更新文章失败(id: %d):%w", p.ID, ErrNotFound)
+	}
+	if expectedVersion > 0 && stored.Version != expectedVersion {
+		return fmt.Errorf("更新文章失败(id: %d, version: %d):%w", p.ID, expectedVersion, ErrVersionConflict)
+	}
+	if p.Title != stored.Title || stored.Slug == "" {
+		slug := r.uniqueSlug(utils.Slugify(p.Title), p.ID)
+		if slug != stored.Slug {
+			delete(r.s.oldSlugs, slug)
+			if stored.Slug != "" {
+				r.s.oldSlugs[stored.Slug] = model.PostSlug{
+					ID:        r.s.newID("post_slugs"),
+					PostID:    p.ID,
+					Slug:      stored.Slug,
+					CreatedAt: time.Now(),
+				}
+			}
+			stored.Slug = slug
+		}
+	}
+	p.Slug = stored.Slug
+	contentChanged := p.Title != stored.Title || p.Content != stored.Content
+	stored.Title = p.Title
+	stored.Content = p.Content
+	stored.Status = p.Status
+	stored.PublishAt = p.PublishAt
+	stored.CategoryID = p.CategoryID
+	if p.Tags != nil {
+		r.setTags(p.ID, p.Tags)
+	}
+	stored.Version++
+	stored.UpdatedAt = time.Now()
+	p.Version = stored.Version
+	r.s.posts[p.ID] = stored
+	if contentChanged {
+		r.addRevision(stored, editorID)
+	}
+	return nil
+}
+
+func (r *memoryPostRepository) Delete(id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.posts[id]; !ok {
+		return fmt.Errorf("文章不存在或已被删除(id: %d):%w", id, ErrNotFound)
+	}
+	delete(r.s.posts, id)
+	return nil
+}
+
+type memoryTagRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryTagRepository) FindOrCreate(names []string) ([]model.Tag, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	tags := make([]model.Tag, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, t := range r.s.tags {
+			if t.Name == name {
+				tags = append(tags, t)
+				found = true
+				break
+			}
+		}
+		if !found {
+			now := time.Now()
+			t := model.Tag{ID: r.s.newID("tags"), Name: name, CreatedAt: now, UpdatedAt: now}
+			r.s.tags[t.ID] = t
+			tags = append(tags, t)
+		}
+	}
+	return tags, nil
+}
+
+func (r *memoryTagRepository) FindByName(name string) (*model.Tag, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, t := range r.s.tags {
+		if t.Name == name {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("查询标签失败(name: %s):%w", name, ErrNotFound)
+}
+
+func (r *memoryTagRepository) ListWithCounts() ([]model.TagStat, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	counts := make(map[uint]int64)
+	for postID, tagIDs := range r.s.postTags {
+		p, ok := r.s.posts[postID]
+		if !ok || !p.IsPublished() {
+			continue
+		}
+		for _, id := range tagIDs {
+			counts[id]++
+		}
+	}
+	stats := make([]model.TagStat, 0, len(counts))
+	for id, count := range counts {
+		stats = append(stats, model.TagStat{ID: id, Name: r.s.tags[id].Name, PostCount: count})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].PostCount != stats[j].PostCount {
+			return stats[i].PostCount > stats[j].PostCount
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	return stats, nil
+}
+
+func (r *memoryTagRepository) Rename(id uint, name string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	t, ok := r.s.tags[id]
+	if !ok {
+		return fmt.Errorf("重命名标签失败(id: %d):%w", id, ErrNotFound)
+	}
+	for _, other := range r.s.tags {
+		if other.Name == name && other.ID != id {
+			return fmt.Errorf("重命名标签失败(id: %d):%w", id, ErrDuplicate)
+		}
+	}
+	t.Name = name
+	t.UpdatedAt = time.Now()
+	r.s.tags[id] = t
+	return nil
+}
+
+func (r *memoryTagRepository) Merge(sourceIDs []uint, targetID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for postID, tagIDs := range r.s.postTags {
+		merged := make([]uint, 0, len(tagIDs))
+		for _, id := range tagIDs {
+			if slices.Contains(sourceIDs, id) {
+				id = targetID
+			}
+			if !slices.Contains(merged, id) {
+				merged = append(merged, id)
+			}
+		}
+		r.s.postTags[postID] = merged
+	}
+	for _, id := range sourceIDs {
+		delete(r.s.tags, id)
+	}
+	return nil
+}
+
+type memoryCategoryRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryCategoryRepository) FindByID(id uint) (*model.Category, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	c, ok := r.s.categories[id]
+	if !ok {
+		return nil, fmt.Errorf("查询分类失败(id: %d):%w", id, ErrNotFound)
+	}
+	return &c, nil
+}
+
+func (r *memoryCategoryRepository) List() ([]model.Category, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	categories := sortedValues(r.s.categories)
+	sort.SliceStable(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+	return categories, nil
+}
+
+func (r *memoryCategoryRepository) Create(c *model.Category) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, other := range r.s.categories {
+		if other.Name == c.Name {
+			return fmt.Errorf("新增分类失败：%w", ErrDuplicate)
+		}
+	}
+	now := time.Now()
+	c.ID = r.s.newID("categories")
+	c.CreatedAt, c.UpdatedAt = now, now
+	r.s.categories[c.ID] = *c
+	return nil
+}
+
+type memoryCommentRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryCommentRepository) FindByID(id uint) (*model.Comment, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	c, ok := r.s.comments[id]
+	if !ok || c.IsDeleted() {
+		return nil, fmt.Errorf("查询评论失败(id: %d):%w", id, ErrNotFound)
+	}
+	c.User = r.s.users[c.UserID]
+	return &c, nil
+}
+
+func (r *memoryCommentRepository) ListByPost(postID uint, q utils.ListQuery, page, size int) ([]model.Comment, int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var matched []model.Comment
+	for _, c := range sortedValues(r.s.comments) {
+		if c.PostID == postID && !c.IsDeleted() {
+			matched = append(matched, c)
+		}
+	}
+	matched = applyListQuery(matched, q, commentColumn)
+	comments := paginate(matched, page, size)
+	for i := range comments {
+		comments[i].User = r.s.users[comments[i].UserID]
+	}
+	return comments, int64(len(matched)), nil
+}
+
+func (r *memoryCommentRepository) ListByPostCursor(postID uint, q utils.ListQuery, cq utils.CursorQuery) ([]model.Comment, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var matched []model.Comment
+	for _, c := range sortedValues(r.s.comments) {
+		if c.PostID == postID && !c.IsDeleted() {
+			matched = append(matched, c)
+		}
+	}
+	matched = applyCursor(applyListQuery(matched, q, commentColumn), cq,
+		func(c model.Comment) (time.Time, uint) { return c.CreatedAt, c.ID })
+	for i := range matched {
+		matched[i].User = r.s.users[matched[i].UserID]
+	}
+	return matched, nil
+}
+
+// visibleInThread 与 GORM 实现一致：未删除，或已删除但任意深度的回复中还有未删除的，调用方需持有锁
+func (r *memoryCommentRepository) visibleInThread(c model.Comment) bool {
+
+	if !c.IsDeleted() {
+		return true
+	}
+	for _, reply := range r.s.comments {
+		if reply.ParentID != nil && *reply.ParentID == c.ID && r.visibleInThread(reply) {
+			return true
+		}
+	}
+	return false
+}
+
+// replies 按时间顺序列出 parentIDs 的可见直接回复，调用方需持有锁
+func (r *memoryCommentRepository) replies(parentIDs []uint) []model.Comment {
+
+	var matched []model.Comment
+	for _, c := range sortedValues(r.s.comments) {
+		if c.ParentID != nil && slices.Contains(parentIDs, *c.ParentID) && r.visibleInThread(c) {
+			c.User = r.s.users[c.UserID]
+			matched = append(matched, c)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].CreatedAt.Before(matched[j].CreatedAt) })
+	return matched
+}
+
+func (r *memoryCommentRepository) ListThreads(postID uint, parentID *uint, page, size int) ([]model.Comment, int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var matched []model.Comment
+	if parentID != nil {
+		for _, c := range r.replies([]uint{*parentID}) {
+			if c.PostID == postID {
+				matched = append(matched, c)
+			}
+		}
+	} else {
+		for _, c := range sortedValues(r.s.comments) {
+			if c.PostID == postID && c.ParentID == nil && r.visibleInThread(c) {
+				c.User = r.s.users[c.UserID]
+				matched = append(matched, c)
+			}
+		}
+		sort.SliceStable(matched, func(i, j int) bool { return matched[i].CreatedAt.Before(matched[j].CreatedAt) })
+	}
+	return paginate(matched, page, size), int64(len(matched)), nil
+}
+
+func (r *memoryCommentRepository) ListReplies(parentIDs []uint) ([]model.Comment, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.replies(parentIDs), nil
+}
+
+func (r *memoryCommentRepository) CountReplies(ids []uint) (map[uint]int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	counts := make(map[uint]int64, len(ids))
+	for _, c := range r.replies(ids) {
+		counts[*c.ParentID]++
+	}
+	return counts, nil
+}
+
+func (r *memoryCommentRepository) Create(c *model.Comment) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.posts[c.PostID]; !ok {
+		return fmt.Errorf("创建评论失败：%w", ErrNotFound)
+	}
+	now := time.Now()
+	c.ID = r.s.newID("comments")
+	c.CreatedAt, c.UpdatedAt = now, now
+	stored := *c
+	stored.User, stored.Post = model.User{}, model.Post{}
+	r.s.comments[c.ID] = stored
+	return nil
+}
+
+func (r *memoryCommentRepository) UpdateContent(id uint, content string, editedAt time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	c, ok := r.s.comments[id]
+	if !ok || c.IsDeleted() {
+		return fmt.Errorf("修改评论失败(id: %d):%w", id, ErrNotFound)
+	}
+	c.Content, c.EditedAt, c.UpdatedAt = content, &editedAt, time.Now()
+	r.s.comments[id] = c
+	return nil
+}
+
+func (r *memoryCommentRepository) Delete(id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	c, ok := r.s.comments[id]
+	if !ok || c.IsDeleted() {
+		return fmt.Errorf("删除评论失败(id: %d):%w", id, ErrNotFound)
+	}
+	c.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.s.comments[id] = c
+	return nil
+}
+
+type memoryTokenRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryTokenRepository) CreateRefreshToken(t *model.RefreshToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	t.ID = r.s.newID("refresh_tokens")
+	t.CreatedAt = time.Now()
+	r.s.refreshTokens[t.ID] = *t
+	return nil
+}
+
+func (r *memoryTokenRepository) FindRefreshTokenByHash(hash string) (*model.RefreshToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, t := range r.s.refreshTokens {
+		if t.TokenHash == hash {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("查询刷新令牌失败:%w", ErrNotFound)
+}
+
+func (r *memoryTokenRepository) RevokeRefreshToken(id uint) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	t, ok := r.s.refreshTokens[id]
+	if !ok || t.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	t.RevokedAt = &now
+	r.s.refreshTokens[id] = t
+	return true, nil
+}
+
+func (r *memoryTokenRepository) SetReplacedBy(id, replacedBy uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	t, ok := r.s.refreshTokens[id]
+	if !ok {
+		return fmt.Errorf("更新刷新令牌失败(id: %d):%w", id, ErrNotFound)
+	}
+	t.ReplacedBy = &replacedBy
+	r.s.refreshTokens[id] = t
+	return nil
+}
+
+func (r *memoryTokenRepository) revokeWhere(match func(model.RefreshToken) bool) {
+	now := time.Now()
+	for id, t := range r.s.refreshTokens {
+		if t.RevokedAt == nil && match(t) {
+			t.RevokedAt = &now
+			r.s.refreshTokens[id] = t
+		}
+	}
+}
+
+func (r *memoryTokenRepository) RevokeFamily(familyID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.revokeWhere(func(t model.RefreshToken) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+func (r *memoryTokenRepository) RevokeUser(userID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.revokeWhere(func(t model.RefreshToken) bool { return t.UserID == userID })
+	return nil
+}
+
+func (r *memoryTokenRepository) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if jti != "" {
+		r.s.revokedJTIs[jti] = expiresAt
+	}
+	return nil
+}
+
+func (r *memoryTokenRepository) IsAccessTokenRevoked(jti string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	_, ok := r.s.revokedJTIs[jti]
+	return ok, nil
+}
+
+func (r *memoryTokenRepository) CreateActionToken(t *model.ActionToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	t.ID = r.s.newID("action_tokens")
+	t.CreatedAt = time.Now()
+	r.s.actionTokens[t.JTI] = *t
+	return nil
+}
+
+func (r *memoryTokenRepository) UseActionToken(jti string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	t, ok := r.s.actionTokens[jti]
+	now := time.Now()
+	if !ok || t.UsedAt != nil || !now.Before(t.ExpiresAt) {
+		return false, nil
+	}
+	t.UsedAt = &now
+	r.s.actionTokens[jti] = t
+	return true, nil
+}
+
+func (r *memoryTokenRepository) ActionTokenUsable(jti string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	t, ok := r.s.actionTokens[jti]
+	return ok && t.UsedAt == nil && time.Now().Before(t.ExpiresAt), nil
+}
+
+func (r *memoryTokenRepository) RevokeActionTokens(userID uint, purpose string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now()
+	for jti, t := range r.s.actionTokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			t.UsedAt = &now
+			r.s.actionTokens[jti] = t
+		}
+	}
+	return nil
+}
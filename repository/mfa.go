@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jheader/golang_blog/model"
+	"gorm.io/gorm"
+)
+
+func (r *gormUserRepository) SetTOTPSecret(id uint, secret string) error {
+
+	result := r.db.Model(&model.User{ID: id}).UpdateColumns(map[string]interface{}{
+		"totp_secret":     secret,
+		"totp_enabled_at": nil,
+		"totp_last_step":  0,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("保存两步验证密钥失败(id: %d):%w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("保存两步验证密钥失败(id: %d):%w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func (r *gormUserRepository) EnableTOTP(id uint, step int64, recoveryCodeHashes []string) error {
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.User{ID: id}).UpdateColumns(map[string]interface{}{
+			"totp_enabled_at": time.Now(),
+			"totp_last_step":  step,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return replaceRecoveryCodes(tx, id, recoveryCodeHashes)
+	})
+	if err != nil {
+		return fmt.Errorf("开启两步验证失败(id: %d):%w", id, err)
+	}
+	return nil
+}
+
+func (r *gormUserRepository) DisableTOTP(id uint) error {
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.User{ID: id}).UpdateColumns(map[string]interface{}{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", id).Delete(&model.RecoveryCode{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("关闭两步验证失败(id: %d):%w", id, err)
+	}
+	return nil
+}
+
+func (r *gormUserRepository) UseTOTPStep(id uint, step int64) (bool, error) {
+
+	// 条件写在 UPDATE 中，同一个验证码并发提交时只有一个请求能成功
+	result := r.db.Model(&model.User{}).Where("id = ? AND totp_last_step < ?", id, step).
+		UpdateColumn("totp_last_step", step)
+	if result.Error != nil {
+		return false, fmt.Errorf("记录验证码失败(id: %d):%w", id, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *gormUserRepository) ReplaceRecoveryCodes(userID uint, hashes []string) error {
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, hashes)
+	})
+	if err != nil {
+		return fmt.Errorf("保存恢复码失败(user_id: %d):%w", userID, err)
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, hashes []string) error {
+
+	if err := tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	codes := make([]model.RecoveryCode, 0, len(hashes))
+	for _, h := range hashes {
+		codes = append(codes, model.RecoveryCode{UserID: userID, CodeHash: h})
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Create(&codes).Error
+}
+
+func (r *gormUserRepository) UseRecoveryCode(userID uint, hash string) (bool, error) {
+
+	result := r.db.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("使用恢复码失败(user_id: %d):%w", userID, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/utils"
+	"gorm.io/gorm"
+)
+
+type gormPostRepository struct {
+	db *gorm.DB
+}
+
+// withRelations 加载作者、分类和标签
+func withRelations(db *gorm.DB) *gorm.DB {
+	return db.Preload("User").Preload("Category").Preload("Tags", func(db *gorm.DB) *gorm.DB {
+		return db.Order("tags.name")
+	})
+}
+
+// withComments 按时间顺序加载评论及评论作者
+func withComments(db *gorm.DB) *gorm.DB {
+	return db.Preload("Comments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("comments.created_at, comments.id")
+	}).Preload("Comments.User")
+}
+
+func (r *gormPostRepository) FindByID(id uint) (*model.Post, error) {
+
+	var post model.Post
+	if err := r.db.Scopes(withRelations).First(&post, id).Error; err != nil {
+		return nil, fmt.Errorf("查询文章失败(id: %d):%w", id, translate(err))
+	}
+	return &post, nil
+}
+
+func (r *gormPostRepository) FindDetail(id uint) (*model.Post, error) {
+
+	var post model.Post
+	if err := r.db.Scopes(withRelations, withComments).First(&post, id).Error; err != nil {
+		return nil, fmt.Errorf("查询文章失败(id: %d):%w", id, translate(err))
+	}
+	return &post, nil
+}
+
+func (r *gormPostRepository) FindBySlug(slug string) (*model.Post, error) {
+
+	var post model.Post
+	if err := r.db.Scopes(withRelations, withComments).Where("slug = ?", slug).First(&post).Error; err != nil {
+		return nil, fmt.Errorf("查询文章失败(slug: %s):%w", slug, translate(err))
+	}
+	return &post, nil
+}
+
+func (r *gormPostRepository) FindOldSlug(slug string) (*model.PostSlug, error) {
+
+	var old model.PostSlug
+	if err := r.db.Where("slug = ?", slug).First(&old).Error; err != nil {
+		return nil, fmt.Errorf("查询 slug 历史失败(slug: %s):%w", slug, translate(err))
+	}
+	return &old, nil
+}
+
+// published 满足过滤条件的已发布文章
+func (r *gormPostRepository) published(filter PostFilter) *gorm.DB {
+
+	published := r.db.Model(&model.Post{}).Where("status = ?", model.PostStatusPublished)
+	if filter.TagID != 0 {
+		published = published.Where("id IN (?)", r.db.Table("post_tags").Select("post_id").Where("tag_id = ?", filter.TagID))
+	}
+	if filter.CategoryID != 0 {
+		published = published.Where("category_id = ?", filter.CategoryID)
+	}
+	return published.Scopes(filter.Query.FilterScope())
+}
+
+func (r *gormPostRepository) List(filter PostFilter, page, size int) ([]model.Post, int64, error) {
+
+	published := r.published(filter)
+	var total int64
+	// 步骤1：统计总条数（不含 LIMIT/OFFSET）
+	if err := published.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计文章总数失败：%w", err)
+	}
+	// 步骤2：使用分页中间件查询当前页数据
+	var posts []model.Post
+	if err := published.Scopes(withRelations, filter.Query.SortScope(), utils.Paginate(page, size)).Find(&posts).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询文章列表失败：%w", err)
+	}
+	return posts, total, nil
+}
+
+func (r *gormPostRepository) ListByCursor(filter PostFilter, cq utils.CursorQuery) ([]model.Post, error) {
+
+	var posts []model.Post
+	if err := r.published(filter).Scopes(withRelations, cq.Scope()).Find(&posts).Error; err != nil {
+		return nil, fmt.Errorf("查询文章列表失败：%w", err)
+	}
+	return posts, nil
+}
+
+func (r *gormPostRepository) CountComments(postIDs []uint) (map[uint]int64, error) {
+
+	counts := make(map[uint]int64, len(postIDs))
+	if len(postIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		PostID uint
+		Count  int64
+	}
+	err := r.db.Model(&model.Comment{}).Select("post_id, COUNT(*) AS count").
+		Where("post_id IN ?", postIDs).Group("post_id").Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("统计文章评论数失败：%w", err)
+	}
+	for _, row := range rows {
+		counts[row.PostID] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *gormPostRepository) ListByAuthor(userID uint, statuses []string, page, size int) ([]model.Post, int64, error) {
+
+	query := r.db.Model(&model.Post{}).Where("user_id = ? AND status IN ?", userID, statuses)
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计用户文章失败(user_id: %d)：%w", userID, err)
+	}
+	var posts []model.Post
+	err := query.Scopes(withRelations).Order("updated_at DESC").Order("id DESC").
+		Scopes(utils.Paginate(page, size)).Find(&posts).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询用户文章失败(user_id: %d)：%w", userID, err)
+	}
+	return posts, total, nil
+}
+
+func (r *gormPostRepository) Create(p *model.Post) error {
+
+	err := retryOnDuplicate(func() error {
+		return r.db.Transaction(func(tx *gorm.DB) error {
+			slug, err := uniqueSlug(tx, utils.Slugify(p.Title), 0)
+			if err != nil {
+				return err
+			}
+			p.Slug = slug
+			//忽略数据中的主键（即使设置了也会生成新主键，除非禁用自增）
+			//标签由 TagRepository.FindOrCreate 事先创建，这里只写入 post_tags
+			if err := tx.Omit("Tags.*").Create(p).Error; err != nil {
+				return err
+			}
+			return tx.Create(&model.PostRevision{
+				PostID:   p.ID,
+				Revision: p.Version,
+				Title:    p.Title,
+				Content:  p.Content,
+				AuthorID: p.UserID,
+			}).Error
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("新增文章失败：%w", err)
+	}
+	return nil
+}
+
+func (r *gormPostRepository) Update(p *model.Post, expectedVersion, editorID uint) error {
+
+	return retryOnDuplicate(func() error {
+		return r.update(p, expectedVersion, editorID)
+	})
+}
+
+func (r *gormPostRepository) update(p *model.Post, expectedVersion, editorID uint) error {
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+
+		var current model.Post
+		if err := tx.First(&current, p.ID).Error; err != nil {
+			return fmt.Errorf("查询文章失败(id: %d):%w", p.ID, translate(err))
+		}
+		if expectedVersion > 0 && current.Version != expectedVersion {
+			return fmt.Errorf("更新文章失败(id: %d, version: %d):%w", p.ID, expectedVersion, ErrVersionConflict)
+		}
+		// 历史版本功能上线前创建的文章没有快照，先补上当前版本
+		if err := backfillRevision(tx, &current); err != nil {
+			return err
+		}
+
+		// 标题变化后重新生成 slug，旧 slug 记入历史
+		slug := current.Slug
+		if p.Title != current.Title || slug == "" {
+			var err error
+			if slug, err = uniqueSlug(tx, utils.Slugify(p.Title), p.ID); err != nil {
+				return err
+			}
+			if err := changeSlug(tx, p.ID, current.Slug, slug); err != nil {
+				return err
+			}
+		}
+
+		// 使用 map 更新，零值字段同样会被保存；where 带上版本号，避免并发更新互相覆盖
+		result := tx.Model(&model.Post{}).Where("id = ? AND version = ?", p.ID, current.Version).Updates(map[string]interface{}{
+			"title":       p.Title,
+			"slug":        slug,
+			"content":     p.Content,
+			"status":      p.Status,
+			"publish_at":  p.PublishAt,
+			"category_id": p.CategoryID,
+			"version":     current.Version + 1,
+		})
+		if result.Error != nil {
+			return fmt.Errorf("更新文章失败(id: %d):%w", p.ID, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("更新文章失败(id: %d, version: %d):%w", p.ID, current.Version, ErrVersionConflict)
+		}
+		p.Version = current.Version + 1
+		p.Slug = slug
+
+		if p.Tags != nil {
+			if err := tx.Model(&model.Post{ID: p.ID}).Omit("Tags.*").Association("Tags").Replace(p.Tags); err != nil {
+				return fmt.Errorf("更新文章标签失败(id: %d):%w", p.ID, err)
+			}
+		}
+
+		// 历史版本只记录标题和正文，只修改了标签、分类或状态时不产生与上一版本相同的快照
+		if p.Title == current.Title && p.Content == current.Content {
+			return nil
+		}
+		err := tx.Create(&model.PostRevision{
+			PostID:   p.ID,
+			Revision: p.Version,
+			Title:    p.Title,
+			Content:  p.Content,
+			AuthorID: editorID,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("保存文章历史版本失败(id: %d):%w", p.ID, err)
+		}
+		return nil
+	})
+}
+
+// backfillRevision 文章还没有任何历史版本时，把当前内容记为一个版本。
+// 只修改标签等字段不产生新版本，因此当前版本号没有对应的快照是正常的，不能据此补录
+func backfillRevision(tx *gorm.DB, current *model.Post) error {
+
+	var count int64
+	err := tx.Model(&model.PostRevision{}).
+		Where("post_id = ?", current.ID).
+		Count(&count).Error
+	if err != nil || count > 0 {
+		return err
+	}
+	return tx.Create(&model.PostRevision{
+		PostID:    current.ID,
+		Revision:  current.Version,
+		Title:     current.Title,
+		Content:   current.Content,
+		AuthorID:  current.UserID,
+		CreatedAt: current.UpdatedAt,
+	}).Error
+}
+
+func (r *gormPostRepository) ListRevisions(postID uint, page, size int) ([]model.PostRevision, int64, error) {
+
+	var total int64
+	if err := r.db.Model(&model.PostRevision{}).Where("post_id = ?", postID).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计文章历史版本失败：%w", err)
+	}
+	var revisions []model.PostRevision
+	err := r.db.Preload("Author").Where("post_id = ?", postID).
+		Order("revision DESC").Scopes(utils.Paginate(page, size)).Find(&revisions).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询文章历史版本失败：%w", err)
+	}
+	return revisions, total, nil
+}
+
+func (r *gormPostRepository) FindRevision(postID, revision uint) (*model.PostRevision, error) {
+
+	var rev model.PostRevision
+	err := r.db.Preload("Author").Where("post_id = ? AND revision = ?", postID, revision).First(&rev).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询文章历史版本失败(id: %d, revision: %d):%w", postID, revision, translate(err))
+	}
+	return &rev, nil
+}
+
+func (r *gormPostRepository) Delete(id uint) error {
+
+	result := r.db.Delete(&model.Post{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除文章失败(id: %d):%w", id, result.Error)
+	}
+	// 检查是否有数据被删除
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("文章不存在或已被删除(id: %d):%w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func (r *gormPostRepository) PublishDue(now time.Time) (int64, error) {
+
+	// 条件写在 UPDATE 中：并发执行时数据库行锁保证每篇文章只会被其中一个实例更新
+	result := r.db.Model(&model.Post{}).
+		Where("status = ? AND publish_at <= ?", model.PostStatusScheduled, now).
+		Update("status", model.PostStatusPublished)
+	if result.Error != nil {
+		return 0, fmt.Errorf("发布定时文章失败：%w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/utils"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound 记录不存在，GORM 实现会把 gorm.ErrRecordNotFound 转换成它，
+// 调用方只需要判断这一个错误，不必依赖具体的存储实现
+var ErrNotFound = errors.New("record not found")
+
+// ErrDuplicate 违反唯一约束，例如重名的标签或分类
+var ErrDuplicate = errors.New("duplicate record")
+
+// ErrVersionConflict 乐观锁冲突：记录已被他人修改，版本号不再匹配
+var ErrVersionConflict = errors.New("version conflict")
+
+type UserRepository interface {
+	FindByID(id uint) (*model.User, error)
+	FindByUsername(username string) (*model.User, error)
+	FindByEmail(email string) (*model.User, error)
+	// FindByMap 按列名等值查询，例如 {"email": "a@b.com"}
+	FindByMap(conds map[string]interface{}) ([]model.User, error)
+	Create(u *model.User) error
+	// UpdateLoginState 持久化登录失败次数与锁定时间
+	UpdateLoginState(u *model.User) error
+	// RegisterLoginFailure 原子地累加登录失败次数，达到 maxAttempts 后清零并锁定 lockFor 时长，
+	// 返回更新后的锁定截止时间（未锁定时为 nil）
+	RegisterLoginFailure(id uint, maxAttempts int, lockFor time.Duration) (*time.Time, error)
+	// UpdateProfile 保存显示名称、简介、头像、邮箱和邮箱验证时间，邮箱已被占用时返回 ErrDuplicate
+	UpdateProfile(u *model.User) error
+	// MarkEmailVerified 邮箱仍为 email 时记录验证时间，邮箱已被修改时返回 false
+	MarkEmailVerified(id uint, email string) (bool, error)
+	// UpdatePassword 加密并保存新密码，记录修改时间，同时清除管理员要求重置密码的标记
+	UpdatePassword(id uint, password string) error
+	// FindByIdentity 按外部身份提供方和 subject 查询关联的用户
+	FindByIdentity(provider, subject string) (*model.User, error)
+	// CreateWithIdentity 在一个事务中创建用户并关联外部身份，用于首次使用外部账号登录
+	CreateWithIdentity(u *model.User, identity *model.UserIdentity) error
+	// LinkIdentity 把外部身份关联到已有用户，该身份已关联其他用户时返回 ErrDuplicate
+	LinkIdentity(identity *model.UserIdentity) error
+	// TouchIdentity 记录外部身份的最近登录时间和提供方返回的邮箱
+	TouchIdentity(provider, subject, email string) error
+	// SetTOTPSecret 开始开启两步验证：保存新生成的密钥，确认前不生效
+	SetTOTPSecret(id uint, secret string) error
+	// EnableTOTP 确认开启两步验证，step 为确认时使用的时间步，同时用新的恢复码替换旧的
+	EnableTOTP(id uint, step int64, recoveryCodeHashes []string) error
+	// DisableTOTP 关闭两步验证，清除密钥和恢复码
+	DisableTOTP(id uint) error
+	// UseTOTPStep 记录已使用的时间步，step 不大于上次使用的时间步（验证码被重放）时返回 false
+	UseTOTPStep(id uint, step int64) (bool, error)
+	// ReplaceRecoveryCodes 删除用户所有的恢复码并保存新的
+	ReplaceRecoveryCodes(userID uint, hashes []string) error
+	// UseRecoveryCode 把未使用的恢复码标记为已使用，不存在或已使用时返回 false
+	UseRecoveryCode(userID uint, hash string) (bool, error)
+	// UpdateRole 修改用户的角色和单独授予的权限
+	UpdateRole(id uint, role string, permissions []string) error
+	// List 按 id 分页列出用户，search 不为空时按用户名或邮箱模糊匹配（不区分大小写）。
+	// 不包含删除用户时创建的占位用户
+	List(search string, page, size int) ([]model.User, int64, error)
+	// SetDisabled 停用（disabledAt 不为空）或启用账号
+	SetDisabled(id uint, disabledAt *time.Time) error
+	SetMustResetPassword(id uint, must bool) error
+	// Delete 在一个事务中彻底删除用户及其刷新令牌、一次性令牌、恢复码和关联的外部身份。
+	// cascade 为 false 时文章、评论和历史版本转给占位用户 model.GhostUsername；
+	// 为 true 时删除该用户的文章（连同文章下所有人的评论），其他文章下的评论有回复的保留为已删除的占位，其余直接删除。
+	// 返回该用户名下的文章和评论 id，用于同步搜索索引
+	Delete(id uint, cascade bool) (*UserContent, error)
+}
+
+// UserContent 用户名下的文章和评论
+type UserContent struct {
+	PostIDs    []uint
+	CommentIDs []uint
+}
+
+type PostRepository interface {
+	// FindByID 查询文章并加载作者
+	FindByID(id uint) (*model.Post, error)
+	// FindDetail 查询文章并加载作者和评论（含评论作者）
+	FindDetail(id uint) (*model.Post, error)
+	// FindBySlug 按当前 slug 查询文章并加载作者和评论（含评论作者）
+	FindBySlug(slug string) (*model.Post, error)
+	// FindOldSlug 查询文章用过的旧 slug
+	FindOldSlug(slug string) (*model.PostSlug, error)
+	// List 按条件分页列出已发布的文章，同时加载作者、分类和标签（不加载评论）
+	List(filter PostFilter, page, size int) ([]model.Post, int64, error)
+	// ListByCursor 按游标列出已发布的文章，不统计总数。
+	// 结果按 cq 的扫描方向排列，至多 cq.Limit+1 条，由 utils.NewCursorResponse 截断
+	ListByCursor(filter PostFilter, cq utils.CursorQuery) ([]model.Post, error)
+	// CountComments 用一条聚合查询统计每篇文章的评论数，没有评论的文章不在结果中
+	CountComments(postIDs []uint) (map[uint]int64, error)
+	// ListByAuthor 分页列出作者处于指定状态的文章，按最后修改时间倒序
+	ListByAuthor(userID uint, statuses []string, page, size int) ([]model.Post, int64, error)
+	// Create 保存文章，根据标题生成唯一的 slug，同时记录第一个历史版本
+	Create(p *model.Post) error
+	// Update 保存文章的标题、内容、状态和发布时间并将版本号加一，标题变化时重新生成 slug，同时以 editorID 为作者记录新的历史版本。
+	// expectedVersion 大于0时只有当前版本号与之相同才会更新，否则返回 ErrVersionConflict
+	Update(p *model.Post, expectedVersion, editorID uint) error
+	Delete(id uint) error
+	// PublishDue 把 publish_at 不晚于 now 的定时文章改为已发布，返回发布的数量。
+	// 只执行一条带条件的 UPDATE，多个实例同时运行调度器也不会重复发布
+	PublishDue(now time.Time) (int64, error)
+
+	// ListRevisions 按版本号倒序列出历史版本，并加载作者
+	ListRevisions(postID uint, page, size int) ([]model.PostRevision, int64, error)
+	FindRevision(postID, revision uint) (*model.PostRevision, error)
+}
+
+// PostFilter 文章列表的过滤条件，零值表示不过滤
+type PostFilter struct {
+	TagID      uint
+	CategoryID uint
+	// Query 客户端指定的过滤和排序（已按白名单校验）
+	Query utils.ListQuery
+}
+
+type TagRepository interface {
+	// FindOrCreate 按名称查找标签，不存在的自动创建，返回顺序与 names 一致
+	FindOrCreate(names []string) ([]model.Tag, error)
+	FindByName(name string) (*model.Tag, error)
+	// ListWithCounts 列出至少有一篇已发布文章的标签，按文章数倒序
+	ListWithCounts() ([]model.TagStat, error)
+	// Rename 修改标签名称，新名称已被占用时返回 ErrDuplicate
+	Rename(id uint, name string) error
+	// Merge 把 sourceIDs 标签下的文章全部改为 targetID 标签，然后删除这些标签
+	Merge(sourceIDs []uint, targetID uint) error
+}
+
+type CategoryRepository interface {
+	FindByID(id uint) (*model.Category, error)
+	List() ([]model.Category, error)
+	// Create 新增分类，名称已存在时返回 ErrDuplicate
+	Create(c *model.Category) error
+}
+
+type CommentRepository interface {
+	// FindByID 查询评论并加载作者
+	FindByID(id uint) (*model.Comment, error)
+	// ListByPost 分页列出文章的评论并加载作者，q 为客户端指定的过滤和排序
+	ListByPost(postID uint, q utils.ListQuery, page, size int) ([]model.Comment, int64, error)
+	// ListByPostCursor 按游标列出文章的评论，结果约定同 PostRepository.ListByCursor
+	ListByPostCursor(postID uint, q utils.ListQuery, cq utils.CursorQuery) ([]model.Comment, error)
+	// ListThreads 分页列出文章的顶层评论（parentID 不为空时为该评论的直接回复），按时间顺序并加载作者。
+	// 已删除但还有未删除回复的评论也会返回，用于显示占位
+	ListThreads(postID uint, parentID *uint, page, size int) ([]model.Comment, int64, error)
+	// ListReplies 列出这些评论的直接回复，可见性规则同 ListThreads
+	ListReplies(parentIDs []uint) ([]model.Comment, error)
+	// CountReplies 用一条聚合查询统计每条评论可见的直接回复数，没有回复的评论不在结果中
+	CountReplies(ids []uint) (map[uint]int64, error)
+	Create(c *model.Comment) error
+	// UpdateContent 修改评论内容并记录修改时间
+	UpdateContent(id uint, content string, editedAt time.Time) error
+	// Delete 软删除评论，回复不受影响
+	Delete(id uint) error
+}
+
+type TokenRepository interface {
+	CreateRefreshToken(t *model.RefreshToken) error
+	FindRefreshTokenByHash(hash string) (*model.RefreshToken, error)
+	// RevokeRefreshToken 吊销单个刷新令牌，令牌此前已被吊销时返回 false
+	RevokeRefreshToken(id uint) (bool, error)
+	SetReplacedBy(id, replacedBy uint) error
+	RevokeFamily(familyID string) error
+	RevokeUser(userID uint) error
+	RevokeAccessToken(jti string, expiresAt time.Time) error
+	IsAccessTokenRevoked(jti string) (bool, error)
+
+	CreateActionToken(t *model.ActionToken) error
+	// UseActionToken 把未使用且未过期的一次性令牌标记为已使用，令牌不存在、已使用或已过期时返回 false
+	UseActionToken(jti string) (bool, error)
+	// ActionTokenUsable 一次性令牌是否未使用且未过期，只查询不标记
+	ActionTokenUsable(jti string) (bool, error)
+	// RevokeActionTokens 作废用户某种用途的全部未使用的令牌，例如重置密码后作废其他重置链接
+	RevokeActionTokens(userID uint, purpose string) error
+}
+
+// Repositories 汇总所有仓储，由 routes.SetupRoutes 注入到各个 controller
+type Repositories struct {
+	Users      UserRepository
+	Posts      PostRepository
+	Tags       TagRepository
+	Categories CategoryRepository
+	Comments   CommentRepository
+	Tokens     TokenRepository
+}
+
+func NewGormRepositories(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Users:      &gormUserRepository{db: db},
+		Posts:      &gormPostRepository{db: db},
+		Tags:       &gormTagRepository{db: db},
+		Categories: &gormCategoryRepository{db: db},
+		Comments:   &gormCommentRepository{db: db},
+		Tokens:     &gormTokenRepository{db: db},
+	}
+}
+
+// NewMemoryRepositories 基于内存的实现，供单元测试使用
+func NewMemoryRepositories() *Repositories {
+	s := newMemoryStore()
+	return &Repositories{
+		Users:      &memoryUserRepository{s: s},
+		Posts:      &memoryPostRepository{s: s},
+		Tags:       &memoryTagRepository{s: s},
+		Categories: &memoryCategoryRepository{s: s},
+		Comments:   &memoryCommentRepository{s: s},
+		Tokens:     &memoryTokenRepository{s: s},
+	}
+}
+
+// translate 把 GORM 的未找到、唯一键冲突错误统一成 ErrNotFound、ErrDuplicate
+func translate(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicate
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/utils"
+	"gorm.io/gorm"
+)
+
+// slugAttempts 依次尝试 base、base-2 … base-N，仍然冲突时追加随机后缀
+const slugAttempts = 20
+
+// uniqueSlug 在 base 的基础上找一个未被占用的 slug，postID 为当前文章，它自己用过的旧 slug 可以重新使用。
+// 已删除文章的 slug 和其他文章的旧 slug 都视为已占用
+func uniqueSlug(tx *gorm.DB, base string, postID uint) (string, error) {
+
+	for i := 1; i <= slugAttempts; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, i)
+		}
+		taken, err := slugTaken(tx, candidate, postID)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return base + "-" + hex.EncodeToString(suffix), nil
+}
+
+func slugTaken(tx *gorm.DB, slug string, postID uint) (bool, error) {
+
+	var count int64
+	if err := tx.Unscoped().Model(&model.Post{}).Where("slug = ? AND id <> ?", slug, postID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("检查 slug 失败：%w", err)
+	}
+	if count > 0 {
+		return true, nil
+	}
+	if err := tx.Model(&model.PostSlug{}).Where("slug = ? AND post_id <> ?", slug, postID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("检查 slug 失败：%w", err)
+	}
+	return count > 0, nil
+}
+
+// changeSlug 为文章分配新的 slug，旧 slug 记入历史以便跳转
+func changeSlug(tx *gorm.DB, postID uint, oldSlug, newSlug string) error {
+
+	if oldSlug == newSlug {
+		return nil
+	}
+	// 改回自己用过的旧 slug 时，从历史中移除
+	if err := tx.Where("post_id = ? AND slug = ?", postID, newSlug).Delete(&model.PostSlug{}).Error; err != nil {
+		return fmt.Errorf("更新 slug 历史失败(id: %d)：%w", postID, err)
+	}
+	if oldSlug == "" {
+		return nil
+	}
+	if err := tx.Create(&model.PostSlug{PostID: postID, Slug: oldSlug}).Error; err != nil {
+		return fmt.Errorf("保存 slug 历史失败(id: %d)：%w", postID, err)
+	}
+	return nil
+}
+
+// retryOnDuplicate 并发写入时两个请求可能选中同一个 slug，唯一索引冲突时重试
+func retryOnDuplicate(fn func() error) error {
+
+	var err error
+	for i := 0; i < 3; i++ {
+		if err = fn(); !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return err
+		}
+	}
+	return err
+}
+
+// BackfillPostSlugs 为 slug 功能上线前创建的文章生成 slug，迁移时调用
+func BackfillPostSlugs(db *gorm.DB) error {
+
+	var posts []model.Post
+	if err := db.Unscoped().Select("id", "title").Where("slug IS NULL OR slug = ''").Find(&posts).Error; err != nil {
+		return fmt.Errorf("查询缺少 slug 的文章失败：%w", err)
+	}
+	for _, p := range posts {
+		err := retryOnDuplicate(func() error {
+			return db.Transaction(func(tx *gorm.DB) error {
+				slug, err := uniqueSlug(tx, utils.Slugify(p.Title), p.ID)
+				if err != nil {
+					return err
+				}
+				return tx.Unscoped().Model(&model.Post{}).Where("id = ?", p.ID).UpdateColumn("slug", slug).Error
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("生成文章 slug 失败(id: %d)：%w", p.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/jheader/golang_blog/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormTagRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormTagRepository) FindOrCreate(names []string) ([]model.Tag, error) {
+
+	tags := make([]model.Tag, 0, len(names))
+	if len(names) == 0 {
+		return tags, nil
+	}
+	newTags := make([]model.Tag, 0, len(names))
+	for _, name := range names {
+		newTags = append(newTags, model.Tag{Name: name})
+	}
+	// 已存在的标签忽略，并发创建同名标签也不会报错
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&newTags).Error; err != nil {
+		return nil, fmt.Errorf("创建标签失败：%w", err)
+	}
+	var found []model.Tag
+	if err := r.db.Where("name IN ?", names).Find(&found).Error; err != nil {
+		return nil, fmt.Errorf("查询标签失败：%w", err)
+	}
+	byName := make(map[string]model.Tag, len(found))
+	for _, t := range found {
+		byName[t.Name] = t
+	}
+	for _, name := range names {
+		if t, ok := byName[name]; ok {
+			tags = append(tags, t)
+		}
+	}
+	return tags, nil
+}
+
+func (r *gormTagRepository) FindByName(name string) (*model.Tag, error) {
+
+	var tag model.Tag
+	if err := r.db.Where("name = ?", name).First(&tag).Error; err != nil {
+		return nil, fmt.Errorf("查询标签失败(name: %s):%w", name, translate(err))
+	}
+	return &tag, nil
+}
+
+func (r *gormTagRepository) ListWithCounts() ([]model.TagStat, error) {
+
+	stats := []model.TagStat{}
+	err := r.db.Table("tags").
+		Select("tags.id, tags.name, COUNT(posts.id) AS post_count").
+		Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Joins("JOIN posts ON posts.id = post_tags.post_id AND posts.status = ? AND posts.deleted_at IS NULL", model.PostStatusPublished).
+		Group("tags.id, tags.name").
+		Order("post_count DESC").Order("tags.name").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("统计标签失败：%w", err)
+	}
+	return stats, nil
+}
+
+func (r *gormTagRepository) Rename(id uint, name string) error {
+
+	result := r.db.Model(&model.Tag{}).Where("id = ?", id).Update("name", name)
+	if result.Error != nil {
+		return fmt.Errorf("重命名标签失败(id: %d):%w", id, translate(result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("重命名标签失败(id: %d):%w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func (r *gormTagRepository) Merge(sourceIDs []uint, targetID uint) error {
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, sourceID := range sourceIDs {
+			// 已经同时带有两个标签的文章只保留目标标签
+			err := tx.Exec(`INSERT INTO post_tags (post_id, tag_id)
+				SELECT post_id, ? FROM post_tags
+				WHERE tag_id = ? AND post_id NOT IN (SELECT post_id FROM post_tags WHERE tag_id = ?)`,
+				targetID, sourceID, targetID).Error
+			if err != nil {
+				return fmt.Errorf("合并标签失败(id: %d):%w", sourceID, err)
+			}
+			if err := tx.Exec("DELETE FROM post_tags WHERE tag_id = ?", sourceID).Error; err != nil {
+				return fmt.Errorf("合并标签失败(id: %d):%w", sourceID, err)
+			}
+			if err := tx.Delete(&model.Tag{}, sourceID).Error; err != nil {
+				return fmt.Errorf("删除标签失败(id: %d):%w", sourceID, err)
+			}
+		}
+		return nil
+	})
+}
+
+type gormCategoryRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormCategoryRepository) FindByID(id uint) (*model.Category, error) {
+
+	var category model.Category
+	if err := r.db.First(&category, id).Error; err != nil {
+		return nil, fmt.Errorf("查询分类失败(id: %d):%w", id, translate(err))
+	}
+	return &category, nil
+}
+
+func (r *gormCategoryRepository) List() ([]model.Category, error) {
+
+	var categories []model.Category
+	if err := r.db.Order("name").Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("查询分类列表失败：%w", err)
+	}
+	return categories, nil
+}
+
+func (r *gormCategoryRepository) Create(c *model.Category) error {
+
+	if err := r.db.Create(c).Error; err != nil {
+		return fmt.Errorf("新增分类失败：%w", translate(err))
+	}
+	return nil
+}
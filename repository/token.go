@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jheader/golang_blog/model"
+	"gorm.io/gorm"
+)
+
+type gormTokenRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormTokenRepository) CreateRefreshToken(t *model.RefreshToken) error {
+
+	if err := r.db.Create(t).Error; err != nil {
+		return fmt.Errorf("保存刷新令牌失败：%w", err)
+	}
+	return nil
+}
+
+func (r *gormTokenRepository) FindRefreshTokenByHash(hash string) (*model.RefreshToken, error) {
+
+	var t model.RefreshToken
+	if err := r.db.Where("token_hash = ?", hash).First(&t).Error; err != nil {
+		return nil, fmt.Errorf("查询刷新令牌失败:%w", translate(err))
+	}
+	return &t, nil
+}
+
+func (r *gormTokenRepository) RevokeRefreshToken(id uint) (bool, error) {
+
+	// 带上 revoked_at IS NULL 条件，并发刷新时只有一个请求能成功
+	result := r.db.Model(&model.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("吊销刷新令牌失败(id: %d):%w", id, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *gormTokenRepository) SetReplacedBy(id, replacedBy uint) error {
+
+	err := r.db.Model(&model.RefreshToken{}).Where("id = ?", id).Update("replaced_by", replacedBy).Error
+	if err != nil {
+		return fmt.Errorf("更新刷新令牌失败(id: %d):%w", id, err)
+	}
+	return nil
+}
+
+// RevokeFamily 吊销同一 family 下所有未吊销的刷新令牌
+func (r *gormTokenRepository) RevokeFamily(familyID string) error {
+
+	err := r.db.Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("吊销令牌族失败(family: %s):%w", familyID, err)
+	}
+	return nil
+}
+
+// RevokeUser 吊销某用户的全部刷新令牌
+func (r *gormTokenRepository) RevokeUser(userID uint) error {
+
+	err := r.db.Model(&model.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("吊销用户令牌失败(user_id: %d):%w", userID, err)
+	}
+	return nil
+}
+
+// RevokeAccessToken 将访问令牌的 jti 加入黑名单，同时顺带清理已过期的记录
+func (r *gormTokenRepository) RevokeAccessToken(jti string, expiresAt time.Time) error {
+
+	if jti == "" {
+		return nil
+	}
+	r.db.Where("expires_at < ?", time.Now()).Delete(&model.RevokedToken{})
+	err := r.db.Where(model.RevokedToken{JTI: jti}).
+		Attrs(model.RevokedToken{ExpiresAt: expiresAt}).
+		FirstOrCreate(&model.RevokedToken{}).Error
+	if err != nil {
+		return fmt.Errorf("吊销访问令牌失败(jti: %s):%w", jti, err)
+	}
+	return nil
+}
+
+func (r *gormTokenRepository) IsAccessTokenRevoked(jti string) (bool, error) {
+
+	if jti == "" {
+		return false, nil
+	}
+	var count int64
+	if err := r.db.Model(&model.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("查询令牌黑名单失败(jti: %s):%w", jti, err)
+	}
+	return count > 0, nil
+}
+
+func (r *gormTokenRepository) CreateActionToken(t *model.ActionToken) error {
+
+	if err := r.db.Create(t).Error; err != nil {
+		return fmt.Errorf("保存一次性令牌失败：%w", err)
+	}
+	return nil
+}
+
+func (r *gormTokenRepository) UseActionToken(jti string) (bool, error) {
+
+	// 条件写在 UPDATE 中，同一个令牌并发使用时只有一个请求能成功
+	now := time.Now()
+	result := r.db.Model(&model.ActionToken{}).
+		Where("jti = ? AND used_at IS NULL AND expires_at > ?", jti, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, fmt.Errorf("使用一次性令牌失败(jti: %s):%w", jti, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *gormTokenRepository) ActionTokenUsable(jti string) (bool, error) {
+
+	var count int64
+	err := r.db.Model(&model.ActionToken{}).
+		Where("jti = ? AND used_at IS NULL AND expires_at > ?", jti, time.Now()).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("查询一次性令牌失败(jti: %s):%w", jti, err)
+	}
+	return count > 0, nil
+}
+
+func (r *gormTokenRepository) RevokeActionTokens(userID uint, purpose string) error {
+
+	err := r.db.Model(&model.ActionToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("作废一次性令牌失败(user_id: %d):%w", userID, err)
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormUserRepository) FindByID(id uint) (*model.User, error) {
+
+	var u model.User
+	if err := r.db.First(&u, id).Error; err != nil {
+		return nil, fmt.Errorf("查询用户失败(id: %d):%w", id, translate(err))
+	}
+	return &u, nil
+}
+
+// result.Error == nil 只能说明「查询执行成功（无数据库异常）」，
+// 但不能 100% 等同于「查询到了数据」—— 仅当使用 First/Take/Last 这类「查询单条记录」的方法时，
+// result.Error == nil 才意味着「查询到了数据」；若使用 Find（查询多条）或 Count（统计数量），则需要额外判断结果长度 / 数量
+func (r *gormUserRepository) FindByUsername(username string) (*model.User, error) {
+
+	var u model.User
+	if err := r.db.Scopes(byIdentity("username", username)).First(&u).Error; err != nil {
+		return nil, fmt.Errorf("查询用户失败(username: %s):%w", username, translate(err))
+	}
+	return &u, nil
+}
+
+func (r *gormUserRepository) FindByEmail(email string) (*model.User, error) {
+
+	var u model.User
+	if err := r.db.Scopes(byIdentity("email", email)).First(&u).Error; err != nil {
+		return nil, fmt.Errorf("查询用户失败(email: %s):%w", email, translate(err))
+	}
+	return &u, nil
+}
+
+// byIdentity 按规范形式匹配用户名或邮箱。迁移时因冲突没有规范形式的旧用户只能精确匹配，
+// 精确匹配的用户优先，保证这些用户仍然可以用原来的用户名登录
+func byIdentity(column, value string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(column+"_canonical = ? OR ("+column+"_canonical IS NULL AND "+column+" = ?)",
+			utils.CanonicalIdentity(value), value).
+			Order(clause.OrderBy{Expression: clause.Expr{SQL: "CASE WHEN " + column + " = ? THEN 0 ELSE 1 END", Vars: []interface{}{value}}})
+	}
+}
+
+func (r *gormUserRepository) FindByMap(conds map[string]interface{}) ([]model.User, error) {
+
+	var users []model.User
+	if err := r.db.Find(&users, conds).Error; err != nil {
+		return nil, fmt.Errorf("查询失败：%w", err)
+	}
+	return users, nil
+}
+
+func (r *gormUserRepository) Create(u *model.User) error {
+
+	// 密码在 model.User 的 BeforeCreate 钩子中加密
+	if err := r.db.Create(u).Error; err != nil {
+		return fmt.Errorf("创建用户失败(username: %s):%w", u.Username, translate(err))
+	}
+	return nil
+}
+
+func (r *gormUserRepository) UpdateLoginState(u *model.User) error {
+
+	err := r.db.Model(u).UpdateColumns(map[string]interface{}{
+		"failed_login_attempts": u.FailedLoginAttempts,
+		"locked_until":          u.LockedUntil,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("更新登录状态失败(username: %s):%w", u.Username, err)
+	}
+	return nil
+}
+
+func (r *gormUserRepository) RegisterLoginFailure(id uint, maxAttempts int, lockFor time.Duration) (*time.Time, error) {
+
+	result := loginFailureUpdate(r.db, id, maxAttempts, time.Now().Add(lockFor))
+	if result.Error != nil {
+		return nil, fmt.Errorf("记录登录失败次数失败(id: %d):%w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("记录登录失败次数失败(id: %d):%w", id, ErrNotFound)
+	}
+
+	var u model.User
+	if err := r.db.Select("locked_until").First(&u, id).Error; err != nil {
+		return nil, fmt.Errorf("查询用户失败(id: %d):%w", id, translate(err))
+	}
+	return u.LockedUntil, nil
+}
+
+// loginFailureUpdate 计数和锁定在同一条 UPDATE 中完成，并发的失败请求不会互相覆盖。
+// MySQL 按 SET 中的顺序求值，后面的赋值读到的是前面已更新的值，所以用有序的 clause.Set
+// 把 locked_until 放在 failed_login_attempts 之前（map 会被 GORM 按键名排序）；
+// PostgreSQL 和 SQLite 都读取更新前的值，与顺序无关
+func loginFailureUpdate(db *gorm.DB, id uint, maxAttempts int, lockUntil time.Time) *gorm.DB {
+
+	set := clause.Set{{Column: clause.Column{Name: "failed_login_attempts"}, Value: gorm.Expr("failed_login_attempts + 1")}}
+	if maxAttempts > 0 {
+		set = clause.Set{
+			{Column: clause.Column{Name: "locked_until"},
+				Value: gorm.Expr("CASE WHEN failed_login_attempts + 1 >= ? THEN ? ELSE locked_until END", maxAttempts, lockUntil)},
+			{Column: clause.Column{Name: "failed_login_attempts"},
+				Value: gorm.Expr("CASE WHEN failed_login_attempts + 1 >= ? THEN 0 ELSE failed_login_attempts + 1 END", maxAttempts)},
+		}
+	}
+	return db.Model(&model.User{}).Clauses(set).Where("id = ?", id).UpdateColumns(map[string]interface{}{})
+}
+
+func (r *gormUserRepository) UpdateProfile(u *model.User) error {
+
+	result := r.db.Model(&model.User{ID: u.ID}).
+		Select("display_name", "bio", "avatar_url", "email", "email_canonical", "email_verified_at").
+		Updates(&model.User{
+			DisplayName:     u.DisplayName,
+			Bio:             u.Bio,
+			AvatarURL:       u.AvatarURL,
+			Email:           u.Email,
+			EmailCanonical:  u.EmailCanonical,
+			EmailVerifiedAt: u.EmailVerifiedAt,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("修改用户资料失败(id: %d):%w", u.ID, translate(result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("修改用户资料失败(id: %d):%w", u.ID, ErrNotFound)
+	}
+	return nil
+}
+
+func (r *gormUserRepository) MarkEmailVerified(id uint, email string) (bool, error) {
+
+	result := r.db.Model(&model.User{}).Where("id = ? AND email = ?", id, email).
+		UpdateColumn("email_verified_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("验证邮箱失败(id: %d):%w", id, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *gormUserRepository) UpdatePassword(id uint, password string) error {
+
+	u := model.User{Password: password}
+	if err := u.HashPassword(); err != nil {
+		return fmt.Errorf("修改密码失败(id: %d):%w", id, err)
+	}
+	result := r.db.Model(&model.User{ID: id}).UpdateColumns(map[string]interface{}{
+		"password":            u.Password,
+		"password_changed_at": time.Now(),
+		"must_reset_password": false,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("修改密码失败(id: %d):%w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("修改密码失败(id: %d):%w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func (r *gormUserRepository) UpdateRole(id uint, role string, permissions []string) error {
+
+	// Permissions 使用 json 序列化，通过结构体更新才会经过 serializer
+	result := r.db.Model(&model.User{ID: id}).Select("role", "permissions").
+		Updates(&model.User{Role: role, Permissions: permissions})
+	if result.Error != nil {
+		return fmt.Errorf("修改用户角色失败(id: %d):%w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("修改用户角色失败(id: %d):%w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func (r *gormUserRepository) List(search string, page, size int) ([]model.User, int64, error) {
+
+	query := r.db.Model(&model.User{}).Where("username <> ?", model.GhostUsername)
+	if search != "" {
+		pattern := "%" + escapeLike(strings.ToLower(search)) + "%"
+		query = query.Where("LOWER(username) LIKE ? ESCAPE '!' OR LOWER(email) LIKE ? ESCAPE '!'", pattern, pattern)
+	}
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计用户失败：%w", err)
+	}
+	var users []model.User
+	if err := query.Order("id").Scopes(utils.Paginate(page, size)).Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询用户列表失败：%w", err)
+	}
+	return users, total, nil
+}
+
+// escapeLike 转义 LIKE 中的通配符，使用 ! 作为转义字符，各个数据库的写法一致
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+func (r *gormUserRepository) SetDisabled(id uint, disabledAt *time.Time) error {
+	return r.updateColumn(id, "disabled_at", disabledAt)
+}
+
+func (r *gormUserRepository) SetMustResetPassword(id uint, must bool) error {
+	return r.updateColumn(id, "must_reset_password", must)
+}
+
+func (r *gormUserRepository) updateColumn(id uint, column string, value interface{}) error {
+
+	result := r.db.Model(&model.User{ID: id}).Update(column, value)
+	if result.Error != nil {
+		return fmt.Errorf("更新用户失败(id: %d, %s):%w", id, column, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("更新用户失败(id: %d, %s):%w", id, column, ErrNotFound)
+	}
+	return nil
+}
+
+func (r *gormUserRepository) Delete(id uint, cascade bool) (*UserContent, error) {
+
+	content := &UserContent{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&model.User{}, id).Error; err != nil {
+			return translate(err)
+		}
+		// 已软删除的文章和评论也一并处理，Unscoped 才能查到
+		if err := tx.Unscoped().Model(&model.Post{}).Where("user_id = ?", id).Pluck("id", &content.PostIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&model.Comment{}).Where("user_id = ?", id).Pluck("id", &content.CommentIDs).Error; err != nil {
+			return err
+		}
+		ghostID, err := ghostUserID(tx)
+		if err != nil {
+			return err
+		}
+
+		if cascade {
+			if err := deleteUserContent(tx, id, ghostID, content.PostIDs); err != nil {
+				return err
+			}
+		} else {
+			if err := tx.Unscoped().Model(&model.Post{}).Where("user_id = ?", id).UpdateColumn("user_id", ghostID).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&model.Comment{}).Where("user_id = ?", id).UpdateColumn("user_id", ghostID).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&model.PostRevision{}).Where("author_id = ?", id).UpdateColumn("author_id", ghostID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&model.RefreshToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&model.ActionToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&model.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&model.UserIdentity{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&model.User{}, id).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("删除用户失败(id: %d):%w", id, err)
+	}
+	return content, nil
+}
+
+// deleteUserContent 删除用户的文章（连同文章下的评论、标签关联、历史版本和旧 slug），
+// 以及该用户在其他文章下的评论：有回复的评论转给占位用户并清空内容、标记为已删除，保持评论树完整
+func deleteUserContent(tx *gorm.DB, userID, ghostID uint, postIDs []uint) error {
+
+	if len(postIDs) > 0 {
+		if err := tx.Unscoped().Where("post_id IN ?", postIDs).Delete(&model.Comment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM post_tags WHERE post_id IN ?", postIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id IN ?", postIDs).Delete(&model.PostRevision{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id IN ?", postIDs).Delete(&model.PostSlug{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&model.Post{}, postIDs).Error; err != nil {
+			return err
+		}
+	}
+
+	replied := tx.Session(&gorm.Session{NewDB: true}).Table("comments AS r").Select("1").Where("r.parent_id = comments.id")
+	err := tx.Unscoped().Model(&model.Comment{}).Where("user_id = ? AND EXISTS (?)", userID, replied).
+		UpdateColumns(map[string]interface{}{
+			"user_id":    ghostID,
+			"content":    "",
+			"deleted_at": gorm.Expr("COALESCE(deleted_at, ?)", time.Now()),
+		}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("user_id = ?", userID).Delete(&model.Comment{}).Error
+}
+
+// ghostUserID 查询占位用户，不存在时创建。占位用户处于停用状态，密码随机，无法登录
+func ghostUserID(tx *gorm.DB) (uint, error) {
+
+	password, err := utils.RandomToken(32)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	ghost := model.User{
+		Username:   model.GhostUsername,
+		Email:      "deleted@invalid",
+		Password:   password,
+		Role:       model.RoleUser,
+		DisabledAt: &now,
+	}
+	if err := tx.Where("username = ?", model.GhostUsername).Attrs(ghost).FirstOrCreate(&ghost).Error; err != nil {
+		return 0, fmt.Errorf("创建占位用户失败：%w", err)
+	}
+	return ghost.ID, nil
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jheader/golang_blog/model"
+	"gorm.io/gorm"
+)
+
+func (r *gormUserRepository) FindByIdentity(provider, subject string) (*model.User, error) {
+
+	var u model.User
+	err := r.db.Joins("JOIN user_identities ON user_identities.user_id = users.id").
+		Where("user_identities.provider = ? AND user_identities.subject = ?", provider, subject).
+		First(&u).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询用户失败(provider: %s):%w", provider, translate(err))
+	}
+	return &u, nil
+}
+
+func (r *gormUserRepository) CreateWithIdentity(u *model.User, identity *model.UserIdentity) error {
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// 密码在 model.User 的 BeforeCreate 钩子中加密
+		if err := tx.Create(u).Error; err != nil {
+			return err
+		}
+		identity.UserID = u.ID
+		return tx.Create(identity).Error
+	})
+	if err != nil {
+		return fmt.Errorf("创建用户失败(username: %s):%w", u.Username, translate(err))
+	}
+	return nil
+}
+
+func (r *gormUserRepository) LinkIdentity(identity *model.UserIdentity) error {
+
+	if err := r.db.Create(identity).Error; err != nil {
+		return fmt.Errorf("关联外部身份失败(provider: %s):%w", identity.Provider, translate(err))
+	}
+	return nil
+}
+
+func (r *gormUserRepository) TouchIdentity(provider, subject, email string) error {
+
+	err := r.db.Model(&model.UserIdentity{}).
+		Where("provider = ? AND subject = ?", provider, subject).
+		UpdateColumns(map[string]interface{}{"email": email, "last_login_at": time.Now()}).Error
+	if err != nil {
+		return fmt.Errorf("更新外部身份失败(provider: %s):%w", provider, err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// dryRunMySQL 不连接数据库，只用 MySQL 方言生成 SQL
+func dryRunMySQL(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:3306)/blog", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestLoginFailureUpdateSetsLockedUntilFirst(t *testing.T) {
+
+	db := dryRunMySQL(t)
+	lockUntil := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return loginFailureUpdate(tx, 7, 5, lockUntil)
+	})
+
+	// MySQL 按顺序求值 SET：locked_until 必须读到累加之前的 failed_login_attempts
+	lockedAt := strings.Index(sql, "`locked_until`=CASE WHEN failed_login_attempts + 1 >= 5")
+	attemptsAt := strings.Index(sql, "`failed_login_attempts`=CASE WHEN failed_login_attempts + 1 >= 5 THEN 0")
+	if lockedAt < 0 || attemptsAt < 0 || lockedAt > attemptsAt {
+		t.Fatalf("locked_until must be assigned before failed_login_attempts:\n%s", sql)
+	}
+	if !strings.Contains(sql, "WHERE id = 7") || !strings.Contains(sql, "`users`.`deleted_at` IS NULL") {
+		t.Fatalf("unexpected WHERE clause:\n%s", sql)
+	}
+	if strings.Contains(sql, "updated_at") {
+		t.Fatalf("login failures must not touch updated_at:\n%s", sql)
+	}
+
+	// 不限制次数时只累加
+	sql = db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return loginFailureUpdate(tx, 7, 0, lockUntil)
+	})
+	if !strings.Contains(sql, "SET `failed_login_attempts`=failed_login_attempts + 1 WHERE") {
+		t.Fatalf("unexpected SQL without a limit:\n%s", sql)
+	}
+}
@@ -3,60 +3,146 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/jheader/golang_blog/controller"
+	"github.com/jheader/golang_blog/mail"
 	"github.com/jheader/golang_blog/middleware"
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/repository"
+	"github.com/jheader/golang_blog/search"
+	"github.com/jheader/golang_blog/service"
+	"github.com/jheader/golang_blog/utils"
 )
 
-func SetupRoutes() *gin.Engine {
+func SetupRoutes(repos *repository.Repositories, searcher search.Searcher, mailer mail.Mailer) *gin.Engine {
+
+	// 注册自定义校验规则和校验错误的中英文翻译
+	utils.InitValidator()
 
 	r := gin.New()
+	r.Use(middleware.RequestIDMiddleware())
 	r.Use(middleware.LoggerMiddleWare())
 	r.Use(middleware.ErrorHandleMiddleWare())
 	r.Use(gin.Recovery())
 
+	authService := service.NewAuthService(repos.Users, repos.Tokens, mailer)
+	authController := controller.NewAuthController(authService)
+	postController := controller.NewPostController(service.NewPostService(repos.Posts, repos.Tags, repos.Categories, searcher))
+	tagController := controller.NewTagController(
+		service.NewTagService(repos.Tags, repos.Posts, searcher),
+		service.NewCategoryService(repos.Categories, repos.Posts))
+	commentController := controller.NewCommentController(service.NewCommentService(repos.Comments, repos.Posts, searcher))
+	searchController := controller.NewSearchController(service.NewSearchService(searcher, repos.Users, repos.Tags))
+	userService := service.NewUserService(repos.Users, repos.Tokens, repos.Posts, repos.Comments, searcher)
+	userController := controller.NewUser(userService, authService)
+	adminController := controller.NewAdminController(userService)
+	oidcController := controller.NewOIDCController(service.NewOIDCService(authService, repos.Users, utils.OIDCProviders()))
+
 	api := r.Group("/api/v1")
 	{
 		// 认证相关路由（无需认证）
 		auth := api.Group("/auth")
 		{
-			auth.POST("/register", (&controller.AuthController{}).Register)
-			auth.POST("/login", (&controller.AuthController{}).Login)
+			auth.POST("/register", authController.Register)
+			auth.POST("/login", authController.Login)
+			auth.POST("/mfa", authController.VerifyMFA)
+			auth.POST("/refresh", authController.Refresh)
+			auth.POST("/logout", authController.Logout)
+			//邮箱验证、忘记密码、重置密码
+			auth.POST("/verify", authController.VerifyEmail)
+			auth.POST("/forgot", authController.ForgotPassword)
+			auth.POST("/reset", authController.ResetPassword)
+			//使用外部身份提供方（OpenID Connect）登录
+			auth.GET("/oidc/providers", oidcController.Providers)
+			auth.GET("/oidc/:provider/login", oidcController.Login)
+			auth.GET("/oidc/:provider/callback", oidcController.Callback)
 		}
 		// 需要认证的路由
 		authenticated := api.Group("")
-		authenticated.Use(middleware.AuthMiddleware())
+		authenticated.Use(middleware.AuthMiddleware(repos.Tokens, repos.Users))
 		{
-			authenticated.GET("/profile", (&controller.User{}).GetProfile)
+			//当前用户的资料：查看 / 修改 / 修改密码，/profile 为旧路径
+			authenticated.GET("/me", userController.GetProfile)
+			authenticated.PATCH("/me", userController.UpdateProfile)
+			authenticated.POST("/me/password", userController.ChangePassword)
+			authenticated.POST("/me/verify-email", userController.ResendVerification)
+			authenticated.POST("/me/mfa/totp", userController.EnrollTOTP)
+			authenticated.POST("/me/mfa/totp/confirm", userController.ConfirmTOTP)
+			authenticated.DELETE("/me/mfa/totp", userController.DisableTOTP)
+			authenticated.POST("/me/mfa/recovery-codes", userController.RegenerateRecoveryCodes)
+			authenticated.GET("/profile", userController.GetProfile)
 			//文章
 			postsRout := authenticated.Group("/posts")
-			{ //发表或者更新
-				postsRout.POST("/saveOrUpdate", (&controller.PostController{}).CreateOrUpdate)
+			{ //发表，REQUIRE_VERIFIED_EMAIL 为 true 时需要先验证邮箱
+				postsRout.POST("", middleware.RequireVerifiedEmail(), postController.CreatePost)
+				//我的草稿箱（草稿 / 定时发布）
+				postsRout.GET("/drafts", postController.MyPosts)
+				//整体更新 / 部分更新，需携带 If-Match
+				postsRout.PUT("/:post_id", postController.UpdatePost)
+				postsRout.PATCH("/:post_id", postController.PatchPost)
 				//删除
-				postsRout.DELETE("/:post_id", (&controller.PostController{}).DeletedById)
+				postsRout.DELETE("/:post_id", postController.DeletedById)
+				//历史版本：列表 / 对比 / 查看 / 恢复
+				postsRout.GET("/:post_id/revisions", postController.ListRevisions)
+				postsRout.GET("/:post_id/revisions/diff", postController.DiffRevisions)
+				postsRout.GET("/:post_id/revisions/:rev", postController.GetRevision)
+				postsRout.POST("/:post_id/revisions/:rev/restore", postController.RestoreRevision)
 
 			}
+			//管理接口，按权限控制：标签重命名 / 合并、新增分类，用户管理
+			admin := authenticated.Group("/admin")
+			{
+				admin.PATCH("/tags/:name", middleware.RequirePermission(model.PermTagsManage), tagController.RenameTag)
+				admin.POST("/tags/merge", middleware.RequirePermission(model.PermTagsManage), tagController.MergeTags)
+				admin.POST("/categories", middleware.RequirePermission(model.PermTagsManage), tagController.CreateCategory)
+				users := admin.Group("/users", middleware.RequirePermission(model.PermUsersManage))
+				users.GET("", adminController.ListUsers)
+				users.PUT("/:id/role", adminController.SetUserRole)
+				users.POST("/:id/disable", adminController.DisableUser)
+				users.POST("/:id/enable", adminController.EnableUser)
+				users.POST("/:id/force-password-reset", adminController.ForcePasswordReset)
+				users.DELETE("/:id", adminController.DeleteUser)
+			}
 			//评论授权路由 实现评论的创建功能，已认证的用户可以对文章发表评论。
 			addcomment := authenticated.Group("/posts/:post_id/comment")
 			{
-				addcomment.POST("", (&controller.CommentController{}).CreateComment)
+				addcomment.POST("", middleware.RequireVerifiedEmail(), commentController.CreateComment)
 
 			}
+			//修改、删除评论
+			commentsRout := authenticated.Group("/comments")
+			{
+				commentsRout.PUT("/:id", commentController.UpdateComment)
+				commentsRout.DELETE("/:id", commentController.DeleteComment)
+			}
 
 		}
 
 		// 公开路由（无需认证）
 		public := api.Group("")
+		// 携带令牌时识别当前用户，作者可以查看自己未发布的文章
+		public.Use(middleware.OptionalAuthMiddleware(repos.Tokens, repos.Users))
 		{
-			// 文章公开路由获取所有文章列表和
-			public.GET("/posts", (&controller.PostController{}).GetAllPosts)
+			// 文章公开路由获取所有已发布的文章列表和
+			public.GET("/posts", postController.GetAllPosts)
 			//单个文章的详细信息
-			public.GET("/posts/:post_id", (&controller.PostController{}).GetPostById)
+			public.GET("/posts/:post_id", postController.GetPostById)
+			//按 slug 查询，旧 slug 301 跳转
+			public.GET("/posts/by-slug/:slug", postController.GetPostBySlug)
 			//获取某篇文章的所有评论列表。
-			public.GET("/posts/commentsByPostId", (&controller.CommentController{}).GetComments)
+			public.GET("/posts/commentsByPostId", commentController.GetComments)
+			//标签和分类
+			public.GET("/tags", tagController.ListTags)
+			public.GET("/tags/:name/posts", tagController.TagPosts)
+			public.GET("/categories", tagController.ListCategories)
+			public.GET("/categories/:id/posts", tagController.CategoryPosts)
+			//用户的公开资料
+			public.GET("/users/:username", userController.GetByUsername)
+			//全文搜索：文章标题、正文和评论
+			public.GET("/search", searchController.Search)
 		}
 
 		// 健康检查
 		r.GET("/health", func(c *gin.Context) {
-			c.JSON(200, gin.H{
+			utils.Success(c, gin.H{
 				"status":  "ok",
 				"message": "Blog API is running",
 			})
@@ -64,5 +150,9 @@ func SetupRoutes() *gin.Engine {
 
 	}
 
+	r.NoRoute(func(c *gin.Context) {
+		utils.NotFound(c, "route not found")
+	})
+
 	return r
 }
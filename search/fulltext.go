@@ -0,0 +1,187 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/jheader/golang_blog/model"
+	"gorm.io/gorm"
+)
+
+// fulltextIndexes MySQL FULLTEXT 索引，使用 ngram 解析器以支持中文。
+// 标题单独建一个索引，用于提高标题匹配的权重
+var fulltextIndexes = []struct {
+	table, name, columns string
+}{
+	{"posts", "ft_posts_title", "title"},
+	{"posts", "ft_posts_title_content", "title, content"},
+	{"comments", "ft_comments_content", "content"},
+}
+
+// FulltextSearcher 基于 MySQL FULLTEXT 索引的搜索，索引由 MySQL 在写入时自动维护，
+// 因此 IndexPost 等同步方法都不需要做任何事
+type FulltextSearcher struct {
+	db *gorm.DB
+}
+
+// NewFulltextSearcher 创建搜索器，缺少的 FULLTEXT 索引会自动创建
+func NewFulltextSearcher(db *gorm.DB) (*FulltextSearcher, error) {
+
+	for _, idx := range fulltextIndexes {
+		var count int64
+		err := db.Raw(`SELECT COUNT(*) FROM information_schema.statistics
+			WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?`, idx.table, idx.name).
+			Scan(&count).Error
+		if err != nil {
+			return nil, fmt.Errorf("检查全文索引失败(%s)：%w", idx.name, err)
+		}
+		if count > 0 {
+			continue
+		}
+		sql := fmt.Sprintf("ALTER TABLE %s ADD FULLTEXT INDEX %s (%s) WITH PARSER ngram", idx.table, idx.name, idx.columns)
+		if err := db.Exec(sql).Error; err != nil {
+			return nil, fmt.Errorf("创建全文索引失败(%s)：%w", idx.name, err)
+		}
+	}
+	return &FulltextSearcher{db: db}, nil
+}
+
+func (s *FulltextSearcher) IndexPost(p *model.Post) error                   { return nil }
+func (s *FulltextSearcher) DeletePost(id uint) error                        { return nil }
+func (s *FulltextSearcher) IndexComment(c *model.Comment) error             { return nil }
+func (s *FulltextSearcher) DeleteComment(id uint) error                     { return nil }
+func (s *FulltextSearcher) MergeTags(sourceIDs []uint, targetID uint) error { return nil }
+
+type fulltextRow struct {
+	Type      string
+	PostID    uint
+	CommentID uint
+	AuthorID  uint
+	CreatedAt time.Time
+	Score     float64
+}
+
+func (s *FulltextSearcher) Search(q Query) ([]Hit, int64, error) {
+
+	terms := uniqueTerms(Tokenize(q.Text))
+	if len(terms) == 0 {
+		return []Hit{}, 0, nil
+	}
+
+	// 两个子查询分别搜索文章和评论，UNION 后按相关度统一排序
+	const against = "AGAINST (? IN NATURAL LANGUAGE MODE)"
+	postSQL, postArgs := s.filter(
+		"SELECT 'post' AS type, posts.id AS post_id, 0 AS comment_id, posts.user_id AS author_id, posts.created_at AS created_at, "+
+			"MATCH (posts.title) "+against+" * 2 + MATCH (posts.title, posts.content) "+against+" AS score "+
+			"FROM posts WHERE MATCH (posts.title, posts.content) "+against,
+		[]interface{}{q.Text, q.Text, q.Text}, "posts", q)
+	commentSQL, commentArgs := s.filter(
+		"SELECT 'comment' AS type, comments.post_id AS post_id, comments.id AS comment_id, comments.user_id AS author_id, comments.created_at AS created_at, "+
+			"MATCH (comments.content) "+against+" AS score "+
+			"FROM comments JOIN posts ON posts.id = comments.post_id "+
+			"WHERE comments.deleted_at IS NULL AND MATCH (comments.content) "+against,
+		[]interface{}{q.Text, q.Text}, "comments", q)
+	union := postSQL + " UNION ALL " + commentSQL
+	args := append(postArgs, commentArgs...)
+
+	var total int64
+	if err := s.db.Raw("SELECT COUNT(*) FROM ("+union+") AS matches", args...).Scan(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("搜索失败：%w", err)
+	}
+	var rows []fulltextRow
+	err := s.db.Raw(union+" ORDER BY score DESC, created_at DESC LIMIT ? OFFSET ?",
+		append(args, q.Size, (q.Page-1)*q.Size)...).Scan(&rows).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("搜索失败：%w", err)
+	}
+	hits, err := s.load(rows, terms)
+	if err != nil {
+		return nil, 0, err
+	}
+	return hits, total, nil
+}
+
+// filter 追加可见性和过滤条件，table 为结果所属的表（作者和时间取自该表）
+func (s *FulltextSearcher) filter(sql string, args []interface{}, table string, q Query) (string, []interface{}) {
+
+	var sb strings.Builder
+	sb.WriteString(sql)
+	sb.WriteString(" AND posts.deleted_at IS NULL AND (posts.status = ? OR (posts.status = ? AND posts.publish_at <= ?))")
+	args = append(args, model.PostStatusPublished, model.PostStatusScheduled, time.Now())
+	if q.AuthorID != 0 {
+		sb.WriteString(" AND " + table + ".user_id = ?")
+		args = append(args, q.AuthorID)
+	}
+	if q.TagID != 0 {
+		sb.WriteString(" AND posts.id IN (SELECT post_id FROM post_tags WHERE tag_id = ?)")
+		args = append(args, q.TagID)
+	}
+	if q.From != nil {
+		sb.WriteString(" AND " + table + ".created_at >= ?")
+		args = append(args, *q.From)
+	}
+	if q.To != nil {
+		sb.WriteString(" AND " + table + ".created_at <= ?")
+		args = append(args, *q.To)
+	}
+	return sb.String(), args
+}
+
+// load 查询结果对应的文章和评论，生成高亮的标题和摘要
+func (s *FulltextSearcher) load(rows []fulltextRow, terms []string) ([]Hit, error) {
+
+	var postIDs, commentIDs []uint
+	for _, row := range rows {
+		postIDs = append(postIDs, row.PostID)
+		if row.Type == TypeComment {
+			commentIDs = append(commentIDs, row.CommentID)
+		}
+	}
+	posts := make(map[uint]model.Post)
+	comments := make(map[uint]model.Comment)
+	if len(postIDs) > 0 {
+		var list []model.Post
+		if err := s.db.Preload("User").Where("id IN ?", postIDs).Find(&list).Error; err != nil {
+			return nil, fmt.Errorf("查询搜索结果失败：%w", err)
+		}
+		for _, p := range list {
+			posts[p.ID] = p
+		}
+	}
+	if len(commentIDs) > 0 {
+		var list []model.Comment
+		if err := s.db.Preload("User").Where("id IN ?", commentIDs).Find(&list).Error; err != nil {
+			return nil, fmt.Errorf("查询搜索结果失败：%w", err)
+		}
+		for _, c := range list {
+			comments[c.ID] = c
+		}
+	}
+
+	hits := make([]Hit, 0, len(rows))
+	for _, row := range rows {
+		post := posts[row.PostID]
+		hit := Hit{
+			Type:      row.Type,
+			PostID:    row.PostID,
+			Slug:      post.Slug,
+			Title:     Highlight(post.Title, terms),
+			AuthorID:  row.AuthorID,
+			Score:     math.Round(row.Score*1000) / 1000,
+			CreatedAt: row.CreatedAt,
+		}
+		if row.Type == TypeComment {
+			comment := comments[row.CommentID]
+			hit.CommentID = row.CommentID
+			hit.Snippet = Snippet(comment.Content, terms)
+			hit.Author = comment.User.Username
+		} else {
+			hit.Snippet = Snippet(post.Content, terms)
+			hit.Author = post.User.Username
+		}
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
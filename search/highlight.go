@@ -0,0 +1,93 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// snippetLength 摘要的最大长度（字符数）
+const snippetLength = 160
+
+// Highlight 对整段文本做 HTML 转义并高亮其中的搜索词
+func Highlight(text string, terms []string) string {
+	runes := []rune(text)
+	return mark(runes, matchSpans(runes, terms))
+}
+
+// Snippet 截取第一个匹配附近 snippetLength 个字符并高亮，没有匹配时取开头
+func Snippet(text string, terms []string) string {
+
+	runes := []rune(text)
+	spans := matchSpans(runes, terms)
+	start := 0
+	if len(spans) > 0 && spans[0].start > snippetLength/4 {
+		start = spans[0].start - snippetLength/4
+	}
+	end := min(len(runes), start+snippetLength)
+
+	var visible []span
+	for _, s := range spans {
+		if s.start >= start && s.end <= end {
+			visible = append(visible, span{s.start - start, s.end - start})
+		}
+	}
+	snippet := mark(runes[start:end], visible)
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+type span struct{ start, end int }
+
+// matchSpans 不区分大小写地查找所有搜索词出现的位置（按字符），重叠的位置合并
+func matchSpans(runes []rune, terms []string) []span {
+
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	var spans []span
+	for _, term := range terms {
+		t := []rune(term)
+		if len(t) == 0 {
+			continue
+		}
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) == term {
+				spans = append(spans, span{i, i + len(t)})
+			}
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	merged := spans[:0]
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s.start <= merged[n-1].end {
+			merged[n-1].end = max(merged[n-1].end, s.end)
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+func mark(runes []rune, spans []span) string {
+
+	var sb strings.Builder
+	pos := 0
+	for _, s := range spans {
+		sb.WriteString(html.EscapeString(string(runes[pos:s.start])))
+		sb.WriteString("<mark>")
+		sb.WriteString(html.EscapeString(string(runes[s.start:s.end])))
+		sb.WriteString("</mark>")
+		pos = s.end
+	}
+	sb.WriteString(html.EscapeString(string(runes[pos:])))
+	return sb.String()
+}
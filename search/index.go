@@ -0,0 +1,315 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jheader/golang_blog/model"
+	"gorm.io/gorm"
+)
+
+// BM25 参数，标题中的匹配权重是正文的两倍
+const (
+	bm25K1      = 1.2
+	bm25B       = 0.75
+	titleWeight = 2.0
+)
+
+type docKey struct {
+	kind string
+	id   uint
+}
+
+type termFreq struct {
+	title, content int
+}
+
+// indexedDoc 索引中的一篇文章或一条评论，评论的可见性和标签取自所属文章
+type indexedDoc struct {
+	key        docKey
+	postID     uint
+	authorID   uint
+	author     string
+	slug       string
+	title      string
+	content    string
+	createdAt  time.Time
+	status     string
+	publishAt  *time.Time
+	tagIDs     []uint
+	titleLen   int
+	contentLen int
+	terms      []string
+}
+
+// Index 进程内的倒排索引，用于 SQLite、PostgreSQL 和本地开发。
+// 数据只在内存中，启动时通过 Load 从数据库重建
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[docKey]*indexedDoc
+	postings map[string]map[docKey]termFreq
+	// 各字段的总长度，用于计算 BM25 的平均长度
+	titleTotal, contentTotal int
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[docKey]*indexedDoc),
+		postings: make(map[string]map[docKey]termFreq),
+	}
+}
+
+// Load 从数据库加载全部文章和评论（不含已删除的）
+func (x *Index) Load(db *gorm.DB) error {
+
+	var posts []model.Post
+	err := db.Preload("User").Preload("Tags").FindInBatches(&posts, 500, func(tx *gorm.DB, batch int) error {
+		for i := range posts {
+			if err := x.IndexPost(&posts[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return fmt.Errorf("加载文章索引失败：%w", err)
+	}
+
+	var comments []model.Comment
+	err = db.Preload("User").FindInBatches(&comments, 500, func(tx *gorm.DB, batch int) error {
+		for i := range comments {
+			if err := x.IndexComment(&comments[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return fmt.Errorf("加载评论索引失败：%w", err)
+	}
+	return nil
+}
+
+func (x *Index) IndexPost(p *model.Post) error {
+
+	tagIDs := make([]uint, 0, len(p.Tags))
+	for _, t := range p.Tags {
+		tagIDs = append(tagIDs, t.ID)
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.put(&indexedDoc{
+		key:       docKey{TypePost, p.ID},
+		postID:    p.ID,
+		authorID:  p.UserID,
+		author:    p.User.Username,
+		slug:      p.Slug,
+		title:     p.Title,
+		content:   p.Content,
+		createdAt: p.CreatedAt,
+		status:    p.Status,
+		publishAt: p.PublishAt,
+		tagIDs:    tagIDs,
+	})
+	return nil
+}
+
+func (x *Index) DeletePost(id uint) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for key, doc := range x.docs {
+		if doc.postID == id {
+			x.remove(key)
+		}
+	}
+	return nil
+}
+
+func (x *Index) IndexComment(c *model.Comment) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.put(&indexedDoc{
+		key:       docKey{TypeComment, c.ID},
+		postID:    c.PostID,
+		authorID:  c.UserID,
+		author:    c.User.Username,
+		content:   c.Content,
+		createdAt: c.CreatedAt,
+	})
+	return nil
+}
+
+func (x *Index) DeleteComment(id uint) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.remove(docKey{TypeComment, id})
+	return nil
+}
+
+func (x *Index) MergeTags(sourceIDs []uint, targetID uint) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for _, doc := range x.docs {
+		merged := doc.tagIDs[:0]
+		for _, id := range doc.tagIDs {
+			if slices.Contains(sourceIDs, id) {
+				id = targetID
+			}
+			if !slices.Contains(merged, id) {
+				merged = append(merged, id)
+			}
+		}
+		doc.tagIDs = merged
+	}
+	return nil
+}
+
+// put 写入文档，已存在时先删除旧的倒排记录，调用方需持有写锁
+func (x *Index) put(doc *indexedDoc) {
+
+	x.remove(doc.key)
+
+	freqs := make(map[string]termFreq)
+	titleTokens, contentTokens := Tokenize(doc.title), Tokenize(doc.content)
+	for _, t := range titleTokens {
+		f := freqs[t]
+		f.title++
+		freqs[t] = f
+	}
+	for _, t := range contentTokens {
+		f := freqs[t]
+		f.content++
+		freqs[t] = f
+	}
+	doc.titleLen, doc.contentLen = len(titleTokens), len(contentTokens)
+	doc.terms = make([]string, 0, len(freqs))
+	for term, f := range freqs {
+		if x.postings[term] == nil {
+			x.postings[term] = make(map[docKey]termFreq)
+		}
+		x.postings[term][doc.key] = f
+		doc.terms = append(doc.terms, term)
+	}
+	x.docs[doc.key] = doc
+	x.titleTotal += doc.titleLen
+	x.contentTotal += doc.contentLen
+}
+
+func (x *Index) remove(key docKey) {
+
+	doc, ok := x.docs[key]
+	if !ok {
+		return
+	}
+	for _, term := range doc.terms {
+		delete(x.postings[term], key)
+		if len(x.postings[term]) == 0 {
+			delete(x.postings, term)
+		}
+	}
+	x.titleTotal -= doc.titleLen
+	x.contentTotal -= doc.contentLen
+	delete(x.docs, key)
+}
+
+type scoredDoc struct {
+	doc   *indexedDoc
+	score float64
+}
+
+// Search 按 BM25 相关度排序，包含任意一个搜索词的文档都会返回
+func (x *Index) Search(q Query) ([]Hit, int64, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	terms := uniqueTerms(Tokenize(q.Text))
+	n := float64(len(x.docs))
+	if n == 0 || len(terms) == 0 {
+		return []Hit{}, 0, nil
+	}
+	avgTitle := math.Max(float64(x.titleTotal)/n, 1)
+	avgContent := math.Max(float64(x.contentTotal)/n, 1)
+
+	scores := make(map[docKey]float64)
+	for _, term := range terms {
+		postings := x.postings[term]
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for key, f := range postings {
+			doc := x.docs[key]
+			score := titleWeight * bm25(f.title, doc.titleLen, avgTitle)
+			score += bm25(f.content, doc.contentLen, avgContent)
+			scores[key] += idf * score
+		}
+	}
+
+	now := time.Now()
+	var matched []scoredDoc
+	for key, score := range scores {
+		doc := x.docs[key]
+		post := x.docs[docKey{TypePost, doc.postID}]
+		if post == nil || !visible(post.status, post.publishAt, now) {
+			continue
+		}
+		if q.AuthorID != 0 && doc.authorID != q.AuthorID {
+			continue
+		}
+		if q.TagID != 0 && !slices.Contains(post.tagIDs, q.TagID) {
+			continue
+		}
+		if !inRange(doc.createdAt, q) {
+			continue
+		}
+		matched = append(matched, scoredDoc{doc, score})
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].score != matched[j].score {
+			return matched[i].score > matched[j].score
+		}
+		return matched[i].doc.createdAt.After(matched[j].doc.createdAt)
+	})
+
+	// 页码过大时乘法可能溢出成负数，超出结果范围的页一律返回空列表
+	start := len(matched)
+	if q.Page >= 1 && q.Size > 0 && q.Page-1 <= len(matched)/q.Size {
+		start = min(len(matched), (q.Page-1)*q.Size)
+	}
+	end := start + min(len(matched)-start, max(q.Size, 0))
+	hits := make([]Hit, 0, end-start)
+	for _, m := range matched[start:end] {
+		post := x.docs[docKey{TypePost, m.doc.postID}]
+		hit := Hit{
+			Type:      m.doc.key.kind,
+			PostID:    m.doc.postID,
+			Slug:      post.slug,
+			Title:     Highlight(post.title, terms),
+			Snippet:   Snippet(m.doc.content, terms),
+			AuthorID:  m.doc.authorID,
+			Author:    m.doc.author,
+			Score:     math.Round(m.score*1000) / 1000,
+			CreatedAt: m.doc.createdAt,
+		}
+		if hit.Type == TypeComment {
+			hit.CommentID = m.doc.key.id
+		}
+		hits = append(hits, hit)
+	}
+	return hits, int64(len(matched)), nil
+}
+
+func bm25(tf, length int, avgLength float64) float64 {
+	if tf == 0 {
+		return 0
+	}
+	norm := bm25K1 * (1 - bm25B + bm25B*float64(length)/avgLength)
+	return float64(tf) * (bm25K1 + 1) / (float64(tf) + norm)
+}
@@ -0,0 +1,108 @@
+// Package search 文章和评论的全文搜索。
+// MySQL 使用 FULLTEXT 索引（ngram 分词，支持中文），其他数据库使用进程内的倒排索引，
+// 启动时从数据库加载，文章和评论变更时由 service 层同步
+package search
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jheader/golang_blog/model"
+	"gorm.io/gorm"
+)
+
+// 搜索结果的类型
+const (
+	TypePost    = "post"
+	TypeComment = "comment"
+)
+
+// 搜索引擎
+const (
+	EngineAuto     = "auto"
+	EngineFulltext = "fulltext"
+	EngineMemory   = "memory"
+)
+
+// Searcher 全文搜索。只有已发布文章及其评论会出现在结果中
+type Searcher interface {
+	// IndexPost 新增或更新文章的索引，需要加载 User 和 Tags
+	IndexPost(p *model.Post) error
+	// DeletePost 删除文章及其评论的索引
+	DeletePost(id uint) error
+	// IndexComment 新增或更新评论的索引，需要加载 User
+	IndexComment(c *model.Comment) error
+	DeleteComment(id uint) error
+	// MergeTags 标签合并后更新文章的标签
+	MergeTags(sourceIDs []uint, targetID uint) error
+	Search(q Query) ([]Hit, int64, error)
+}
+
+// Query 搜索条件，AuthorID、TagID 为0以及 From、To 为 nil 时不过滤
+type Query struct {
+	Text     string
+	AuthorID uint
+	TagID    uint
+	From     *time.Time
+	To       *time.Time
+	Page     int
+	Size     int
+}
+
+// Hit 一条搜索结果，Title 和 Snippet 中匹配的词用 <mark></mark> 包裹，其余内容已做 HTML 转义
+type Hit struct {
+	Type      string    `json:"type"`
+	PostID    uint      `json:"post_id"`
+	CommentID uint      `json:"comment_id,omitempty"`
+	Slug      string    `json:"slug"`
+	Title     string    `json:"title"`
+	Snippet   string    `json:"snippet"`
+	AuthorID  uint      `json:"author_id"`
+	Author    string    `json:"author"`
+	Score     float64   `json:"score"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// New 根据配置创建搜索引擎：auto 时 MySQL 使用 FULLTEXT，其他数据库使用内存索引
+func New(db *gorm.DB, engine string) (Searcher, error) {
+
+	if engine == "" || engine == EngineAuto {
+		engine = EngineMemory
+		if db.Dialector.Name() == "mysql" {
+			engine = EngineFulltext
+		}
+	}
+	switch engine {
+	case EngineFulltext:
+		if db.Dialector.Name() != "mysql" {
+			return nil, fmt.Errorf("search engine %q requires mysql, got %s", engine, db.Dialector.Name())
+		}
+		return NewFulltextSearcher(db)
+	case EngineMemory:
+		index := NewIndex()
+		if err := index.Load(db); err != nil {
+			return nil, err
+		}
+		return index, nil
+	default:
+		return nil, fmt.Errorf("unsupported SEARCH_ENGINE %q (auto, fulltext, memory)", engine)
+	}
+}
+
+// visible 已发布，或者定时发布且已到发布时间（调度器可能还没来得及更新状态）
+func visible(status string, publishAt *time.Time, now time.Time) bool {
+	if status == model.PostStatusPublished {
+		return true
+	}
+	return status == model.PostStatusScheduled && publishAt != nil && !publishAt.After(now)
+}
+
+func inRange(t time.Time, q Query) bool {
+	if q.From != nil && t.Before(*q.From) {
+		return false
+	}
+	if q.To != nil && t.After(*q.To) {
+		return false
+	}
+	return true
+}
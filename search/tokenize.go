@@ -0,0 +1,61 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenize 分词：字母和数字组成的词转为小写；连续的汉字按二元组切分（"数据库" -> "数据"、"据库"），
+// 单个汉字作为一个词。与 MySQL ngram 解析器（ngram_token_size=2）的规则一致
+func Tokenize(text string) []string {
+
+	var tokens []string
+	var word strings.Builder
+	var han []rune
+
+	flushWord := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	flushHan := func() {
+		if len(han) == 1 {
+			tokens = append(tokens, string(han))
+		}
+		for i := 0; i+1 < len(han); i++ {
+			tokens = append(tokens, string(han[i:i+2]))
+		}
+		han = han[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word.WriteRune(unicode.ToLower(r))
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return tokens
+}
+
+// uniqueTerms 去重并保持顺序
+func uniqueTerms(tokens []string) []string {
+	seen := make(map[string]bool, len(tokens))
+	terms := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		if !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
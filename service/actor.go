@@ -0,0 +1,14 @@
+package service
+
+import "slices"
+
+// Actor 发起操作的当前用户，由 controller 根据访问令牌构造
+type Actor struct {
+	UserID      uint
+	Permissions []string
+}
+
+// Can 是否拥有某个权限，例如 model.PermCommentsDeleteAny
+func (a Actor) Can(permission string) bool {
+	return slices.Contains(a.Permissions, permission)
+}
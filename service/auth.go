@@ -0,0 +1,325 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jheader/golang_blog/mail"
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/repository"
+	"github.com/jheader/golang_blog/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+type AuthService struct {
+	users  repository.UserRepository
+	tokens repository.TokenRepository
+	// 发送邮箱验证、重置密码邮件
+	mailer mail.Mailer
+	// 按客户端IP统计的登录失败次数
+	limiter *utils.LoginLimiter
+}
+
+func NewAuthService(users repository.UserRepository, tokens repository.TokenRepository, mailer mail.Mailer) *AuthService {
+	return &AuthService{
+		users:   users,
+		tokens:  tokens,
+		mailer:  mailer,
+		limiter: utils.NewLoginLimiter(loginMaxAttempts(), loginLockDuration()),
+	}
+}
+
+func loginMaxAttempts() int {
+	return viper.GetInt("LOGIN_MAX_ATTEMPTS")
+}
+
+func loginLockDuration() time.Duration {
+	return time.Duration(viper.GetInt("LOGIN_LOCK_MINUTES")) * time.Minute
+}
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	record       model.RefreshToken
+
+	// MFAToken 开启了两步验证的账号密码校验通过后只返回该字段，用它和验证码换取令牌对
+	MFAToken string
+}
+
+func (s *AuthService) Register(username, email, password string) (*model.User, *TokenPair, error) {
+
+	//查询用户是否存在
+	if _, err := s.users.FindByUsername(username); err == nil {
+		return nil, nil, conflictError("username_taken", username+" already exists")
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, err
+	}
+
+	// 检查邮箱是否已存在
+	if _, err := s.users.FindByEmail(email); err == nil {
+		return nil, nil, conflictError("email_taken", "Email already exists")
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, err
+	}
+
+	// 创建新用户
+	user := model.User{
+		Username: username,
+		Email:    email,
+		Password: password, // 密码会在BeforeCreate钩子中自动加密
+		Role:     model.RoleUser,
+	}
+	if err := s.users.Create(&user); err != nil {
+		// 并发注册时由唯一索引兜底
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, nil, conflictError("user_exists", "username or email already exists")
+		}
+		return nil, nil, err
+	}
+	s.sendVerification(&user)
+
+	pair, err := s.issueTokens(&user, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return &user, pair, nil
+}
+
+// Login 校验用户名（或邮箱）和密码，不区分大小写；连续失败达到上限后按用户和IP分别锁定
+func (s *AuthService) Login(login, password, ip string) (*model.User, *TokenPair, error) {
+
+	if locked, until := s.limiter.Locked(ip); locked {
+		return nil, nil, unauthorizedError("account_locked", "too many failed login attempts, try again after "+until.Format(time.RFC3339))
+	}
+
+	//查询用户是否存在，用户名不能包含 @，包含 @ 的按邮箱查询
+	u, err := s.findByLogin(login)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			return nil, nil, err
+		}
+		// 用户不存在与密码错误返回相同的错误，并同样做一次 bcrypt 比较，不能据此探测用户名或邮箱是否注册
+		s.limiter.Fail(ip)
+		dummyUser.CheckPassword(password)
+		return nil, nil, invalidCredentialsError()
+	}
+
+	if u.IsLocked(time.Now()) {
+		return nil, nil, accountLockedError(u)
+	}
+
+	// 校验密码
+	if !u.CheckPassword(password) {
+		if err := s.recordLoginFailure(u, ip); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, invalidCredentialsError()
+	}
+
+	s.limiter.Reset(ip)
+	if u.ClearLoginFailures() {
+		if err := s.users.UpdateLoginState(u); err != nil {
+			logrus.Error(err)
+		}
+	}
+	// 密码正确之后才提示账号被停用或需要重置，避免泄露账号状态
+	if u.IsDisabled() {
+		return nil, nil, accountDisabledError()
+	}
+	if u.MustResetPassword {
+		return nil, nil, forbiddenError("password_reset_required", "password must be reset before logging in")
+	}
+
+	if u.MFAEnabled() {
+		mfaToken, err := s.issueActionToken(u, model.TokenPurposeMFAPending, utils.MFAPendingTTL())
+		if err != nil {
+			return nil, nil, err
+		}
+		return u, &TokenPair{MFAToken: mfaToken}, nil
+	}
+
+	pair, err := s.issueTokens(u, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return u, pair, nil
+}
+
+func (s *AuthService) findByLogin(login string) (*model.User, error) {
+	if strings.Contains(login, "@") {
+		return s.users.FindByEmail(login)
+	}
+	return s.users.FindByUsername(login)
+}
+
+// dummyUser 用户不存在时用于比较密码，使响应时间与密码错误时一致
+var dummyUser = &model.User{Password: "$2a$10$vDt60awwgu7CvlgHp2Y.3.M79.yfpgKoirDl6CXfufH98htzcMWcK"}
+
+// recordLoginFailure 记录一次登录失败（IP 和用户两个维度），账号因此被锁定时返回 account_locked
+func (s *AuthService) recordLoginFailure(u *model.User, ip string) error {
+
+	s.limiter.Fail(ip)
+	lockedUntil, err := s.users.RegisterLoginFailure(u.ID, loginMaxAttempts(), loginLockDuration())
+	if err != nil {
+		logrus.Error(err)
+		return nil
+	}
+	u.LockedUntil = lockedUntil
+	if u.IsLocked(time.Now()) {
+		return accountLockedError(u)
+	}
+	return nil
+}
+
+func invalidCredentialsError() error {
+	return unauthorizedError("invalid_credentials", "invalid username or password")
+}
+
+func accountLockedError(u *model.User) error {
+	return unauthorizedError("account_locked", "account is locked until "+u.LockedUntil.Format(time.RFC3339))
+}
+
+func accountDisabledError() error {
+	return forbiddenError("account_disabled", "account has been disabled")
+}
+
+// Refresh 用刷新令牌换取新的令牌对，旧刷新令牌随即失效（轮换）。
+// 已失效的刷新令牌被再次使用说明可能被盗用，吊销整个令牌族
+func (s *AuthService) Refresh(refreshToken, ip string) (*TokenPair, error) {
+
+	old, err := s.tokens.FindRefreshTokenByHash(utils.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, unauthorizedError("invalid_refresh_token", "invalid refresh token")
+		}
+		return nil, err
+	}
+
+	if old.RevokedAt != nil {
+		logrus.WithFields(logrus.Fields{
+			"user_id":   old.UserID,
+			"family_id": old.FamilyID,
+			"client_ip": ip,
+		}).Warn("refresh token reuse detected, revoking token family")
+		if err := s.tokens.RevokeFamily(old.FamilyID); err != nil {
+			logrus.Error(err)
+		}
+		return nil, unauthorizedError("refresh_token_revoked", "refresh token has been revoked")
+	}
+	if !old.IsActive(time.Now()) {
+		return nil, unauthorizedError("refresh_token_expired", "refresh token has expired")
+	}
+
+	// 用户已被删除时刷新令牌也随之失效
+	user, err := s.users.FindByID(old.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, unauthorizedError("invalid_refresh_token", "invalid refresh token")
+		}
+		return nil, err
+	}
+	if user.IsDisabled() {
+		return nil, accountDisabledError()
+	}
+
+	// 先原子地吊销旧令牌，并发刷新时只有一个请求能成功
+	rotated, err := s.tokens.RevokeRefreshToken(old.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		return nil, unauthorizedError("refresh_token_revoked", "refresh token has been revoked")
+	}
+
+	pair, err := s.issueTokens(user, old.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tokens.SetReplacedBy(old.ID, pair.record.ID); err != nil {
+		logrus.Error(err)
+	}
+	return pair, nil
+}
+
+// ChangePassword 校验当前密码后修改密码，吊销该用户所有的刷新令牌，
+// 此前签发的访问令牌由 AuthMiddleware 拒绝；返回新的令牌对，当前会话不必重新登录
+func (s *AuthService) ChangePassword(userID uint, currentPassword, newPassword string) (*TokenPair, error) {
+
+	u, err := s.users.FindByID(userID)
+	if err != nil {
+		return nil, userLookupError(err)
+	}
+	if !u.CheckPassword(currentPassword) {
+		return nil, forbiddenError("incorrect_password", "current password is incorrect")
+	}
+	if currentPassword == newPassword {
+		return nil, validationError("password_unchanged", "new password must be different from the current password")
+	}
+
+	if err := s.users.UpdatePassword(userID, newPassword); err != nil {
+		return nil, err
+	}
+	if err := s.tokens.RevokeUser(userID); err != nil {
+		return nil, err
+	}
+	return s.issueTokens(u, "")
+}
+
+// Logout 吊销刷新令牌所在的令牌族；accessToken 非空时同时将其 jti 拉黑
+func (s *AuthService) Logout(refreshToken, accessToken string) error {
+
+	rt, err := s.tokens.FindRefreshTokenByHash(utils.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return unauthorizedError("invalid_refresh_token", "invalid refresh token")
+		}
+		return err
+	}
+	if err := s.tokens.RevokeFamily(rt.FamilyID); err != nil {
+		return err
+	}
+
+	if accessToken != "" {
+		if claims, err := utils.ParseToken(accessToken); err == nil && claims.UserID == rt.UserID {
+			if err := s.tokens.RevokeAccessToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+				logrus.Error(err)
+			}
+		}
+	}
+	return nil
+}
+
+// issueTokens 签发访问令牌和刷新令牌，familyID 为空时开启新的令牌族
+func (s *AuthService) issueTokens(u *model.User, familyID string) (*TokenPair, error) {
+
+	token, err := utils.GenerateToken(u.ID, u.Username, u.Role, u.EffectivePermissions())
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	if familyID == "" {
+		if familyID, err = utils.RandomToken(16); err != nil {
+			return nil, err
+		}
+	}
+	pair := &TokenPair{
+		AccessToken:  token,
+		RefreshToken: refreshToken,
+		record: model.RefreshToken{
+			UserID:    u.ID,
+			TokenHash: utils.HashToken(refreshToken),
+			FamilyID:  familyID,
+			ExpiresAt: time.Now().Add(utils.RefreshTokenTTL()),
+		},
+	}
+	if err := s.tokens.CreateRefreshToken(&pair.record); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
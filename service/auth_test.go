@@ -0,0 +1,96 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jheader/golang_blog/mail"
+	"github.com/jheader/golang_blog/repository"
+)
+
+func TestLoginLocksAccountAfterRepeatedFailures(t *testing.T) {
+
+	repos := repository.NewMemoryRepositories()
+	auth := NewAuthService(repos.Users, repos.Tokens, &mail.LogMailer{})
+	createUser(t, repos, "alice")
+
+	// 每次换一个 IP，只触发账号维度的锁定（LOGIN_MAX_ATTEMPTS=3）
+	for i := 1; i < 3; i++ {
+		_, _, err := auth.Login("alice", "wrong", fmt.Sprintf("10.0.0.%d", i))
+		assertAppError(t, err, "invalid_credentials")
+	}
+	_, _, err := auth.Login("alice", "wrong", "10.0.0.3")
+	assertAppError(t, err, "account_locked")
+	// 锁定期间密码正确也不能登录
+	_, _, err = auth.Login("ALICE", "secret123", "10.0.0.4")
+	assertAppError(t, err, "account_locked")
+
+	u, err := repos.Users.FindByUsername("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.LockedUntil == nil || u.FailedLoginAttempts != 0 {
+		t.Fatalf("expected locked account with reset counter, got locked_until %v, attempts %d", u.LockedUntil, u.FailedLoginAttempts)
+	}
+}
+
+func TestLoginLocksIPAndHidesUnknownAccounts(t *testing.T) {
+
+	repos := repository.NewMemoryRepositories()
+	auth := NewAuthService(repos.Users, repos.Tokens, &mail.LogMailer{})
+	createUser(t, repos, "alice")
+
+	// 不存在的用户与密码错误返回相同的错误，同样计入 IP 的失败次数
+	for range 3 {
+		_, _, err := auth.Login("nobody", "secret123", "10.0.0.1")
+		assertAppError(t, err, "invalid_credentials")
+	}
+	_, _, err := auth.Login("alice", "secret123", "10.0.0.1")
+	assertAppError(t, err, "account_locked")
+
+	// 其他 IP 不受影响，账号本身没有被锁定
+	_, pair, err := auth.Login("alice", "secret123", "10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair.AccessToken == "" || pair.RefreshToken == "" {
+		t.Fatal("expected a token pair")
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+
+	repos := repository.NewMemoryRepositories()
+	auth := NewAuthService(repos.Users, repos.Tokens, &mail.LogMailer{})
+	createUser(t, repos, "alice")
+
+	_, first, err := auth.Login("alice", "secret123", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := auth.Refresh(first.RefreshToken, "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	// 已轮换的令牌再次使用：拒绝，并吊销整个令牌族，包括刚签发的新令牌
+	_, err = auth.Refresh(first.RefreshToken, "10.0.0.2")
+	assertAppError(t, err, "refresh_token_revoked")
+	_, err = auth.Refresh(second.RefreshToken, "10.0.0.1")
+	assertAppError(t, err, "refresh_token_revoked")
+
+	_, err = auth.Refresh("not-a-token", "10.0.0.1")
+	assertAppError(t, err, "invalid_refresh_token")
+
+	// 重新登录开始一个新的令牌族，不受影响
+	_, fresh, err := auth.Login("alice", "secret123", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Refresh(fresh.RefreshToken, "10.0.0.1"); err != nil {
+		t.Fatalf("refresh in a new family: %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jheader/golang_blog/dto"
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/repository"
+	"github.com/jheader/golang_blog/search"
+	"github.com/jheader/golang_blog/utils"
+)
+
+type CommentService struct {
+	comments repository.CommentRepository
+	posts    repository.PostRepository
+	searcher search.Searcher
+}
+
+func NewCommentService(comments repository.CommentRepository, posts repository.PostRepository, searcher search.Searcher) *CommentService {
+	return &CommentService{comments: comments, posts: posts, searcher: searcher}
+}
+
+// Create 发表评论，parentID 不为空时回复该评论，被回复的评论必须属于同一篇文章
+func (s *CommentService) Create(userID, postID uint, parentID *uint, content string) (*model.Comment, error) {
+
+	if strings.TrimSpace(content) == "" {
+		return nil, validationError("content_required", "评论内容不能为空")
+	}
+	// 检查文章是否存在，未发布的文章不能评论
+	if err := s.checkPublished(postID); err != nil {
+		return nil, err
+	}
+	if parentID != nil {
+		parent, err := s.comments.FindByID(*parentID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, notFoundError("parent_not_found", fmt.Sprintf("回复的评论 %d 不存在", *parentID))
+		}
+		if err != nil {
+			return nil, err
+		}
+		if parent.PostID != postID {
+			return nil, validationError("parent_post_mismatch", "回复的评论不属于这篇文章")
+		}
+	}
+	comment := model.Comment{
+		Content:  content,
+		UserID:   userID,
+		PostID:   postID,
+		ParentID: parentID,
+	}
+	if err := s.comments.Create(&comment); err != nil {
+		return nil, err
+	}
+	// 预加载用户信息
+	created, err := s.comments.FindByID(comment.ID)
+	if err != nil {
+		return nil, err
+	}
+	syncIndex(s.searcher.IndexComment(created), search.TypeComment, created.ID)
+	return created, nil
+}
+
+// Update 修改评论内容，评论作者或拥有 comments:update:any 权限的用户可以修改
+func (s *CommentService) Update(actor Actor, commentID uint, content string) (*model.Comment, error) {
+
+	if strings.TrimSpace(content) == "" {
+		return nil, validationError("content_required", "评论内容不能为空")
+	}
+	comment, err := s.findComment(commentID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.UserID != actor.UserID && !actor.Can(model.PermCommentsUpdateAny) {
+		return nil, forbiddenError("comment_not_owned", "只能修改自己的评论")
+	}
+	if err := s.comments.UpdateContent(commentID, content, time.Now()); err != nil {
+		return nil, commentLookupError(err)
+	}
+	updated, err := s.comments.FindByID(commentID)
+	if err != nil {
+		return nil, commentLookupError(err)
+	}
+	syncIndex(s.searcher.IndexComment(updated), search.TypeComment, updated.ID)
+	return updated, nil
+}
+
+// Delete 软删除评论，评论作者、文章作者或拥有 comments:delete:any 权限的用户（版主、管理员）可以删除。
+// 已有的回复不受影响，评论树中被删除的评论显示为 "[deleted]"
+func (s *CommentService) Delete(actor Actor, commentID uint) error {
+
+	comment, err := s.findComment(commentID)
+	if err != nil {
+		return err
+	}
+	if comment.UserID != actor.UserID && !actor.Can(model.PermCommentsDeleteAny) {
+		post, err := s.posts.FindByID(comment.PostID)
+		if err != nil {
+			return postLookupError(err)
+		}
+		if post.UserID != actor.UserID {
+			return forbiddenError("comment_not_owned", "只能删除自己的评论或自己文章下的评论")
+		}
+	}
+	if err := s.comments.Delete(commentID); err != nil {
+		return commentLookupError(err)
+	}
+	syncIndex(s.searcher.DeleteComment(commentID), search.TypeComment, commentID)
+	return nil
+}
+
+func (s *CommentService) findComment(id uint) (*model.Comment, error) {
+
+	comment, err := s.comments.FindByID(id)
+	if err != nil {
+		return nil, commentLookupError(err)
+	}
+	return comment, nil
+}
+
+func commentLookupError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return notFoundError("comment_not_found", "评论不存在或已被删除")
+	}
+	return err
+}
+
+// 评论树默认展开的层数和允许的最大层数
+const (
+	DefaultThreadDepth = 3
+	MaxThreadDepth     = 10
+)
+
+// Thread 分页列出顶层评论（parentID 不为空时为该评论的回复），每个线程向下展开 depth 层。
+// 每层只需一次查询，回复数用一条聚合查询统计；已删除但子树中（任意深度）仍有未删除回复的评论显示为 "[deleted]"
+func (s *CommentService) Thread(postID uint, parentID *uint, depth, page, size int) ([]*dto.CommentNode, int64, error) {
+
+	if depth < 1 || depth > MaxThreadDepth {
+		return nil, 0, validationError("invalid_depth", fmt.Sprintf("depth 必须在 1 到 %d 之间", MaxThreadDepth))
+	}
+	if err := s.checkPublished(postID); err != nil {
+		return nil, 0, err
+	}
+	roots, total, err := s.comments.ListThreads(postID, parentID, page, size)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	nodes := make([]*dto.CommentNode, 0, len(roots))
+	byID := make(map[uint]*dto.CommentNode)
+	level := make([]uint, 0, len(roots))
+	for _, c := range roots {
+		node := &dto.CommentNode{Comment: dto.NewComment(c), Depth: 1, Replies: []*dto.CommentNode{}}
+		nodes = append(nodes, node)
+		byID[c.ID] = node
+		level = append(level, c.ID)
+	}
+	for d := 2; d <= depth && len(level) > 0; d++ {
+		replies, err := s.comments.ListReplies(level)
+		if err != nil {
+			return nil, 0, err
+		}
+		level = level[:0]
+		for _, c := range replies {
+			node := &dto.CommentNode{Comment: dto.NewComment(c), Depth: d, Replies: []*dto.CommentNode{}}
+			parent := byID[*c.ParentID]
+			parent.Replies = append(parent.Replies, node)
+			byID[c.ID] = node
+			level = append(level, c.ID)
+		}
+	}
+
+	ids := make([]uint, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	counts, err := s.comments.CountReplies(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for id, node := range byID {
+		node.ReplyCount = counts[id]
+	}
+	return nodes, total, nil
+}
+
+// CommentListSpec 评论列表允许的排序、过滤和返回字段
+var CommentListSpec = utils.ListSpec{
+	Sorts: map[string]string{
+		"id":         "id",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+	DefaultSort: "created_at",
+	Filters: map[string]utils.FilterSpec{
+		"author_id":      {Column: "user_id", Op: "=", Type: utils.FilterUint},
+		"created_after":  {Column: "created_at", Op: ">=", Type: utils.FilterTime},
+		"created_before": {Column: "created_at", Op: "<", Type: utils.FilterTime},
+	},
+	// 对应 dto.Comment 的 JSON 字段
+	Fields: []string{"id", "content", "post_id", "author", "created_at", "updated_at"},
+}
+
+// ListByPost 分页列出已发布文章的评论，q 由 utils.ParseListQuery 按 CommentListSpec 解析
+func (s *CommentService) ListByPost(postID uint, q utils.ListQuery, page, size int) ([]dto.Comment, int64, error) {
+
+	if err := s.checkPublished(postID); err != nil {
+		return nil, 0, err
+	}
+	comments, total, err := s.comments.ListByPost(postID, q, page, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	return dto.NewComments(comments), total, nil
+}
+
+// ListByPostCursor 游标分页列出已发布文章的评论
+func (s *CommentService) ListByPostCursor(postID uint, q utils.ListQuery, cq utils.CursorQuery) ([]dto.Comment, error) {
+
+	if err := s.checkPublished(postID); err != nil {
+		return nil, err
+	}
+	comments, err := s.comments.ListByPostCursor(postID, q, cq)
+	if err != nil {
+		return nil, err
+	}
+	return dto.NewComments(comments), nil
+}
+
+func (s *CommentService) checkPublished(postID uint) error {
+
+	post, err := s.posts.FindByID(postID)
+	if err != nil {
+		return postLookupError(err)
+	}
+	if !post.IsPublished() {
+		return postLookupError(repository.ErrNotFound)
+	}
+	return nil
+}
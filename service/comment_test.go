@@ -0,0 +1,91 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/jheader/golang_blog/dto"
+	"github.com/jheader/golang_blog/repository"
+	"github.com/jheader/golang_blog/search"
+)
+
+// threadLabels 按深度优先顺序列出评论树中每条评论的内容，已删除的评论带 * 标记
+func threadLabels(nodes []*dto.CommentNode) []string {
+	var out []string
+	for _, n := range nodes {
+		label := n.Content
+		if n.Deleted {
+			label += "*"
+		}
+		out = append(out, label)
+		out = append(out, threadLabels(n.Replies)...)
+	}
+	return out
+}
+
+func TestThreadKeepsDeletedAncestorsOfLiveReplies(t *testing.T) {
+
+	repos := repository.NewMemoryRepositories()
+	comments := NewCommentService(repos.Comments, repos.Posts, search.NewIndex())
+	alice := createUser(t, repos, "alice")
+	post := createPost(t, repos, alice, "Thread")
+
+	// c1 <- c2 <- c3 <- c4，另有一条独立的顶层评论 other
+	var parent *uint
+	ids := make([]uint, 0, 4)
+	for _, content := range []string{"c1", "c2", "c3", "c4"} {
+		c, err := comments.Create(alice.ID, post.ID, parent, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, c.ID)
+		parent = &c.ID
+	}
+	if _, err := comments.Create(alice.ID, post.ID, nil, "other"); err != nil {
+		t.Fatal(err)
+	}
+	actor := Actor{UserID: alice.ID}
+	for _, id := range ids[:3] {
+		if err := comments.Delete(actor, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	nodes, total, err := comments.Thread(post.ID, nil, MaxThreadDepth, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := threadLabels(nodes)
+	want := []string{"[deleted]*", "[deleted]*", "[deleted]*", "c4", "other"}
+	if total != 2 || !slices.Equal(got, want) {
+		t.Fatalf("thread with live reply at depth 4: total %d, got %v, want %v", total, got, want)
+	}
+	// 只展开一层时，c1 的子树中仍有未删除的回复，占位和回复数都要保留
+	nodes, _, err = comments.Thread(post.ID, nil, 1, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 || !nodes[0].Deleted || nodes[0].ReplyCount != 1 {
+		t.Fatalf("depth=1: expected deleted placeholder with 1 reply first, got %v", threadLabels(nodes))
+	}
+	// 从 c2 开始展开，c3 也是占位
+	nodes, _, err = comments.Thread(post.ID, &ids[1], MaxThreadDepth, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := threadLabels(nodes); !slices.Equal(got, []string{"[deleted]*", "c4"}) {
+		t.Fatalf("replies of c2: got %v", got)
+	}
+
+	// 最深的回复也删除后，整条线程消失
+	if err := comments.Delete(actor, ids[3]); err != nil {
+		t.Fatal(err)
+	}
+	nodes, total, err = comments.Thread(post.ID, nil, MaxThreadDepth, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := threadLabels(nodes); total != 1 || !slices.Equal(got, []string{"other"}) {
+		t.Fatalf("fully deleted thread: total %d, got %v", total, got)
+	}
+}
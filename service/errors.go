@@ -0,0 +1,45 @@
+package service
+
+import "github.com/jheader/golang_blog/utils"
+
+// service 层返回的错误类别。定义放在 utils 中，避免 utils 与 service 循环引用；
+// 状态码和错误码的映射见 utils.RespondError
+var (
+	ErrValidation   = utils.ErrValidation
+	ErrUnauthorized = utils.ErrUnauthorized
+	ErrForbidden    = utils.ErrForbidden
+	ErrNotFound     = utils.ErrNotFound
+	ErrConflict     = utils.ErrConflict
+
+	ErrPreconditionFailed   = utils.ErrPreconditionFailed
+	ErrPreconditionRequired = utils.ErrPreconditionRequired
+	ErrUnprocessable        = utils.ErrUnprocessable
+)
+
+func validationError(code, message string) *utils.AppError {
+	return utils.NewAppError(ErrValidation, code, message)
+}
+
+func unauthorizedError(code, message string) *utils.AppError {
+	return utils.NewAppError(ErrUnauthorized, code, message)
+}
+
+func forbiddenError(code, message string) *utils.AppError {
+	return utils.NewAppError(ErrForbidden, code, message)
+}
+
+func notFoundError(code, message string) *utils.AppError {
+	return utils.NewAppError(ErrNotFound, code, message)
+}
+
+func preconditionFailedError(code, message string) *utils.AppError {
+	return utils.NewAppError(ErrPreconditionFailed, code, message)
+}
+
+func unprocessableError(code, message string) *utils.AppError {
+	return utils.NewAppError(ErrUnprocessable, code, message)
+}
+
+func conflictError(code, message string) *utils.AppError {
+	return utils.NewAppError(ErrConflict, code, message)
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/repository"
+	"github.com/jheader/golang_blog/utils"
+	"github.com/spf13/viper"
+)
+
+// TestMain 设置测试用的配置，不读取 .env
+func TestMain(m *testing.M) {
+	viper.Set("JWT_SECRET", "test-secret")
+	viper.Set("LOGIN_MAX_ATTEMPTS", 3)
+	viper.Set("LOGIN_LOCK_MINUTES", 15)
+	viper.Set("ACCESS_TOKEN_TTL_MINUTES", 120)
+	viper.Set("REFRESH_TOKEN_TTL_HOURS", 720)
+	viper.Set("MFA_ISSUER", "golang_blog")
+	viper.Set("MFA_PENDING_TTL_MINUTES", 5)
+	os.Exit(m.Run())
+}
+
+// createUser 直接通过仓储创建一个已验证邮箱的用户，密码为 secret123
+func createUser(t *testing.T, repos *repository.Repositories, username string) *model.User {
+	t.Helper()
+
+	verified := time.Now()
+	u := &model.User{Username: username, Email: username + "@example.com", Password: "secret123", EmailVerifiedAt: &verified}
+	if err := repos.Users.Create(u); err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return u
+}
+
+// createPost 以 author 的身份创建一篇已发布的文章
+func createPost(t *testing.T, repos *repository.Repositories, author *model.User, title string) *model.Post {
+	t.Helper()
+
+	p := &model.Post{Title: title, Content: title + " content", UserID: author.ID, Status: model.PostStatusPublished}
+	if err := repos.Posts.Create(p); err != nil {
+		t.Fatalf("create post %s: %v", title, err)
+	}
+	return p
+}
+
+// assertAppError 检查 err 是 AppError 且错误码为 code
+func assertAppError(t *testing.T, err error, code string) {
+	t.Helper()
+
+	var appErr *utils.AppError
+	if !errors.As(err, &appErr) {
+		t.Fatalf("expected AppError %q, got %v", code, err)
+	}
+	if appErr.Code != code {
+		t.Fatalf("expected error code %q, got %q (%s)", code, appErr.Code, appErr.Message)
+	}
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/repository"
+	"github.com/jheader/golang_blog/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// totpQRCodeSize 二维码图片的边长（像素）
+const totpQRCodeSize = 256
+
+// TOTPEnrollment 开启两步验证时返回给用户的密钥，用验证器应用扫描二维码或手动输入 Secret
+type TOTPEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+	// QRCode data:image/png;base64,... 格式，可直接用作 <img> 的 src
+	QRCode string `json:"qr_code"`
+}
+
+// EnrollTOTP 生成新的 TOTP 密钥，需要调用 ConfirmTOTP 提交验证码后才会生效。
+// 重复调用会替换尚未确认的密钥
+func (s *AuthService) EnrollTOTP(userID uint) (*TOTPEnrollment, error) {
+
+	u, err := s.users.FindByID(userID)
+	if err != nil {
+		return nil, userLookupError(err)
+	}
+	if u.MFAEnabled() {
+		return nil, conflictError("mfa_already_enabled", "two-factor authentication is already enabled")
+	}
+
+	key, err := utils.GenerateTOTPKey(u.Username)
+	if err != nil {
+		return nil, err
+	}
+	png, err := utils.TOTPQRCode(key, totpQRCodeSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.SetTOTPSecret(u.ID, key.Secret()); err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollment{
+		Secret:     key.Secret(),
+		OTPAuthURL: key.URL(),
+		QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+// ConfirmTOTP 用验证器应用生成的第一个验证码确认开启两步验证，返回恢复码。
+// 恢复码只返回这一次，数据库中只保存哈希
+func (s *AuthService) ConfirmTOTP(userID uint, code string) ([]string, error) {
+
+	u, err := s.users.FindByID(userID)
+	if err != nil {
+		return nil, userLookupError(err)
+	}
+	if u.MFAEnabled() {
+		return nil, conflictError("mfa_already_enabled", "two-factor authentication is already enabled")
+	}
+	if u.TOTPSecret == "" {
+		return nil, validationError("mfa_not_enrolled", "call POST /me/mfa/totp to generate a secret first")
+	}
+	step, ok := utils.MatchTOTP(u.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, validationError("invalid_mfa_code", "verification code is incorrect")
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.EnableTOTP(u.ID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP 关闭两步验证，需要再次输入密码，并提供当前的验证码或一个恢复码，
+// 避免拿到访问令牌和密码的人直接去掉第二个因素。还没有确认的开启只需要密码就能取消
+func (s *AuthService) DisableTOTP(userID uint, password, code, recoveryCode string) error {
+
+	u, err := s.users.FindByID(userID)
+	if err != nil {
+		return userLookupError(err)
+	}
+	if !u.CheckPassword(password) {
+		return unauthorizedError("incorrect_password", "current password is incorrect")
+	}
+	if u.TOTPSecret == "" {
+		return conflictError("mfa_not_enabled", "two-factor authentication is not enabled")
+	}
+	if u.MFAEnabled() {
+		var ok bool
+		switch {
+		case code != "":
+			ok, err = s.checkTOTP(u, code)
+		case recoveryCode != "":
+			ok, err = s.users.UseRecoveryCode(u.ID, utils.HashRecoveryCode(recoveryCode))
+		default:
+			return validationError("mfa_code_required", "code or recovery_code is required")
+		}
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return validationError("invalid_mfa_code", "verification code is incorrect")
+		}
+	}
+	return s.users.DisableTOTP(u.ID)
+}
+
+// RegenerateRecoveryCodes 用当前的验证码换一组新的恢复码，旧的恢复码全部失效
+func (s *AuthService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+
+	u, err := s.users.FindByID(userID)
+	if err != nil {
+		return nil, userLookupError(err)
+	}
+	if !u.MFAEnabled() {
+		return nil, conflictError("mfa_not_enabled", "two-factor authentication is not enabled")
+	}
+	ok, err := s.checkTOTP(u, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, validationError("invalid_mfa_code", "verification code is incorrect")
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.ReplaceRecoveryCodes(u.ID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyMFA 登录的第二步：用 Login 返回的 MFAToken 和验证码（或恢复码）换取令牌对。
+// 验证码错误与密码错误一样计入失败次数，MFAToken 在验证通过后才失效
+func (s *AuthService) VerifyMFA(mfaToken, code, recoveryCode, ip string) (*model.User, *TokenPair, error) {
+
+	if locked, until := s.limiter.Locked(ip); locked {
+		return nil, nil, unauthorizedError("account_locked", "too many failed login attempts, try again after "+until.Format(time.RFC3339))
+	}
+
+	claims, err := utils.ParseActionToken(mfaToken, model.TokenPurposeMFAPending)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, nil, validationError("token_expired", "mfa token has expired, log in again")
+		}
+		return nil, nil, validationError("invalid_token", "mfa token is invalid")
+	}
+	u, err := s.users.FindByID(claims.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil, validationError("invalid_token", "mfa token is invalid")
+		}
+		return nil, nil, err
+	}
+	// 令牌签发后关闭了两步验证或修改了密码，需要重新登录
+	if !u.MFAEnabled() || (u.PasswordChangedAt != nil && claims.IssuedAt != nil &&
+		claims.IssuedAt.Time.Before(u.PasswordChangedAt.Truncate(time.Second))) {
+		return nil, nil, validationError("invalid_token", "mfa token is invalid")
+	}
+	if u.IsDisabled() {
+		return nil, nil, accountDisabledError()
+	}
+	if u.IsLocked(time.Now()) {
+		return nil, nil, accountLockedError(u)
+	}
+	// 先确认令牌没有用过，避免恢复码被已失效的令牌消耗掉；并发使用时由下面的 UseActionToken 兜底
+	usable, err := s.tokens.ActionTokenUsable(claims.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !usable {
+		return nil, nil, validationError("token_used", "mfa token has already been used")
+	}
+
+	var ok bool
+	switch {
+	case code != "":
+		ok, err = s.checkTOTP(u, code)
+	case recoveryCode != "":
+		ok, err = s.users.UseRecoveryCode(u.ID, utils.HashRecoveryCode(recoveryCode))
+	default:
+		return nil, nil, validationError("mfa_code_required", "code or recovery_code is required")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		if err := s.recordLoginFailure(u, ip); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, unauthorizedError("invalid_mfa_code", "verification code is incorrect")
+	}
+
+	used, err := s.tokens.UseActionToken(claims.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !used {
+		return nil, nil, validationError("token_used", "mfa token has already been used")
+	}
+
+	s.limiter.Reset(ip)
+	if u.ClearLoginFailures() {
+		if err := s.users.UpdateLoginState(u); err != nil {
+			logrus.Error(err)
+		}
+	}
+	pair, err := s.issueTokens(u, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return u, pair, nil
+}
+
+// checkTOTP 校验验证码并记录所在的时间步，已经使用过的验证码视为错误
+func (s *AuthService) checkTOTP(u *model.User, code string) (bool, error) {
+
+	step, ok := utils.MatchTOTP(u.TOTPSecret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return s.users.UseTOTPStep(u.ID, step)
+}
+
+// newRecoveryCodes 生成恢复码及其哈希
+func newRecoveryCodes() ([]string, []string, error) {
+
+	codes, err := utils.GenerateRecoveryCodes(model.RecoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = utils.HashRecoveryCode(c)
+	}
+	return codes, hashes, nil
+}
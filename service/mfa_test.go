@@ -0,0 +1,81 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jheader/golang_blog/mail"
+	"github.com/jheader/golang_blog/repository"
+	"github.com/pquerna/otp/totp"
+)
+
+func TestDisableTOTPRequiresSecondFactor(t *testing.T) {
+
+	repos := repository.NewMemoryRepositories()
+	auth := NewAuthService(repos.Users, repos.Tokens, &mail.LogMailer{})
+	alice := createUser(t, repos, "alice")
+
+	enrollment, err := auth.EnrollTOTP(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	code, err := totp.GenerateCode(enrollment.Secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovery, err := auth.ConfirmTOTP(alice.ID, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertAppError(t, auth.DisableTOTP(alice.ID, "wrong-password", "", recovery[0]), "incorrect_password")
+	assertAppError(t, auth.DisableTOTP(alice.ID, "secret123", "", ""), "mfa_code_required")
+	assertAppError(t, auth.DisableTOTP(alice.ID, "secret123", "000000", ""), "invalid_mfa_code")
+	// 确认时用过的验证码不能再用
+	assertAppError(t, auth.DisableTOTP(alice.ID, "secret123", code, ""), "invalid_mfa_code")
+	assertAppError(t, auth.DisableTOTP(alice.ID, "secret123", "", "aaaaa-bbbbb"), "invalid_mfa_code")
+
+	if err := auth.DisableTOTP(alice.ID, "secret123", "", recovery[0]); err != nil {
+		t.Fatalf("disable with recovery code: %v", err)
+	}
+	u, err := repos.Users.FindByID(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.MFAEnabled() || u.TOTPSecret != "" {
+		t.Fatal("two-factor authentication still enabled")
+	}
+	assertAppError(t, auth.DisableTOTP(alice.ID, "secret123", "", recovery[1]), "mfa_not_enabled")
+}
+
+func TestDisableTOTPWithCurrentCode(t *testing.T) {
+
+	repos := repository.NewMemoryRepositories()
+	auth := NewAuthService(repos.Users, repos.Tokens, &mail.LogMailer{})
+	alice := createUser(t, repos, "alice")
+
+	enrollment, err := auth.EnrollTOTP(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 还没有确认的开启只需要密码就能取消
+	if err := auth.DisableTOTP(alice.ID, "secret123", "", ""); err != nil {
+		t.Fatalf("cancel pending enrollment: %v", err)
+	}
+
+	enrollment, err = auth.EnrollTOTP(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	code, _ := totp.GenerateCode(enrollment.Secret, now)
+	if _, err := auth.ConfirmTOTP(alice.ID, code); err != nil {
+		t.Fatal(err)
+	}
+	// 下一个时间步的验证码在允许的时钟误差内
+	next, _ := totp.GenerateCode(enrollment.Secret, now.Add(30*time.Second))
+	if err := auth.DisableTOTP(alice.ID, "secret123", next, ""); err != nil {
+		t.Fatalf("disable with current code: %v", err)
+	}
+}
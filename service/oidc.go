@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/repository"
+	"github.com/jheader/golang_blog/utils"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// oidcHTTPTimeout 请求身份提供方（发现文档、JWKS、令牌接口）的超时时间
+const oidcHTTPTimeout = 10 * time.Second
+
+// OIDCService 使用外部身份提供方登录（授权码模式 + PKCE），登录成功后签发与密码登录相同的令牌
+type OIDCService struct {
+	auth    *AuthService
+	users   repository.UserRepository
+	configs map[string]utils.OIDCProviderConfig
+	client  *http.Client
+
+	mu        sync.Mutex
+	providers map[string]*oidcProvider
+}
+
+// oidcProvider 发现文档加载成功后的提供方，首次使用时加载，失败时下次重试
+type oidcProvider struct {
+	oauth2   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// oidcClaims ID Token 中用到的字段
+type oidcClaims struct {
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	Name              string `json:"name"`
+}
+
+func NewOIDCService(auth *AuthService, users repository.UserRepository, configs []utils.OIDCProviderConfig) *OIDCService {
+
+	s := &OIDCService{
+		auth:      auth,
+		users:     users,
+		configs:   make(map[string]utils.OIDCProviderConfig, len(configs)),
+		client:    &http.Client{Timeout: oidcHTTPTimeout},
+		providers: make(map[string]*oidcProvider),
+	}
+	for _, c := range configs {
+		s.configs[c.Name] = c
+	}
+	return s
+}
+
+// Providers 已配置的身份提供方名称
+func (s *OIDCService) Providers() []string {
+
+	names := make([]string, 0, len(s.configs))
+	for name := range s.configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AuthCodeURL 发起登录：返回跳转到身份提供方的地址，以及需要保存在 Cookie 中的 state
+func (s *OIDCService) AuthCodeURL(name string) (string, string, error) {
+
+	p, err := s.provider(name)
+	if err != nil {
+		return "", "", err
+	}
+	cookie, state, err := utils.GenerateOIDCState(name)
+	if err != nil {
+		return "", "", err
+	}
+	url := p.oauth2.AuthCodeURL(state.State, oidc.Nonce(state.Nonce), oauth2.S256ChallengeOption(state.Verifier))
+	return url, cookie, nil
+}
+
+// Callback 处理身份提供方的回调：校验 state，用授权码和 PKCE verifier 换取 ID Token 并校验 nonce，
+// 找到或创建关联的用户后签发令牌。开启了两步验证的用户与密码登录一样只返回 MFAToken
+func (s *OIDCService) Callback(ctx context.Context, name, code, state, cookie, ip string) (*model.User, *TokenPair, error) {
+
+	p, err := s.provider(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	st, err := utils.ParseOIDCState(cookie, name, state)
+	if err != nil {
+		return nil, nil, validationError("invalid_oidc_state", "login state is missing or expired, start the login again")
+	}
+
+	ctx = oidc.ClientContext(ctx, s.client)
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(st.Verifier))
+	if err != nil {
+		logrus.WithField("provider", name).Warn("oidc code exchange failed: ", err)
+		return nil, nil, unauthorizedError("oidc_exchange_failed", "authorization code is invalid or expired")
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, nil, unauthorizedError("invalid_id_token", "identity provider did not return an id_token")
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		logrus.WithField("provider", name).Warn("oidc id_token verification failed: ", err)
+		return nil, nil, unauthorizedError("invalid_id_token", "id_token is invalid")
+	}
+	if idToken.Nonce != st.Nonce {
+		return nil, nil, unauthorizedError("invalid_id_token", "id_token nonce does not match")
+	}
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, nil, unauthorizedError("invalid_id_token", "id_token claims are invalid")
+	}
+
+	u, err := s.findOrProvision(name, idToken.Subject, claims)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.IsDisabled() {
+		return nil, nil, accountDisabledError()
+	}
+	if u.MustResetPassword {
+		return nil, nil, forbiddenError("password_reset_required", "password must be reset before logging in")
+	}
+	if u.MFAEnabled() {
+		mfaToken, err := s.auth.issueActionToken(u, model.TokenPurposeMFAPending, utils.MFAPendingTTL())
+		if err != nil {
+			return nil, nil, err
+		}
+		return u, &TokenPair{MFAToken: mfaToken}, nil
+	}
+
+	pair, err := s.auth.issueTokens(u, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	logrus.WithFields(logrus.Fields{"provider": name, "user_id": u.ID, "ip": ip}).Info("oidc login")
+	return u, pair, nil
+}
+
+// findOrProvision 按 (provider, subject) 查找已关联的用户；首次登录时，
+// 双方都已验证过的同一邮箱关联到已有用户，否则自动创建新用户
+func (s *OIDCService) findOrProvision(name, subject string, claims oidcClaims) (*model.User, error) {
+
+	u, err := s.users.FindByIdentity(name, subject)
+	if err == nil {
+		if err := s.users.TouchIdentity(name, subject, claims.Email); err != nil {
+			logrus.Error(err)
+		}
+		return u, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	if claims.Email == "" {
+		return nil, validationError("oidc_email_required", "identity provider did not return an email, request the email scope")
+	}
+	now := time.Now()
+	identity := &model.UserIdentity{Provider: name, Subject: subject, Email: claims.Email, LastLoginAt: &now}
+
+	existing, err := s.users.FindByEmail(claims.Email)
+	if err == nil {
+		// 只有提供方和本站都验证过邮箱才自动关联，否则任何人都能用未验证的邮箱接管同名账号
+		if !claims.EmailVerified || !existing.IsEmailVerified() {
+			return nil, conflictError("email_taken", "an account with this email already exists, "+
+				"verify the email of that account and the identity provider to sign in with "+name)
+		}
+		identity.UserID = existing.ID
+		if err := s.users.LinkIdentity(identity); err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+	return s.provision(identity, claims)
+}
+
+// oidcUsernameMaxAttempts 用户名冲突时追加随机后缀重试的次数
+const oidcUsernameMaxAttempts = 5
+
+// oidcUsernameInvalid 用户名只能包含字母、数字和下划线（与注册时的 username 校验规则一致）
+var oidcUsernameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// provision 首次登录时创建用户。用户名取自 preferred_username 或邮箱前缀，已被占用时追加随机数字；
+// 密码为随机值，需要密码登录时可以通过忘记密码设置
+func (s *OIDCService) provision(identity *model.UserIdentity, claims oidcClaims) (*model.User, error) {
+
+	password, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	base := oidcUsername(claims)
+	for attempt := 0; attempt < oidcUsernameMaxAttempts; attempt++ {
+		username := base
+		if attempt > 0 {
+			username = fmt.Sprintf("%s_%04d", truncateRunes(base, 15), rand.IntN(10000))
+		}
+		if _, err := s.users.FindByUsername(username); err == nil {
+			continue
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return nil, err
+		}
+
+		u := &model.User{
+			Username:    username,
+			Email:       claims.Email,
+			Password:    password,
+			Role:        model.RoleUser,
+			DisplayName: truncateRunes(claims.Name, 50),
+		}
+		if claims.EmailVerified {
+			now := time.Now()
+			u.EmailVerifiedAt = &now
+		}
+		// 并发的首次登录由唯一索引兜底，重试时会查到已创建的用户或换一个用户名
+		err := s.users.CreateWithIdentity(u, identity)
+		if err == nil {
+			return u, nil
+		}
+		if !errors.Is(err, repository.ErrDuplicate) {
+			return nil, err
+		}
+		if u, err := s.users.FindByIdentity(identity.Provider, identity.Subject); err == nil {
+			return u, nil
+		}
+	}
+	return nil, conflictError("user_exists", "could not allocate a username, try again")
+}
+
+// oidcUsername 由 preferred_username 生成合法的用户名，不合法时依次改用邮箱 @ 之前的部分和 "user"
+func oidcUsername(claims oidcClaims) string {
+
+	local, _, _ := strings.Cut(claims.Email, "@")
+	for _, candidate := range []string{claims.PreferredUsername, local} {
+		name := strings.Trim(oidcUsernameInvalid.ReplaceAllString(candidate, "_"), "_")
+		if len(name) >= 3 {
+			return truncateRunes(name, 20)
+		}
+	}
+	return "user"
+}
+
+// truncateRunes 按字符截断
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// provider 返回已加载发现文档的提供方，未配置时返回 oidc_provider_not_found
+func (s *OIDCService) provider(name string) (*oidcProvider, error) {
+
+	cfg, ok := s.configs[name]
+	if !ok {
+		return nil, notFoundError("oidc_provider_not_found", "identity provider "+name+" is not configured")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.providers[name]; ok {
+		return p, nil
+	}
+
+	ctx, cancel := context.WithTimeout(oidc.ClientContext(context.Background(), s.client), oidcHTTPTimeout)
+	defer cancel()
+	discovered, err := oidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("加载身份提供方 %s 的发现文档失败：%w", name, err)
+	}
+	p := &oidcProvider{
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     discovered.Endpoint(),
+			Scopes:       cfg.Scopes,
+		},
+		verifier: discovered.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+	}
+	s.providers[name] = p
+	return p, nil
+}
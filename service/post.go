@@ -0,0 +1,478 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jheader/golang_blog/dto"
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/repository"
+	"github.com/jheader/golang_blog/search"
+	"github.com/jheader/golang_blog/utils"
+)
+
+type PostService struct {
+	posts      repository.PostRepository
+	tags       repository.TagRepository
+	categories repository.CategoryRepository
+	searcher   search.Searcher
+}
+
+func NewPostService(posts repository.PostRepository, tags repository.TagRepository,
+	categories repository.CategoryRepository, searcher search.Searcher) *PostService {
+	return &PostService{posts: posts, tags: tags, categories: categories, searcher: searcher}
+}
+
+type PostInput struct {
+	Title   string
+	Content string
+	// Status 为空时直接发布；Status 为 scheduled 时 PublishAt 为计划发布时间
+	Status    string
+	PublishAt *time.Time
+	// Tags 标签名称，不存在的标签会自动创建
+	Tags       []string
+	CategoryID *uint
+}
+
+// 每篇文章最多的标签数量，以及单个标签名称的最大长度
+const (
+	maxPostTags   = 10
+	maxTagNameLen = 30
+)
+
+// resolveTags 规范化标签名称并去重，然后查找或创建对应的标签
+func (s *PostService) resolveTags(names []string) ([]model.Tag, error) {
+
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		name = model.NormalizeTagName(name)
+		if name == "" || slices.Contains(normalized, name) {
+			continue
+		}
+		if utf8.RuneCountInString(name) > maxTagNameLen {
+			return nil, validationError("tag_too_long", fmt.Sprintf("标签 %q 超过 %d 个字符", name, maxTagNameLen))
+		}
+		normalized = append(normalized, name)
+	}
+	if len(normalized) > maxPostTags {
+		return nil, validationError("too_many_tags", fmt.Sprintf("每篇文章最多 %d 个标签", maxPostTags))
+	}
+	return s.tags.FindOrCreate(normalized)
+}
+
+// resolveCategory 检查分类是否存在，0 表示不设置分类
+func (s *PostService) resolveCategory(id *uint) (*uint, error) {
+
+	if id == nil || *id == 0 {
+		return nil, nil
+	}
+	if _, err := s.categories.FindByID(*id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, validationError("invalid_category", fmt.Sprintf("分类 %d 不存在", *id))
+		}
+		return nil, err
+	}
+	return id, nil
+}
+
+func (in PostInput) validate() error {
+	if strings.TrimSpace(in.Title) == "" {
+		return validationError("title_required", "文章标题不能为空")
+	}
+	if strings.TrimSpace(in.Content) == "" {
+		return validationError("content_required", "文章内容不能为空")
+	}
+	return nil
+}
+
+// resolveStatus 校验文章状态并补全发布时间：未指定状态时直接发布，
+// 定时发布必须指定未来的 publish_at，直接发布的 publish_at 为当前时间，草稿没有发布时间
+func resolveStatus(status string, publishAt *time.Time, now time.Time) (string, *time.Time, error) {
+
+	if status == "" {
+		status = model.PostStatusPublished
+	}
+	if !model.ValidPostStatus(status) {
+		return "", nil, validationError("invalid_status", "文章状态只能是 draft、scheduled、published 或 archived")
+	}
+	switch status {
+	case model.PostStatusScheduled:
+		if publishAt == nil {
+			return "", nil, validationError("publish_at_required", "定时发布需要指定 publish_at")
+		}
+		if !publishAt.After(now) {
+			return "", nil, validationError("publish_at_in_past", "定时发布时间必须晚于当前时间")
+		}
+	case model.PostStatusPublished:
+		if publishAt == nil {
+			publishAt = &now
+		} else if publishAt.After(now) {
+			return "", nil, validationError("publish_at_in_future", "发布时间晚于当前时间，请使用 scheduled 状态")
+		}
+	case model.PostStatusDraft:
+		publishAt = nil
+	}
+	return status, publishAt, nil
+}
+
+// Get 查询文章详情，未发布的文章只有作者本人可见，viewerID 为0表示匿名访问
+func (s *PostService) Get(viewerID, id uint) (*model.Post, error) {
+
+	post, err := s.posts.FindDetail(id)
+	if err != nil {
+		return nil, postLookupError(err)
+	}
+	if !visibleTo(post, viewerID) {
+		return nil, postLookupError(repository.ErrNotFound)
+	}
+	return post, nil
+}
+
+// GetBySlug 按 slug 查询文章详情。slug 是文章用过的旧 slug 时 moved 为 true，
+// 调用方应跳转到 post.Slug
+func (s *PostService) GetBySlug(viewerID uint, slug string) (post *model.Post, moved bool, err error) {
+
+	post, err = s.posts.FindBySlug(slug)
+	if errors.Is(err, repository.ErrNotFound) {
+		old, oldErr := s.posts.FindOldSlug(slug)
+		if oldErr != nil {
+			return nil, false, postLookupError(oldErr)
+		}
+		post, err = s.posts.FindDetail(old.PostID)
+		moved = true
+	}
+	if err != nil {
+		return nil, false, postLookupError(err)
+	}
+	if !visibleTo(post, viewerID) {
+		return nil, false, postLookupError(repository.ErrNotFound)
+	}
+	return post, moved, nil
+}
+
+// visibleTo 未发布的文章只有作者本人可见
+func visibleTo(post *model.Post, viewerID uint) bool {
+	return post.IsPublished() || (viewerID != 0 && post.UserID == viewerID)
+}
+
+// PostListSpec 文章列表允许的排序、过滤和返回字段
+var PostListSpec = utils.ListSpec{
+	Sorts: map[string]string{
+		"id":         "id",
+		"title":      "title",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+		"publish_at": "publish_at",
+	},
+	DefaultSort: "-created_at",
+	Filters: map[string]utils.FilterSpec{
+		"author_id":      {Column: "user_id", Op: "=", Type: utils.FilterUint},
+		"created_after":  {Column: "created_at", Op: ">=", Type: utils.FilterTime},
+		"created_before": {Column: "created_at", Op: "<", Type: utils.FilterTime},
+	},
+	// 对应 dto.PostSummary 的 JSON 字段
+	Fields: []string{"id", "title", "slug", "excerpt", "status", "publish_at", "author", "category",
+		"tags", "comment_count", "created_at", "updated_at"},
+}
+
+// List 公开的文章列表，只包含已发布的文章，q 由 utils.ParseListQuery 按 PostListSpec 解析
+func (s *PostService) List(q utils.ListQuery, page, size int) ([]dto.PostSummary, int64, error) {
+
+	posts, total, err := s.posts.List(repository.PostFilter{Query: q}, page, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	summaries, err := postSummaries(s.posts, posts)
+	return summaries, total, err
+}
+
+// ListByCursor 游标分页的公开文章列表，返回值交给 utils.NewCursorResponse 构建响应
+func (s *PostService) ListByCursor(q utils.ListQuery, cq utils.CursorQuery) ([]dto.PostSummary, error) {
+
+	posts, err := s.posts.ListByCursor(repository.PostFilter{Query: q}, cq)
+	if err != nil {
+		return nil, err
+	}
+	return postSummaries(s.posts, posts)
+}
+
+// postSummaries 把文章转换成列表项，整页文章的评论数只用一条聚合查询统计
+func postSummaries(repo repository.PostRepository, posts []model.Post) ([]dto.PostSummary, error) {
+
+	ids := make([]uint, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+	counts, err := repo.CountComments(ids)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]dto.PostSummary, 0, len(posts))
+	for _, p := range posts {
+		summaries = append(summaries, dto.NewPostSummary(p, counts[p.ID]))
+	}
+	return summaries, nil
+}
+
+// ListMine 列出当前用户指定状态的文章，默认为草稿和定时发布的文章
+func (s *PostService) ListMine(userID uint, statuses []string, page, size int) ([]dto.PostSummary, int64, error) {
+
+	if len(statuses) == 0 {
+		statuses = []string{model.PostStatusDraft, model.PostStatusScheduled}
+	}
+	for _, status := range statuses {
+		if !model.ValidPostStatus(status) {
+			return nil, 0, validationError("invalid_status", "文章状态只能是 draft、scheduled、published 或 archived")
+		}
+	}
+	posts, total, err := s.posts.ListByAuthor(userID, statuses, page, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	summaries, err := postSummaries(s.posts, posts)
+	return summaries, total, err
+}
+
+func (s *PostService) Create(userID uint, in PostInput) (*model.Post, error) {
+
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	status, publishAt, err := resolveStatus(in.Status, in.PublishAt, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	categoryID, err := s.resolveCategory(in.CategoryID)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.resolveTags(in.Tags)
+	if err != nil {
+		return nil, err
+	}
+	post := model.Post{
+		Title:      in.Title,
+		Content:    in.Content,
+		UserID:     userID,
+		Status:     status,
+		PublishAt:  publishAt,
+		CategoryID: categoryID,
+		Tags:       tags,
+	}
+	if err := s.posts.Create(&post); err != nil {
+		return nil, err
+	}
+	// 重新查询以加载作者信息
+	return s.reloadAndIndex(post.ID)
+}
+
+// reloadAndIndex 重新查询文章（加载作者、标签和评论）并更新搜索索引
+func (s *PostService) reloadAndIndex(postID uint) (*model.Post, error) {
+
+	post, err := s.posts.FindDetail(postID)
+	if err != nil {
+		return nil, err
+	}
+	syncIndex(s.searcher.IndexPost(post), search.TypePost, postID)
+	return post, nil
+}
+
+// PostPatch 更新文章，nil 字段保持原值（PATCH），PUT 时标题和内容都会设置。
+// Tags 为空切片时清空标签，CategoryID 为0时取消分类
+type PostPatch struct {
+	Title      *string
+	Content    *string
+	Status     *string
+	PublishAt  *time.Time
+	Tags       []string
+	CategoryID *uint
+}
+
+// AnyVersion 对应 If-Match: *，跳过版本检查
+const AnyVersion = 0
+
+// Update 更新文章，expectedVersion 必须与当前版本一致（来自 If-Match），
+// 否则说明文章已被他人修改，返回 ErrPreconditionFailed
+func (s *PostService) Update(actor Actor, postID, expectedVersion uint, patch PostPatch) (*model.Post, error) {
+
+	post, err := s.ownedPost(actor, postID, model.PermPostsUpdateAny)
+	if err != nil {
+		return nil, err
+	}
+	if expectedVersion != AnyVersion && post.Version != expectedVersion {
+		return nil, versionMismatchError(post)
+	}
+	if patch.Title != nil {
+		post.Title = *patch.Title
+	}
+	if patch.Content != nil {
+		post.Content = *patch.Content
+	}
+	if patch.Status != nil || patch.PublishAt != nil {
+		status, publishAt := post.Status, post.PublishAt
+		if patch.Status != nil {
+			status = *patch.Status
+		}
+		if patch.PublishAt != nil {
+			publishAt = patch.PublishAt
+		}
+		if post.Status, post.PublishAt, err = resolveStatus(status, publishAt, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	if err := (PostInput{Title: post.Title, Content: post.Content}).validate(); err != nil {
+		return nil, err
+	}
+	if patch.CategoryID != nil {
+		if post.CategoryID, err = s.resolveCategory(patch.CategoryID); err != nil {
+			return nil, err
+		}
+	}
+	// Tags 为 nil 时仓储不会修改标签
+	post.Tags = nil
+	if patch.Tags != nil {
+		if post.Tags, err = s.resolveTags(patch.Tags); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.posts.Update(post, post.Version, actor.UserID); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return nil, versionMismatchError(post)
+		}
+		return nil, postLookupError(err)
+	}
+	return s.reloadAndIndex(postID)
+}
+
+// ListRevisions 列出文章的历史版本，仅作者可见
+func (s *PostService) ListRevisions(actor Actor, postID uint, page, size int) ([]dto.Revision, int64, error) {
+
+	if _, err := s.ownedPost(actor, postID, model.PermPostsUpdateAny); err != nil {
+		return nil, 0, err
+	}
+	revisions, total, err := s.posts.ListRevisions(postID, page, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	return dto.NewRevisions(revisions), total, nil
+}
+
+func (s *PostService) GetRevision(actor Actor, postID, revision uint) (*dto.Revision, error) {
+
+	if _, err := s.ownedPost(actor, postID, model.PermPostsUpdateAny); err != nil {
+		return nil, err
+	}
+	rev, err := s.findRevision(postID, revision)
+	if err != nil {
+		return nil, err
+	}
+	out := dto.NewRevision(*rev)
+	return &out, nil
+}
+
+// RevisionDiff 两个历史版本之间的差异，Diff 为内容的 unified diff
+type RevisionDiff struct {
+	PostID    uint   `json:"post_id"`
+	From      uint   `json:"from"`
+	To        uint   `json:"to"`
+	FromTitle string `json:"from_title"`
+	ToTitle   string `json:"to_title"`
+	Diff      string `json:"diff"`
+}
+
+func (s *PostService) DiffRevisions(actor Actor, postID, from, to uint) (*RevisionDiff, error) {
+
+	if _, err := s.ownedPost(actor, postID, model.PermPostsUpdateAny); err != nil {
+		return nil, err
+	}
+	fromRev, err := s.findRevision(postID, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, err := s.findRevision(postID, to)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := utils.UnifiedDiff(fmt.Sprintf("revision %d", from), fmt.Sprintf("revision %d", to),
+		fromRev.Content, toRev.Content)
+	if errors.Is(err, utils.ErrDiffTooLarge) {
+		return nil, unprocessableError("diff_too_large", "两个版本的差异太大，无法生成 diff")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &RevisionDiff{
+		PostID:    postID,
+		From:      from,
+		To:        to,
+		FromTitle: fromRev.Title,
+		ToTitle:   toRev.Title,
+		Diff:      diff,
+	}, nil
+}
+
+// RestoreRevision 用旧版本的标题和内容更新文章，生成一个新的版本（与当前内容相同时不产生新的历史版本），历史记录保持不变
+func (s *PostService) RestoreRevision(actor Actor, postID, revision uint) (*model.Post, error) {
+
+	if _, err := s.ownedPost(actor, postID, model.PermPostsUpdateAny); err != nil {
+		return nil, err
+	}
+	rev, err := s.findRevision(postID, revision)
+	if err != nil {
+		return nil, err
+	}
+	return s.Update(actor, postID, AnyVersion, PostPatch{Title: &rev.Title, Content: &rev.Content})
+}
+
+func (s *PostService) findRevision(postID, revision uint) (*model.PostRevision, error) {
+
+	rev, err := s.posts.FindRevision(postID, revision)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, notFoundError("revision_not_found", fmt.Sprintf("文章版本 %d 不存在", revision))
+		}
+		return nil, err
+	}
+	return rev, nil
+}
+
+func versionMismatchError(p *model.Post) error {
+	return preconditionFailedError("version_mismatch",
+		fmt.Sprintf("文章已被修改（当前版本 %d），请刷新后重试", p.Version))
+}
+
+func (s *PostService) Delete(actor Actor, postID uint) error {
+
+	if _, err := s.ownedPost(actor, postID, model.PermPostsDeleteAny); err != nil {
+		return err
+	}
+	if err := s.posts.Delete(postID); err != nil {
+		return postLookupError(err)
+	}
+	syncIndex(s.searcher.DeletePost(postID), search.TypePost, postID)
+	return nil
+}
+
+// ownedPost 查询文章并检查是否属于当前用户，拥有 anyPermission 权限（如 posts:delete:any）时可以操作任何人的文章
+func (s *PostService) ownedPost(actor Actor, postID uint, anyPermission string) (*model.Post, error) {
+
+	post, err := s.posts.FindByID(postID)
+	if err != nil {
+		return nil, postLookupError(err)
+	}
+	if post.UserID != actor.UserID && !actor.Can(anyPermission) {
+		return nil, forbiddenError("post_not_owned", "不能修改用户"+post.User.Username+"的文章")
+	}
+	return post, nil
+}
+
+func postLookupError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return notFoundError("post_not_found", "文章不存在或已被删除")
+	}
+	return err
+}
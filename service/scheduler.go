@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/jheader/golang_blog/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// PublishScheduler 定时把到期的定时文章改为已发布。
+// 每次只执行一条带条件的 UPDATE，多个副本同时运行时同一篇文章也只会被发布一次
+type PublishScheduler struct {
+	posts    repository.PostRepository
+	interval time.Duration
+}
+
+func NewPublishScheduler(posts repository.PostRepository, interval time.Duration) *PublishScheduler {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &PublishScheduler{posts: posts, interval: interval}
+}
+
+// Start 在后台 goroutine 中运行调度器，启动时立即检查一次，ctx 取消后退出
+func (s *PublishScheduler) Start(ctx context.Context) {
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			s.RunOnce(time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce 发布 publish_at 不晚于 now 的定时文章，返回发布的数量
+func (s *PublishScheduler) RunOnce(now time.Time) int64 {
+
+	published, err := s.posts.PublishDue(now)
+	if err != nil {
+		logrus.WithError(err).Error("publish scheduled posts failed")
+		return 0
+	}
+	if published > 0 {
+		logrus.WithField("count", published).Info("scheduled posts published")
+	}
+	return published
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/repository"
+	"github.com/jheader/golang_blog/search"
+	"github.com/sirupsen/logrus"
+)
+
+// 搜索词的最大长度（字符数）
+const maxSearchTextLen = 100
+
+type SearchService struct {
+	searcher search.Searcher
+	users    repository.UserRepository
+	tags     repository.TagRepository
+}
+
+func NewSearchService(searcher search.Searcher, users repository.UserRepository, tags repository.TagRepository) *SearchService {
+	return &SearchService{searcher: searcher, users: users, tags: tags}
+}
+
+// SearchInput 搜索条件，Author 为用户名，Tag 为标签名称，为空时不过滤
+type SearchInput struct {
+	Text     string
+	Author   string
+	AuthorID uint
+	Tag      string
+	From     *time.Time
+	To       *time.Time
+	Page     int
+	Size     int
+}
+
+func (s *SearchService) Search(in SearchInput) ([]search.Hit, int64, error) {
+
+	text := strings.TrimSpace(in.Text)
+	if text == "" || len(search.Tokenize(text)) == 0 {
+		return nil, 0, validationError("query_required", "搜索词不能为空")
+	}
+	if utf8.RuneCountInString(text) > maxSearchTextLen {
+		return nil, 0, validationError("query_too_long", "搜索词不能超过100个字符")
+	}
+	if in.From != nil && in.To != nil && in.From.After(*in.To) {
+		return nil, 0, validationError("invalid_date_range", "开始时间不能晚于结束时间")
+	}
+
+	q := search.Query{Text: text, AuthorID: in.AuthorID, From: in.From, To: in.To, Page: in.Page, Size: in.Size}
+	// 用户或标签不存在时结果一定为空
+	if in.Author != "" {
+		user, err := s.users.FindByUsername(in.Author)
+		if errors.Is(err, repository.ErrNotFound) {
+			return []search.Hit{}, 0, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if q.AuthorID != 0 && q.AuthorID != user.ID {
+			return []search.Hit{}, 0, nil
+		}
+		q.AuthorID = user.ID
+	}
+	if in.Tag != "" {
+		tag, err := s.tags.FindByName(model.NormalizeTagName(in.Tag))
+		if errors.Is(err, repository.ErrNotFound) {
+			return []search.Hit{}, 0, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		q.TagID = tag.ID
+	}
+	return s.searcher.Search(q)
+}
+
+// syncIndex 同步搜索索引。索引更新失败不影响本次请求，内存索引会在重启时从数据库重建
+func syncIndex(err error, kind string, id uint) {
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"type": kind, "id": id}).WithError(err).Warn("update search index failed")
+	}
+}
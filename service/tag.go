@@ -0,0 +1,168 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jheader/golang_blog/dto"
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/repository"
+	"github.com/jheader/golang_blog/search"
+)
+
+type TagService struct {
+	tags     repository.TagRepository
+	posts    repository.PostRepository
+	searcher search.Searcher
+}
+
+func NewTagService(tags repository.TagRepository, posts repository.PostRepository, searcher search.Searcher) *TagService {
+	return &TagService{tags: tags, posts: posts, searcher: searcher}
+}
+
+// List 列出标签及已发布文章的数量
+func (s *TagService) List() ([]model.TagStat, error) {
+	return s.tags.ListWithCounts()
+}
+
+// Posts 分页列出带有该标签的已发布文章
+func (s *TagService) Posts(name string, page, size int) ([]dto.PostSummary, int64, error) {
+
+	tag, err := s.findTag(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	posts, total, err := s.posts.List(repository.PostFilter{TagID: tag.ID}, page, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	summaries, err := postSummaries(s.posts, posts)
+	return summaries, total, err
+}
+
+// Rename 修改标签名称，新名称已存在时返回冲突，应改用 Merge
+func (s *TagService) Rename(name, newName string) (*model.Tag, error) {
+
+	tag, err := s.findTag(name)
+	if err != nil {
+		return nil, err
+	}
+	newName, err = validTagName(newName)
+	if err != nil {
+		return nil, err
+	}
+	if newName == tag.Name {
+		return tag, nil
+	}
+	if err := s.tags.Rename(tag.ID, newName); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, conflictError("tag_exists", fmt.Sprintf("标签 %q 已存在，请使用合并", newName))
+		}
+		return nil, err
+	}
+	tag.Name = newName
+	return tag, nil
+}
+
+// Merge 把 sources 标签合并到 into 标签，into 不存在时自动创建
+func (s *TagService) Merge(sources []string, into string) (*model.Tag, error) {
+
+	into, err := validTagName(into)
+	if err != nil {
+		return nil, err
+	}
+	sourceIDs := make([]uint, 0, len(sources))
+	for _, name := range sources {
+		tag, err := s.findTag(name)
+		if err != nil {
+			return nil, err
+		}
+		if tag.Name != into {
+			sourceIDs = append(sourceIDs, tag.ID)
+		}
+	}
+	if len(sourceIDs) == 0 {
+		return nil, validationError("merge_sources_required", "至少需要一个与目标不同的源标签")
+	}
+	targets, err := s.tags.FindOrCreate([]string{into})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tags.Merge(sourceIDs, targets[0].ID); err != nil {
+		return nil, err
+	}
+	syncIndex(s.searcher.MergeTags(sourceIDs, targets[0].ID), "tag", targets[0].ID)
+	return &targets[0], nil
+}
+
+func (s *TagService) findTag(name string) (*model.Tag, error) {
+
+	tag, err := s.tags.FindByName(model.NormalizeTagName(name))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, notFoundError("tag_not_found", fmt.Sprintf("标签 %q 不存在", name))
+		}
+		return nil, err
+	}
+	return tag, nil
+}
+
+func validTagName(name string) (string, error) {
+
+	name = model.NormalizeTagName(name)
+	if name == "" {
+		return "", validationError("tag_name_required", "标签名称不能为空")
+	}
+	if utf8.RuneCountInString(name) > maxTagNameLen {
+		return "", validationError("tag_too_long", fmt.Sprintf("标签 %q 超过 %d 个字符", name, maxTagNameLen))
+	}
+	return name, nil
+}
+
+type CategoryService struct {
+	categories repository.CategoryRepository
+	posts      repository.PostRepository
+}
+
+func NewCategoryService(categories repository.CategoryRepository, posts repository.PostRepository) *CategoryService {
+	return &CategoryService{categories: categories, posts: posts}
+}
+
+func (s *CategoryService) List() ([]model.Category, error) {
+	return s.categories.List()
+}
+
+func (s *CategoryService) Create(name, description string) (*model.Category, error) {
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, validationError("category_name_required", "分类名称不能为空")
+	}
+	category := model.Category{Name: name, Description: strings.TrimSpace(description)}
+	if err := s.categories.Create(&category); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, conflictError("category_exists", fmt.Sprintf("分类 %q 已存在", name))
+		}
+		return nil, err
+	}
+	return &category, nil
+}
+
+// Posts 分页列出该分类下已发布的文章
+func (s *CategoryService) Posts(id uint, page, size int) ([]dto.PostSummary, int64, error) {
+
+	if _, err := s.categories.FindByID(id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, 0, notFoundError("category_not_found", fmt.Sprintf("分类 %d 不存在", id))
+		}
+		return nil, 0, err
+	}
+	posts, total, err := s.posts.List(repository.PostFilter{CategoryID: id}, page, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	summaries, err := postSummaries(s.posts, posts)
+	return summaries, total, err
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/repository"
+	"github.com/jheader/golang_blog/search"
+	"github.com/jheader/golang_blog/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// 删除用户时对其文章和评论的处理方式
+const (
+	// DeleteModeAnonymize 保留内容，作者改为占位用户
+	DeleteModeAnonymize = "anonymize"
+	// DeleteModeCascade 删除该用户的文章和评论
+	DeleteModeCascade = "cascade"
+)
+
+type UserService struct {
+	users    repository.UserRepository
+	tokens   repository.TokenRepository
+	posts    repository.PostRepository
+	comments repository.CommentRepository
+	searcher search.Searcher
+}
+
+func NewUserService(users repository.UserRepository, tokens repository.TokenRepository,
+	posts repository.PostRepository, comments repository.CommentRepository, searcher search.Searcher) *UserService {
+	return &UserService{users: users, tokens: tokens, posts: posts, comments: comments, searcher: searcher}
+}
+
+// ProfileUpdate 用户可以自己修改的资料，nil 表示不修改
+type ProfileUpdate struct {
+	DisplayName *string
+	Bio         *string
+	AvatarURL   *string
+	Email       *string
+}
+
+// Profile 当前用户自己的资料
+func (s *UserService) Profile(userID uint) (*model.User, error) {
+	return s.findUser(userID)
+}
+
+// PublicProfile 按用户名查询公开资料，删除用户时创建的占位用户不可查询
+func (s *UserService) PublicProfile(username string) (*model.User, error) {
+
+	if username == model.GhostUsername {
+		return nil, notFoundError("user_not_found", "用户不存在")
+	}
+	u, err := s.users.FindByUsername(username)
+	if err != nil {
+		return nil, userLookupError(err)
+	}
+	return u, nil
+}
+
+// UpdateProfile 修改当前用户的资料，头像传空字符串表示删除。修改邮箱后邮箱变为未验证，
+// 新邮箱已被其他用户使用时返回 email_taken
+func (s *UserService) UpdateProfile(userID uint, update ProfileUpdate) (*model.User, error) {
+
+	u, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if update.DisplayName != nil {
+		u.DisplayName = strings.TrimSpace(*update.DisplayName)
+	}
+	if update.Bio != nil {
+		u.Bio = strings.TrimSpace(*update.Bio)
+	}
+	if update.AvatarURL != nil {
+		avatar := strings.TrimSpace(*update.AvatarURL)
+		if avatar != "" && !validAvatarURL(avatar) {
+			return nil, validationError("invalid_avatar_url", "头像地址必须是 http 或 https 链接")
+		}
+		u.AvatarURL = avatar
+	}
+	if update.Email != nil {
+		email := strings.TrimSpace(*update.Email)
+		if utils.CanonicalIdentity(email) != utils.CanonicalIdentity(u.Email) {
+			// 新邮箱需要重新验证，只修改大小写不算更换邮箱
+			u.EmailVerifiedAt = nil
+		}
+		u.Email = email
+		u.Canonicalize()
+	}
+
+	if err := s.users.UpdateProfile(u); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, conflictError("email_taken", "Email already exists")
+		}
+		return nil, userLookupError(err)
+	}
+	return s.findUser(userID)
+}
+
+// validAvatarURL 只允许 http(s) 的绝对地址，避免 javascript: 之类的链接被渲染到页面
+func validAvatarURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// List 分页列出用户，q 按用户名或邮箱模糊匹配
+func (s *UserService) List(q string, page, size int) ([]model.User, int64, error) {
+	return s.users.List(strings.TrimSpace(q), page, size)
+}
+
+// Disable 停用账号并吊销所有刷新令牌。已签发的访问令牌由 AuthMiddleware 拒绝
+func (s *UserService) Disable(actor Actor, userID uint) (*model.User, error) {
+
+	if userID == actor.UserID {
+		return nil, forbiddenError("cannot_disable_self", "不能停用自己的账号")
+	}
+	u, err := s.findManagedUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.IsDisabled() {
+		return u, nil
+	}
+	now := time.Now()
+	if err := s.users.SetDisabled(userID, &now); err != nil {
+		return nil, userLookupError(err)
+	}
+	if err := s.tokens.RevokeUser(userID); err != nil {
+		return nil, err
+	}
+	return s.findUser(userID)
+}
+
+// Enable 重新启用账号，用户需要重新登录
+func (s *UserService) Enable(userID uint) (*model.User, error) {
+
+	if _, err := s.findManagedUser(userID); err != nil {
+		return nil, err
+	}
+	if err := s.users.SetDisabled(userID, nil); err != nil {
+		return nil, userLookupError(err)
+	}
+	return s.findUser(userID)
+}
+
+// ForcePasswordReset 要求用户重置密码：吊销所有刷新令牌，重置之前不能登录
+func (s *UserService) ForcePasswordReset(userID uint) (*model.User, error) {
+
+	if _, err := s.findManagedUser(userID); err != nil {
+		return nil, err
+	}
+	if err := s.users.SetMustResetPassword(userID, true); err != nil {
+		return nil, userLookupError(err)
+	}
+	if err := s.tokens.RevokeUser(userID); err != nil {
+		return nil, err
+	}
+	return s.findUser(userID)
+}
+
+// Delete 彻底删除用户，mode 决定文章和评论的处理方式，见 repository.UserRepository.Delete。
+// 不能删除自己
+func (s *UserService) Delete(actor Actor, userID uint, mode string) error {
+
+	if mode != DeleteModeAnonymize && mode != DeleteModeCascade {
+		return validationError("invalid_delete_mode", "mode 只能是 anonymize 或 cascade")
+	}
+	if userID == actor.UserID {
+		return forbiddenError("cannot_delete_self", "不能删除自己的账号")
+	}
+	if _, err := s.findManagedUser(userID); err != nil {
+		return err
+	}
+	content, err := s.users.Delete(userID, mode == DeleteModeCascade)
+	if err != nil {
+		return userLookupError(err)
+	}
+
+	if mode == DeleteModeCascade {
+		for _, id := range content.PostIDs {
+			syncIndex(s.searcher.DeletePost(id), search.TypePost, id)
+		}
+		for _, id := range content.CommentIDs {
+			syncIndex(s.searcher.DeleteComment(id), search.TypeComment, id)
+		}
+		return nil
+	}
+	// 作者变成了占位用户，重新索引；已删除的文章和评论不在索引中，跳过
+	for _, id := range content.PostIDs {
+		if post, err := s.posts.FindDetail(id); err == nil {
+			syncIndex(s.searcher.IndexPost(post), search.TypePost, id)
+		}
+	}
+	for _, id := range content.CommentIDs {
+		if comment, err := s.comments.FindByID(id); err == nil {
+			syncIndex(s.searcher.IndexComment(comment), search.TypeComment, id)
+		}
+	}
+	return nil
+}
+
+// SetRole 修改用户的角色和单独授予的权限，需要 users:manage 权限（由路由检查）。
+// 不能修改自己的角色，避免唯一的管理员把自己降级后无人可以管理。
+// 新的权限在用户的下一个请求立即生效
+func (s *UserService) SetRole(actor Actor, userID uint, role string, permissions []string) (*model.User, error) {
+
+	if !model.ValidRole(role) {
+		return nil, validationError("invalid_role", "角色只能是 user、moderator 或 admin")
+	}
+	for _, p := range permissions {
+		if !model.ValidPermission(p) {
+			return nil, validationError("invalid_permission", fmt.Sprintf("未定义的权限 %q", p))
+		}
+	}
+	if userID == actor.UserID {
+		return nil, forbiddenError("cannot_change_own_role", "不能修改自己的角色")
+	}
+	if _, err := s.findManagedUser(userID); err != nil {
+		return nil, err
+	}
+
+	permissions = slices.Compact(slices.Sorted(slices.Values(permissions)))
+	if err := s.users.UpdateRole(userID, role, permissions); err != nil {
+		return nil, userLookupError(err)
+	}
+	return s.findUser(userID)
+}
+
+// BootstrapAdmins 启动时把 names 中的用户设为管理员，用于部署后创建第一个管理员。
+// 之后的角色变更通过管理接口完成，从配置中移除用户名不会撤销管理员角色
+func BootstrapAdmins(users repository.UserRepository, names []string) {
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		u, err := users.FindByUsername(name)
+		if err != nil {
+			logrus.WithField("username", name).Warn("bootstrap admin not found: ", err)
+			continue
+		}
+		if u.Role == model.RoleAdmin {
+			continue
+		}
+		if err := users.UpdateRole(u.ID, model.RoleAdmin, u.Permissions); err != nil {
+			logrus.WithField("username", name).Error("failed to promote bootstrap admin: ", err)
+			continue
+		}
+		logrus.WithField("username", name).Info("promoted bootstrap admin")
+	}
+}
+
+func (s *UserService) findUser(id uint) (*model.User, error) {
+
+	u, err := s.users.FindByID(id)
+	if err != nil {
+		return nil, userLookupError(err)
+	}
+	return u, nil
+}
+
+// findManagedUser 查询管理接口操作的用户。删除用户时创建的占位用户持有被匿名化的文章和评论，
+// 不是真实的账号，对管理接口来说不存在
+func (s *UserService) findManagedUser(id uint) (*model.User, error) {
+
+	u, err := s.findUser(id)
+	if err != nil {
+		return nil, err
+	}
+	if u.Username == model.GhostUsername {
+		return nil, userLookupError(repository.ErrNotFound)
+	}
+	return u, nil
+}
+
+func userLookupError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return notFoundError("user_not_found", "用户不存在")
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/repository"
+	"github.com/jheader/golang_blog/search"
+)
+
+func TestAdminMutationsRejectGhostUser(t *testing.T) {
+
+	repos := repository.NewMemoryRepositories()
+	users := NewUserService(repos.Users, repos.Tokens, repos.Posts, repos.Comments, search.NewIndex())
+	admin := createUser(t, repos, "admin")
+	bob := createUser(t, repos, "bob")
+	createPost(t, repos, bob, "Kept")
+	actor := Actor{UserID: admin.ID, Permissions: model.RolePermissions[model.RoleAdmin]}
+
+	// 匿名化删除 bob 后，他的文章归属于占位用户
+	if err := users.Delete(actor, bob.ID, DeleteModeAnonymize); err != nil {
+		t.Fatal(err)
+	}
+	ghost, err := repos.Users.FindByUsername(model.GhostUsername)
+	if err != nil {
+		t.Fatalf("ghost user not created: %v", err)
+	}
+
+	_, err = users.Disable(actor, ghost.ID)
+	assertAppError(t, err, "user_not_found")
+	_, err = users.Enable(ghost.ID)
+	assertAppError(t, err, "user_not_found")
+	_, err = users.ForcePasswordReset(ghost.ID)
+	assertAppError(t, err, "user_not_found")
+	_, err = users.SetRole(actor, ghost.ID, model.RoleAdmin, nil)
+	assertAppError(t, err, "user_not_found")
+	assertAppError(t, users.Delete(actor, ghost.ID, DeleteModeCascade), "user_not_found")
+
+	// 占位用户没有被修改（创建时即为停用状态），也没有被删除
+	got, err := repos.Users.FindByID(ghost.ID)
+	if err != nil || got.Role != model.RoleUser || !got.IsDisabled() || got.MustResetPassword {
+		t.Fatalf("ghost user modified: %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jheader/golang_blog/mail"
+	"github.com/jheader/golang_blog/model"
+	"github.com/jheader/golang_blog/repository"
+	"github.com/jheader/golang_blog/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// actionLink 邮件中的链接：APP_BASE_URL + path + ?token=
+func actionLink(path, token string) string {
+	return strings.TrimRight(viper.GetString("APP_BASE_URL"), "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// ResendVerification 重新发送邮箱验证邮件，邮箱已验证时返回 email_already_verified
+func (s *AuthService) ResendVerification(userID uint) error {
+
+	u, err := s.users.FindByID(userID)
+	if err != nil {
+		return userLookupError(err)
+	}
+	if u.IsEmailVerified() {
+		return conflictError("email_already_verified", "email has already been verified")
+	}
+	s.sendVerification(u)
+	return nil
+}
+
+// VerifyEmail 使用邮件中的令牌验证邮箱，令牌只能使用一次
+func (s *AuthService) VerifyEmail(token string) (*model.User, error) {
+
+	claims, u, err := s.redeem(token, model.TokenPurposeVerifyEmail)
+	if err != nil {
+		return nil, err
+	}
+	verified, err := s.users.MarkEmailVerified(u.ID, claims.Email)
+	if err != nil {
+		return nil, err
+	}
+	if !verified {
+		return nil, validationError("invalid_token", "token is invalid")
+	}
+	return s.users.FindByID(u.ID)
+}
+
+// ForgotPassword 向邮箱发送重置密码的链接。邮箱不存在或账号被停用时同样返回成功，不泄露邮箱是否注册
+func (s *AuthService) ForgotPassword(email string) error {
+
+	u, err := s.users.FindByEmail(strings.TrimSpace(email))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if u.Username == model.GhostUsername || u.IsDisabled() {
+		return nil
+	}
+
+	token, err := s.issueActionToken(u, model.TokenPurposeResetPassword, utils.PasswordResetTTL())
+	if err != nil {
+		return err
+	}
+	s.send(mail.Message{
+		To:      u.Email,
+		Subject: "重置密码 / Reset your password",
+		Body: fmt.Sprintf("%s，你好：\n\n点击下面的链接重置密码，链接在 %d 分钟内有效，只能使用一次：\n%s\n\n如果不是你本人的操作，请忽略这封邮件。\n",
+			u.Username, int(utils.PasswordResetTTL().Minutes()), actionLink("/reset-password", token)),
+	})
+	return nil
+}
+
+// ResetPassword 使用邮件中的令牌设置新密码：清除管理员要求重置的标记和登录失败锁定，
+// 作废其他重置链接并吊销所有刷新令牌。能收到邮件说明邮箱属于本人，同时视为邮箱已验证
+func (s *AuthService) ResetPassword(token, newPassword string) error {
+
+	claims, u, err := s.redeem(token, model.TokenPurposeResetPassword)
+	if err != nil {
+		return err
+	}
+	if u.IsDisabled() {
+		return accountDisabledError()
+	}
+
+	if err := s.users.UpdatePassword(u.ID, newPassword); err != nil {
+		return err
+	}
+	if err := s.tokens.RevokeActionTokens(u.ID, model.TokenPurposeResetPassword); err != nil {
+		logrus.Error(err)
+	}
+	if err := s.tokens.RevokeUser(u.ID); err != nil {
+		return err
+	}
+	if u.ClearLoginFailures() {
+		if err := s.users.UpdateLoginState(u); err != nil {
+			logrus.Error(err)
+		}
+	}
+	if !u.IsEmailVerified() {
+		if _, err := s.users.MarkEmailVerified(u.ID, claims.Email); err != nil {
+			logrus.Error(err)
+		}
+	}
+	return nil
+}
+
+// sendVerification 发送邮箱验证邮件，失败只记录日志，不影响注册等主流程
+func (s *AuthService) sendVerification(u *model.User) {
+
+	token, err := s.issueActionToken(u, model.TokenPurposeVerifyEmail, utils.EmailVerifyTTL())
+	if err != nil {
+		logrus.WithField("user_id", u.ID).Error("failed to issue email verification token: ", err)
+		return
+	}
+	s.send(mail.Message{
+		To:      u.Email,
+		Subject: "验证邮箱 / Verify your email",
+		Body: fmt.Sprintf("%s，你好：\n\n点击下面的链接验证邮箱，链接在 %d 小时内有效：\n%s\n",
+			u.Username, int(utils.EmailVerifyTTL().Hours()), actionLink("/verify-email", token)),
+	})
+}
+
+// issueActionToken 签发一次性令牌并记录 jti
+func (s *AuthService) issueActionToken(u *model.User, purpose string, ttl time.Duration) (string, error) {
+
+	token, claims, err := utils.GenerateActionToken(purpose, u.ID, u.Email, ttl)
+	if err != nil {
+		return "", err
+	}
+	err = s.tokens.CreateActionToken(&model.ActionToken{
+		JTI:       claims.ID,
+		UserID:    u.ID,
+		Purpose:   purpose,
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// redeem 校验一次性令牌并标记为已使用。签发后邮箱被修改的令牌视为无效
+func (s *AuthService) redeem(token, purpose string) (*utils.ActionClaims, *model.User, error) {
+
+	claims, err := utils.ParseActionToken(token, purpose)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, nil, validationError("token_expired", "token has expired")
+		}
+		return nil, nil, validationError("invalid_token", "token is invalid")
+	}
+	u, err := s.users.FindByID(claims.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil, validationError("invalid_token", "token is invalid")
+		}
+		return nil, nil, err
+	}
+	if u.Email != claims.Email {
+		return nil, nil, validationError("invalid_token", "token is invalid")
+	}
+	used, err := s.tokens.UseActionToken(claims.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !used {
+		return nil, nil, validationError("token_used", "token has already been used")
+	}
+	return claims, u, nil
+}
+
+// send 异步发送邮件，发送耗时不影响接口响应时间，也不会通过响应时间泄露邮箱是否注册
+func (s *AuthService) send(msg mail.Message) {
+	go func() {
+		if err := s.mailer.Send(msg); err != nil {
+			logrus.WithField("to", msg.To).Error("failed to send mail: ", err)
+		}
+	}()
+}
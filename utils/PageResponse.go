@@ -2,20 +2,23 @@ package utils
 
 import "math"
 
-type PageResponse struct {
-	List      interface{} `json:"list"`       // 数据列表
-	Total     int64       `json:"total"`      // 总条数
-	Page      int         `json:"page"`       // 当前页码
-	Size      int         `json:"size"`       // 每页条数
-	TotalPage int         `json:"total_page"` // 总页数
+type PageResponse[T any] struct {
+	List      []T   `json:"list"`       // 数据列表
+	Total     int64 `json:"total"`      // 总条数
+	Page      int   `json:"page"`       // 当前页码
+	Size      int   `json:"size"`       // 每页条数
+	TotalPage int   `json:"total_page"` // 总页数
 }
 
 // NewPageResponse 构建分页响应
-func NewPageResponse(list interface{}, total int64, page, size int) *PageResponse {
+func NewPageResponse[T any](list []T, total int64, page, size int) *PageResponse[T] {
 	if size <= 0 {
 		size = 10
 	}
-	return &PageResponse{
+	if list == nil {
+		list = []T{} // 空列表返回 [] 而不是 null
+	}
+	return &PageResponse[T]{
 		List:      list,
 		Total:     total,
 		Page:      page,
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+)
+
+// ActionClaims 邮件中一次性令牌（邮箱验证、重置密码）的内容。
+// Email 为签发时的邮箱，邮箱修改后旧令牌不再有效
+type ActionClaims struct {
+	UserID  uint   `json:"user_id"`
+	Email   string `json:"email"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// ErrInvalidActionToken 签名错误、格式错误或用途不符
+var ErrInvalidActionToken = errors.New("invalid action token")
+
+// EmailVerifyTTL 邮箱验证令牌有效期，默认48小时
+func EmailVerifyTTL() time.Duration {
+	hours := viper.GetInt("EMAIL_VERIFY_TTL_HOURS")
+	if hours <= 0 {
+		hours = 48
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// PasswordResetTTL 重置密码令牌有效期，默认30分钟
+func PasswordResetTTL() time.Duration {
+	minutes := viper.GetInt("PASSWORD_RESET_TTL_MINUTES")
+	if minutes <= 0 {
+		minutes = 30
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// actionTokenKey 由 JWT_SECRET 派生出单独的密钥，一次性令牌不能被当作访问令牌使用，反之亦然
+func actionTokenKey() []byte {
+	return derivedKey("action-token")
+}
+
+// derivedKey 用 HMAC 从 JWT_SECRET 派生出各用途专用的签名密钥
+func derivedKey(label string) []byte {
+	mac := hmac.New(sha256.New, []byte(viper.GetString("JWT_SECRET")))
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
+// GenerateActionToken 签发一次性令牌，返回令牌及其内容，调用方需保存 jti 以保证只能使用一次
+func GenerateActionToken(purpose string, userID uint, email string, ttl time.Duration) (string, *ActionClaims, error) {
+
+	jti, err := RandomToken(16)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	claims := &ActionClaims{
+		UserID:  userID,
+		Email:   email,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(actionTokenKey())
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
+}
+
+// ParseActionToken 校验签名、有效期和用途。过期时返回的错误满足 errors.Is(err, jwt.ErrTokenExpired)，
+// 其余情况返回 ErrInvalidActionToken
+func ParseActionToken(tokenString, purpose string) (*ActionClaims, error) {
+
+	claims := &ActionClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return actionTokenKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, err
+		}
+		return nil, ErrInvalidActionToken
+	}
+	if claims.Purpose != purpose || claims.ID == "" {
+		return nil, ErrInvalidActionToken
+	}
+	return claims, nil
+}
@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Cursor 游标分页中的位置，即上一次返回的边界记录的 (created_at, id)。
+// 编码后对客户端是不透明的字符串，客户端只需原样传回
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uint      `json:"i"`
+	// Prev 为 true 时取该位置之前的一页（prev_cursor），否则取之后的一页（next_cursor）
+	Prev bool `json:"p,omitempty"`
+	// Desc 生成游标时的排序方向，与当前请求的排序不一致时游标无效
+	Desc bool `json:"d,omitempty"`
+}
+
+// CursorQuery 游标分页参数，列表按 (created_at, id) 排序，不做 COUNT 查询
+type CursorQuery struct {
+	// Cursor 为 nil 表示第一页
+	Cursor *Cursor
+	Limit  int
+	Desc   bool
+}
+
+// CursorResponse 游标分页响应，没有更多数据的方向返回空字符串
+type CursorResponse[T any] struct {
+	List       []T    `json:"list"`
+	NextCursor string `json:"next_cursor"`
+	PrevCursor string `json:"prev_cursor"`
+	Limit      int    `json:"limit"`
+}
+
+// ParseCursorQuery 解析 cursor/limit 参数，两者都没有时返回 false，调用方继续使用 page/size 分页。
+// 游标分页只支持按创建时间排序，q 中的排序必须是 created_at 或 -created_at
+func ParseCursorQuery(values url.Values, q ListQuery) (CursorQuery, bool, error) {
+
+	if !values.Has("cursor") && !values.Has("limit") {
+		return CursorQuery{}, false, nil
+	}
+	if len(q.Sorts) != 1 || q.Sorts[0].Column != "created_at" {
+		return CursorQuery{}, false, NewAppError(ErrValidation, "invalid_cursor", "cursor pagination only supports sort=created_at or sort=-created_at").
+			WithFields(FieldError{Field: "sort", Rule: "cursor", Message: "must be created_at or -created_at"})
+	}
+
+	cq := CursorQuery{Limit: 10, Desc: q.Sorts[0].Desc}
+	limit, err := strconv.Atoi(values.Get("limit"))
+	if err == nil && limit > 0 && limit <= 100 {
+		cq.Limit = limit // 与 size 一致：非法值默认10条，最大100
+	}
+	if raw := values.Get("cursor"); raw != "" {
+		cursor, err := DecodeCursor(raw)
+		if err != nil || cursor.Desc != cq.Desc {
+			return CursorQuery{}, false, NewAppError(ErrValidation, "invalid_cursor", "cursor is malformed or does not match the sort order").
+				WithFields(FieldError{Field: "cursor", Rule: "cursor", Message: "invalid cursor"})
+		}
+		cq.Cursor = &cursor
+	}
+	return cq, true, nil
+}
+
+func EncodeCursor(c Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
+
+// Backward 本次查询实际的扫描方向：向前翻页时与列表顺序相反
+func (cq CursorQuery) Backward() bool {
+	return cq.Cursor != nil && cq.Cursor.Prev
+}
+
+// scanDesc 扫描时 (created_at, id) 是否倒序
+func (cq CursorQuery) scanDesc() bool {
+	return cq.Desc != cq.Backward()
+}
+
+// Scope 按游标位置过滤并排序，多取一条用于判断是否还有更多数据。
+// 结果按扫描方向排列，交给 NewCursorResponse 截断并恢复列表顺序
+func (cq CursorQuery) Scope() func(db *gorm.DB) *gorm.DB {
+
+	return func(db *gorm.DB) *gorm.DB {
+		op, order := ">", "created_at ASC, id ASC"
+		if cq.scanDesc() {
+			op, order = "<", "created_at DESC, id DESC"
+		}
+		if c := cq.Cursor; c != nil {
+			// 不用行值比较 (created_at, id) < (?, ?)，部分数据库不支持或用不上索引
+			db = db.Where("created_at "+op+" ? OR (created_at = ? AND id "+op+" ?)", c.CreatedAt, c.CreatedAt, c.ID)
+		}
+		return db.Order(order).Limit(cq.Limit + 1)
+	}
+}
+
+// After 判断 (createdAt, id) 是否位于游标之后（按扫描方向），供内存实现使用
+func (cq CursorQuery) After(createdAt time.Time, id uint) bool {
+
+	cursor := cq.Cursor
+	if cursor == nil {
+		return true
+	}
+	c := createdAt.Compare(cursor.CreatedAt)
+	if c == 0 {
+		c = cmp.Compare(id, cursor.ID)
+	}
+	if cq.scanDesc() {
+		return c < 0
+	}
+	return c > 0
+}
+
+// Less 按扫描方向比较两条记录，供内存实现排序
+func (cq CursorQuery) Less(aCreatedAt time.Time, aID uint, bCreatedAt time.Time, bID uint) bool {
+
+	c := aCreatedAt.Compare(bCreatedAt)
+	if c == 0 {
+		c = cmp.Compare(aID, bID)
+	}
+	if cq.scanDesc() {
+		return c > 0
+	}
+	return c < 0
+}
+
+// NewCursorResponse 根据按扫描方向取到的至多 Limit+1 条记录构建响应，key 返回记录的 (created_at, id)
+func NewCursorResponse[T any](rows []T, cq CursorQuery, key func(T) (time.Time, uint)) *CursorResponse[T] {
+
+	hasMore := len(rows) > cq.Limit
+	if hasMore {
+		rows = rows[:cq.Limit]
+	}
+	if cq.Backward() {
+		rows = slices.Clone(rows)
+		slices.Reverse(rows)
+	}
+	resp := &CursorResponse[T]{List: rows, Limit: cq.Limit}
+	if resp.List == nil {
+		resp.List = []T{} // 空列表返回 [] 而不是 null
+	}
+	if len(rows) == 0 {
+		return resp
+	}
+
+	cursorAt := func(item T, prev bool) string {
+		t, id := key(item)
+		return EncodeCursor(Cursor{CreatedAt: t, ID: id, Prev: prev, Desc: cq.Desc})
+	}
+	// 向后翻页时，还有更多数据才有下一页，带了游标才有上一页；向前翻页正好相反
+	if hasMore || cq.Backward() {
+		resp.NextCursor = cursorAt(rows[len(rows)-1], false)
+	}
+	if (cq.Backward() && hasMore) || (!cq.Backward() && cq.Cursor != nil) {
+		resp.PrevCursor = cursorAt(rows[0], true)
+	}
+	return resp
+}
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2025, 3, 1, 8, 30, 0, 123456789, time.UTC)
+	for _, c := range []Cursor{
+		{CreatedAt: at, ID: 42},
+		{CreatedAt: at, ID: 42, Desc: true},
+		{CreatedAt: at, ID: 7, Prev: true, Desc: true},
+	} {
+		got, err := DecodeCursor(EncodeCursor(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.CreatedAt.Equal(c.CreatedAt) || got.ID != c.ID || got.Prev != c.Prev || got.Desc != c.Desc {
+			t.Fatalf("round trip of %+v returned %+v", c, got)
+		}
+	}
+}
+
+func TestParseCursorQuery(t *testing.T) {
+	at := time.Date(2025, 3, 1, 8, 30, 0, 0, time.UTC)
+	desc := ListQuery{Sorts: []SortField{{Column: "created_at", Desc: true}}}
+	asc := ListQuery{Sorts: []SortField{{Column: "created_at"}}}
+
+	tests := []struct {
+		name    string
+		values  url.Values
+		q       ListQuery
+		wantErr bool
+	}{
+		{name: "valid desc cursor", values: url.Values{"cursor": {EncodeCursor(Cursor{CreatedAt: at, ID: 1, Desc: true})}}, q: desc},
+		{name: "valid asc cursor", values: url.Values{"cursor": {EncodeCursor(Cursor{CreatedAt: at, ID: 1})}}, q: asc},
+		// 游标的排序方向与请求不一致
+		{name: "asc cursor on desc list", values: url.Values{"cursor": {EncodeCursor(Cursor{CreatedAt: at, ID: 1})}}, q: desc, wantErr: true},
+		{name: "desc cursor on asc list", values: url.Values{"cursor": {EncodeCursor(Cursor{CreatedAt: at, ID: 1, Desc: true})}}, q: asc, wantErr: true},
+		{name: "not base64", values: url.Values{"cursor": {"!!!"}}, q: desc, wantErr: true},
+		{name: "not json", values: url.Values{"cursor": {"bm90IGpzb24"}}, q: desc, wantErr: true},
+		{name: "truncated", values: url.Values{"cursor": {EncodeCursor(Cursor{CreatedAt: at, ID: 1, Desc: true})[:10]}}, q: desc, wantErr: true},
+		{name: "unsupported sort", values: url.Values{"limit": {"5"}}, q: ListQuery{Sorts: []SortField{{Column: "title"}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cq, ok, err := ParseCursorQuery(tt.values, tt.q)
+			if tt.wantErr {
+				appErr, isApp := err.(*AppError)
+				if !isApp || appErr.Code != "invalid_cursor" {
+					t.Fatalf("expected invalid_cursor, got %v", err)
+				}
+				return
+			}
+			if err != nil || !ok || cq.Cursor == nil || cq.Cursor.ID != 1 {
+				t.Fatalf("unexpected result %+v %v %v", cq, ok, err)
+			}
+		})
+	}
+
+	// 没有 cursor/limit 参数时回退到 page/size 分页
+	if _, ok, err := ParseCursorQuery(url.Values{"page": {"2"}}, desc); ok || err != nil {
+		t.Fatalf("expected page pagination, got %v %v", ok, err)
+	}
+}
+
+type cursorRow struct {
+	CreatedAt time.Time
+	ID        uint
+}
+
+// page 用 After/Less 模拟仓储的查询，返回一页记录和响应中的游标
+func page(rows []cursorRow, cq CursorQuery) *CursorResponse[cursorRow] {
+	var matched []cursorRow
+	for _, r := range rows {
+		if cq.After(r.CreatedAt, r.ID) {
+			matched = append(matched, r)
+		}
+	}
+	slices.SortFunc(matched, func(a, b cursorRow) int {
+		if cq.Less(a.CreatedAt, a.ID, b.CreatedAt, b.ID) {
+			return -1
+		}
+		return 1
+	})
+	if len(matched) > cq.Limit+1 {
+		matched = matched[:cq.Limit+1]
+	}
+	return NewCursorResponse(matched, cq, func(r cursorRow) (time.Time, uint) { return r.CreatedAt, r.ID })
+}
+
+// 多条记录的创建时间相同，翻页时按 id 区分，不重复也不遗漏；prev_cursor 回到上一页
+func TestCursorPagingWithTies(t *testing.T) {
+	at := time.Date(2025, 3, 1, 8, 30, 0, 0, time.UTC)
+	var rows []cursorRow
+	for id := uint(1); id <= 7; id++ {
+		rows = append(rows, cursorRow{CreatedAt: at.Add(time.Duration(id/3) * time.Second), ID: id})
+	}
+
+	for _, desc := range []bool{false, true} {
+		q := ListQuery{Sorts: []SortField{{Column: "created_at", Desc: desc}}}
+		cq, _, err := ParseCursorQuery(url.Values{"limit": {"3"}}, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var seen []uint
+		var pages []*CursorResponse[cursorRow]
+		for {
+			resp := page(rows, cq)
+			pages = append(pages, resp)
+			for _, r := range resp.List {
+				seen = append(seen, r.ID)
+			}
+			if resp.NextCursor == "" {
+				break
+			}
+			cq, _, err = ParseCursorQuery(url.Values{"cursor": {resp.NextCursor}, "limit": {"3"}}, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		want := []uint{1, 2, 3, 4, 5, 6, 7}
+		if desc {
+			slices.Reverse(want)
+		}
+		if !slices.Equal(seen, want) {
+			t.Fatalf("desc=%v: pages returned %v, want %v", desc, seen, want)
+		}
+		if pages[0].PrevCursor != "" {
+			t.Fatalf("desc=%v: first page must not have prev_cursor", desc)
+		}
+
+		// 从最后一页向前翻，得到倒数第二页
+		last := pages[len(pages)-1]
+		cq, _, err = ParseCursorQuery(url.Values{"cursor": {last.PrevCursor}, "limit": {"3"}}, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prev := page(rows, cq)
+		if !slices.Equal(prev.List, pages[len(pages)-2].List) {
+			t.Fatalf("desc=%v: prev page %v, want %v", desc, prev.List, pages[len(pages)-2].List)
+		}
+	}
+}
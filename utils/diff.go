@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// diffContext 统一格式 diff 中每个变更块前后保留的上下文行数
+const diffContext = 3
+
+// 限制 diff 的规模：Myers 算法的时间为 O((N+M)·D)，回溯记录的内存为 O(D²)，
+// 两段内容的总行数和编辑距离 D 都有上限，避免一次请求占用大量内存和 CPU
+const (
+	maxDiffLines = 20000
+	maxDiffEdits = 1000
+)
+
+// ErrDiffTooLarge 两个版本的行数或差异超过上限，不生成 diff
+var ErrDiffTooLarge = errors.New("diff too large")
+
+type diffOp struct {
+	kind byte // ' ' 相同, '-' 删除, '+' 新增
+	line string
+}
+
+// UnifiedDiff 按行比较 a、b，输出 unified diff 格式的文本，内容相同时返回空字符串；
+// 超过 maxDiffLines 行或 maxDiffEdits 处修改时返回 ErrDiffTooLarge
+func UnifiedDiff(fromName, toName, a, b string) (string, error) {
+	aLines, bLines := splitLines(a), splitLines(b)
+	if len(aLines)+len(bLines) > maxDiffLines {
+		return "", ErrDiffTooLarge
+	}
+	ops, ok := diffLines(aLines, bLines, maxDiffEdits)
+	if !ok {
+		return "", ErrDiffTooLarge
+	}
+
+	// aPos[i]/bPos[i] 为第 i 个操作之前已经过的 a、b 行数，用于计算块头的行号
+	aPos := make([]int, len(ops)+1)
+	bPos := make([]int, len(ops)+1)
+	for i, op := range ops {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if op.kind != '+' {
+			aPos[i+1]++
+		}
+		if op.kind != '-' {
+			bPos[i+1]++
+		}
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(ops); {
+		// 找到下一个变更
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+		start := max(0, i-diffContext)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			j := end
+			for j < len(ops) && ops[j].kind == ' ' {
+				j++
+			}
+			// 两个变更之间相同的行太多，拆成两个块
+			if j == len(ops) || j-end > 2*diffContext {
+				end = min(len(ops), end+diffContext)
+				break
+			}
+			end = j
+		}
+
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		aCount, bCount := aPos[end]-aPos[start], bPos[end]-bPos[start]
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aPos[start], aCount), hunkRange(bPos[start], bCount))
+		for _, op := range ops[start:end] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.line)
+			sb.WriteByte('\n')
+		}
+		i = end
+	}
+	return sb.String(), nil
+}
+
+func hunkRange(pos, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", pos)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", pos+1)
+	}
+	return fmt.Sprintf("%d,%d", pos+1, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines Myers 差分算法，返回把 a 变成 b 的最短编辑序列；编辑距离超过 maxEdits 时返回 false。
+// 第 d 步只会用到上一步 k ∈ [-(d-1), d-1] 的结果，回溯记录只保存这一段，内存为 O(D²)
+func diffLines(a, b []string, maxEdits int) ([]diffOp, bool) {
+	n, m := len(a), len(b)
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	// trace[d] 第 d 步开始前 v[offset-d+1 : offset+d] 的副本，下标 k 对应 trace[d][k+d-1]
+	var trace [][]int
+
+search:
+	for d := 0; d <= offset; d++ {
+		if d > maxEdits {
+			return nil, false
+		}
+		if d == 0 {
+			trace = append(trace, nil)
+		} else {
+			trace = append(trace, append([]int(nil), v[offset-d+1:offset+d]...))
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// 从终点回溯出编辑路径
+	ops := make([]diffOp, 0, n+m)
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		k := x - y
+		if d == 0 {
+			// 起点之后只有相同的行
+			for x > 0 && y > 0 {
+				ops = append(ops, diffOp{' ', a[x-1]})
+				x--
+				y--
+			}
+			break
+		}
+		prev := trace[d]
+		var prevK int
+		if k == -d || (k != d && prev[k-1+d-1] < prev[k+1+d-1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[prevK+d-1]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, diffOp{'+', b[y-1]})
+			y--
+		} else {
+			ops = append(ops, diffOp{'-', a[x-1]})
+			x--
+		}
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops, true
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// lines 把 1..n 拼成每行一个数字的文本，replace 中的行替换为对应内容
+func lines(n int, replace map[int]string) string {
+	var sb strings.Builder
+	for i := 1; i <= n; i++ {
+		if s, ok := replace[i]; ok {
+			sb.WriteString(s)
+		} else {
+			fmt.Fprintf(&sb, "%d", i)
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{name: "both empty", a: "", b: "", want: ""},
+		{name: "equal", a: "a\nb\n", b: "a\r\nb", want: ""},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "a\nb\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "to empty",
+			a:    "a\n",
+			b:    "",
+			want: "--- old\n+++ new\n@@ -1 +0,0 @@\n-a\n",
+		},
+		{
+			name: "change in the middle keeps three lines of context",
+			a:    lines(9, nil),
+			b:    lines(9, map[int]string{5: "five"}),
+			want: "--- old\n+++ new\n@@ -2,7 +2,7 @@\n2\n3\n4\n-5\n+five\n6\n7\n8\n",
+		},
+		{
+			name: "insertion at the start",
+			a:    "b\nc\n",
+			b:    "a\nb\nc\n",
+			want: "--- old\n+++ new\n@@ -1,2 +1,3 @@\n+a\n b\n c\n",
+		},
+		{
+			// 两处修改之间恰好 2*diffContext 行相同，合并为一个块
+			name: "changes six lines apart share a hunk",
+			a:    lines(10, nil),
+			b:    lines(10, map[int]string{2: "two", 9: "nine"}),
+			want: "--- old\n+++ new\n@@ -1,10 +1,10 @@\n1\n-2\n+two\n3\n4\n5\n6\n7\n8\n-9\n+nine\n10\n",
+		},
+		{
+			// 相隔 7 行，拆成两个块
+			name: "changes seven lines apart are split",
+			a:    lines(12, nil),
+			b:    lines(12, map[int]string{2: "two", 10: "ten"}),
+			want: "--- old\n+++ new\n" +
+				"@@ -1,5 +1,5 @@\n1\n-2\n+two\n3\n4\n5\n" +
+				"@@ -7,6 +7,6 @@\n7\n8\n9\n-10\n+ten\n11\n12\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 期望值中上下文行省略了前导空格，这里补上
+			want := contextLines(tt.want)
+			got, err := UnifiedDiff("old", "new", tt.a, tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Fatalf("diff mismatch\ngot:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+// contextLines 给没有 diff 前缀的行加上表示相同的空格
+func contextLines(s string) string {
+	if s == "" {
+		return ""
+	}
+	out := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	for i, l := range out {
+		if !strings.HasPrefix(l, "---") && !strings.HasPrefix(l, "+++") && !strings.HasPrefix(l, "@@") &&
+			!strings.HasPrefix(l, "+") && !strings.HasPrefix(l, "-") && !strings.HasPrefix(l, " ") {
+			out[i] = " " + l
+		}
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+func TestUnifiedDiffTooLarge(t *testing.T) {
+	// 行数超过上限
+	long := strings.Repeat("x\n", maxDiffLines)
+	if _, err := UnifiedDiff("old", "new", long, "y\n"); !errors.Is(err, ErrDiffTooLarge) {
+		t.Fatalf("expected ErrDiffTooLarge for too many lines, got %v", err)
+	}
+
+	// 行数在上限内，但每一行都不同，编辑距离超过上限
+	var a, b strings.Builder
+	for i := range maxDiffEdits {
+		fmt.Fprintf(&a, "a%d\n", i)
+		fmt.Fprintf(&b, "b%d\n", i)
+	}
+	if _, err := UnifiedDiff("old", "new", a.String(), b.String()); !errors.Is(err, ErrDiffTooLarge) {
+		t.Fatalf("expected ErrDiffTooLarge for too many edits, got %v", err)
+	}
+
+	// 内容很长但修改很少，正常生成
+	big := lines(maxDiffLines/2, nil)
+	changed := lines(maxDiffLines/2, map[int]string{maxDiffLines / 4: "changed"})
+	diff, err := UnifiedDiff("old", "new", big, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "+changed\n") {
+		t.Fatalf("unexpected diff %q", diff)
+	}
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 领域错误的类别，service 层返回的错误都应能用 errors.Is 归到其中一类，
+// 由 RespondError 统一映射为 HTTP 状态码
+var (
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	// 条件请求：If-Match 与当前版本不一致 / 缺少 If-Match
+	ErrPreconditionFailed   = errors.New("precondition failed")
+	ErrPreconditionRequired = errors.New("precondition required")
+	// 请求合法，但处理的数据超出限制（例如两个版本的 diff 太大）
+	ErrUnprocessable = errors.New("unprocessable entity")
+)
+
+// AppError 带有稳定错误码的领域错误。
+// Code 是给客户端判断用的机器可读错误码（如 post_not_found），Message 是给人看的描述
+type AppError struct {
+	Kind    error
+	Code    string
+	Message string
+	Fields  []FieldError // 可选，字段级校验错误
+	Err     error
+}
+
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *AppError) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+// WithCause 记录底层错误，只用于日志，不会返回给客户端
+func (e *AppError) WithCause(err error) *AppError {
+	e.Err = err
+	return e
+}
+
+// WithFields 附带字段级校验错误
+func (e *AppError) WithFields(fields ...FieldError) *AppError {
+	e.Fields = append(e.Fields, fields...)
+	return e
+}
+
+func NewAppError(kind error, code, message string) *AppError {
+	return &AppError{Kind: kind, Code: code, Message: message}
+}
@@ -0,0 +1,13 @@
+package utils
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// CanonicalIdentity 用户名、邮箱的规范形式：去掉首尾空白，NFKC 规范化后转小写。
+// "Alice" 与 "alice"、全角的 "ａｌｉｃｅ" 得到相同的结果，唯一性和登录查询都使用规范形式
+func CanonicalIdentity(s string) string {
+	return strings.ToLower(norm.NFKC.String(strings.TrimSpace(s)))
+}
@@ -1,6 +1,10 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -12,17 +16,46 @@ import (
 type CustomClaims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
+	// Role 和 Permissions 为签发时用户的角色和有效权限，供客户端展示；服务端鉴权以数据库中的用户为准
+	Role        string   `json:"role,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uint, username string) (string, error) {
+// AccessTokenTTL 访问令牌有效期，默认2小时
+func AccessTokenTTL() time.Duration {
+	minutes := viper.GetInt("ACCESS_TOKEN_TTL_MINUTES")
+	if minutes <= 0 {
+		minutes = 120
+	}
+	return time.Duration(minutes) * time.Minute
+}
 
+// RefreshTokenTTL 刷新令牌有效期，默认30天
+func RefreshTokenTTL() time.Duration {
+	hours := viper.GetInt("REFRESH_TOKEN_TTL_HOURS")
+	if hours <= 0 {
+		hours = 720
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func GenerateToken(userID uint, username, role string, permissions []string) (string, error) {
+
+	jti, err := RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
 	claims := CustomClaims{
-		UserID:   userID,
-		Username: username,
+		UserID:      userID,
+		Username:    username,
+		Role:        role,
+		Permissions: permissions,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(2 * time.Hour)), //2小时过期
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        jti, // 用于吊销
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL())),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -35,7 +68,7 @@ func ParseToken(tokenString string) (*CustomClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(t *jwt.Token) (interface{}, error) {
 
 		return []byte(viper.GetString("JWT_SECRET")), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		logrus.Error("token 解析失败", err)
@@ -49,3 +82,18 @@ func ParseToken(tokenString string) (*CustomClaims, error) {
 
 	return nil, errors.New("invalid token")
 }
+
+// RandomToken 生成 n 字节的随机串（URL安全的base64编码）
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken 对不透明令牌做 sha256，数据库中只保存哈希
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,234 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 过滤参数的值类型
+const (
+	FilterUint   = "uint"
+	FilterTime   = "time"
+	FilterString = "string"
+)
+
+// ListSpec 列表接口允许的排序字段、过滤参数和返回字段（白名单）。
+// 参数名与数据库列名分开配置，客户端只能使用白名单中的参数名，列名不会来自请求
+type ListSpec struct {
+	// Sorts 可排序的字段：参数名 -> 列名
+	Sorts map[string]string
+	// DefaultSort 未指定 sort 时的排序，格式同 sort 参数，例如 "-created_at"
+	DefaultSort string
+	Filters     map[string]FilterSpec
+	// Fields 可在 fields 参数中选择的 JSON 字段
+	Fields []string
+}
+
+// FilterSpec 过滤参数对应的列、比较方式（=、>=、<）和值类型
+type FilterSpec struct {
+	Column string
+	Op     string
+	Type   string
+}
+
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+type Filter struct {
+	Column string
+	Op     string
+	Value  interface{}
+}
+
+// ListQuery 解析后的列表参数，零值表示不过滤、使用默认排序、返回全部字段
+type ListQuery struct {
+	Sorts   []SortField
+	Filters []Filter
+	Fields  []string
+}
+
+// ParseListQuery 解析 sort、fields 和过滤参数：
+//
+//	sort=-created_at,title   多个字段用逗号分隔，"-" 表示倒序
+//	author_id=1&created_after=2025-01-01
+//	fields=id,title,user     只返回这些字段
+//
+// 不在白名单中的排序字段、返回字段，以及格式错误的过滤值都会返回校验错误；
+// 白名单之外的其他查询参数会被忽略，以兼容已有的参数（如 postId）
+func ParseListQuery(values url.Values, spec ListSpec) (ListQuery, error) {
+
+	var q ListQuery
+	var fields []FieldError
+
+	sort := values.Get("sort")
+	if sort == "" {
+		sort = spec.DefaultSort
+	}
+	for _, name := range splitList(sort) {
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+		column, ok := spec.Sorts[name]
+		if !ok {
+			fields = append(fields, FieldError{Field: "sort", Rule: "whitelist",
+				Message: fmt.Sprintf("cannot sort by %q, allowed: %s", name, strings.Join(sortedKeys(spec.Sorts), ", "))})
+			continue
+		}
+		q.Sorts = append(q.Sorts, SortField{Column: column, Desc: desc})
+	}
+
+	for name, filter := range spec.Filters {
+		raw := values.Get(name)
+		if raw == "" {
+			continue
+		}
+		value, err := parseFilterValue(raw, filter.Type)
+		if err != nil {
+			fields = append(fields, FieldError{Field: name, Rule: filter.Type, Message: err.Error()})
+			continue
+		}
+		q.Filters = append(q.Filters, Filter{Column: filter.Column, Op: filter.Op, Value: value})
+	}
+	// map 遍历顺序不固定，排序后生成的 SQL 才稳定
+	slices.SortFunc(q.Filters, func(a, b Filter) int { return strings.Compare(a.Column+a.Op, b.Column+b.Op) })
+
+	for _, name := range splitList(values.Get("fields")) {
+		if !slices.Contains(spec.Fields, name) {
+			fields = append(fields, FieldError{Field: "fields", Rule: "whitelist",
+				Message: fmt.Sprintf("unknown field %q, allowed: %s", name, strings.Join(spec.Fields, ", "))})
+			continue
+		}
+		if !slices.Contains(q.Fields, name) {
+			q.Fields = append(q.Fields, name)
+		}
+	}
+
+	if len(fields) > 0 {
+		return ListQuery{}, NewAppError(ErrValidation, "invalid_list_query", "invalid sort, filter or fields parameter").
+			WithFields(fields...)
+	}
+	return q, nil
+}
+
+// FilterScope 把过滤条件应用到查询上，列名都来自 ListSpec 白名单
+func (q ListQuery) FilterScope() func(db *gorm.DB) *gorm.DB {
+
+	return func(db *gorm.DB) *gorm.DB {
+		for _, f := range q.Filters {
+			column := clause.Column{Name: f.Column}
+			switch f.Op {
+			case ">=":
+				db = db.Where(clause.Gte{Column: column, Value: f.Value})
+			case "<":
+				db = db.Where(clause.Lt{Column: column, Value: f.Value})
+			default:
+				db = db.Where(clause.Eq{Column: column, Value: f.Value})
+			}
+		}
+		return db
+	}
+}
+
+// SortScope 应用排序，排序字段相同时按主键排序，保证分页结果稳定。
+// 主键的方向跟随最后一个排序字段，例如 sort=-views,title 时按 id 升序
+func (q ListQuery) SortScope() func(db *gorm.DB) *gorm.DB {
+
+	return func(db *gorm.DB) *gorm.DB {
+		for _, s := range q.Sorts {
+			db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: s.Column}, Desc: s.Desc})
+		}
+		if last, ok := q.lastSort(); ok && last.Column != "id" {
+			db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: last.Desc})
+		}
+		return db
+	}
+}
+
+// lastSort 最后一个排序字段，决定主键兜底排序的方向
+func (q ListQuery) lastSort() (SortField, bool) {
+	if len(q.Sorts) == 0 {
+		return SortField{}, false
+	}
+	return q.Sorts[len(q.Sorts)-1], true
+}
+
+// TieBreakDesc 排序字段相同时主键是否降序，与 SortScope 一致
+func (q ListQuery) TieBreakDesc() bool {
+	last, _ := q.lastSort()
+	return last.Desc
+}
+
+// SelectFields 按 fields 裁剪列表中每一项的 JSON 字段，不存在的字段直接忽略
+func SelectFields[T any](list []T, fields []string) ([]map[string]json.RawMessage, error) {
+
+	selected := make([]map[string]json.RawMessage, 0, len(list))
+	for _, item := range list {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(data, &all); err != nil {
+			return nil, err
+		}
+		m := make(map[string]json.RawMessage, len(fields))
+		for _, f := range fields {
+			if v, ok := all[f]; ok {
+				m[f] = v
+			}
+		}
+		selected = append(selected, m)
+	}
+	return selected, nil
+}
+
+func parseFilterValue(raw, kind string) (interface{}, error) {
+
+	switch kind {
+	case FilterUint:
+		v, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a positive integer")
+		}
+		return uint(v), nil
+	case FilterTime:
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			return t, nil
+		}
+		t, err := time.ParseInLocation(time.DateOnly, raw, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("must be a date (2006-01-02) or RFC3339 time")
+		}
+		return t, nil
+	default:
+		return raw, nil
+	}
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
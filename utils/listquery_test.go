@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+var testListSpec = ListSpec{
+	Sorts:       map[string]string{"id": "id", "title": "title", "views": "view_count", "created_at": "created_at"},
+	DefaultSort: "-created_at",
+	Filters: map[string]FilterSpec{
+		"author_id":     {Column: "user_id", Op: "=", Type: FilterUint},
+		"created_after": {Column: "created_at", Op: ">=", Type: FilterTime},
+	},
+	Fields: []string{"id", "title"},
+}
+
+// dryRunMySQL 不连接数据库，只用 MySQL 方言生成 SQL
+func dryRunMySQL(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:3306)/blog", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSortScopeTieBreak(t *testing.T) {
+	tests := []struct {
+		sort     string
+		wantDesc bool
+		want     string
+	}{
+		{"", true, "ORDER BY `created_at` DESC,`id` DESC"},
+		{"created_at", false, "ORDER BY `created_at`,`id`"},
+		// 主键的方向跟随最后一个排序字段
+		{"-views,title", false, "ORDER BY `view_count` DESC,`title`,`id`"},
+		{"title,-views", true, "ORDER BY `title`,`view_count` DESC,`id` DESC"},
+		// 已经按主键排序时不再追加
+		{"-id", true, "ORDER BY `id` DESC LIMIT"},
+	}
+	db := dryRunMySQL(t)
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			q, err := ParseListQuery(url.Values{"sort": {tt.sort}}, testListSpec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q.TieBreakDesc() != tt.wantDesc {
+				t.Fatalf("TieBreakDesc() = %v, want %v", q.TieBreakDesc(), tt.wantDesc)
+			}
+			sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+				var rows []map[string]interface{}
+				return tx.Table("posts").Scopes(q.SortScope()).Limit(1).Find(&rows)
+			})
+			if !strings.Contains(sql, tt.want) {
+				t.Fatalf("expected %q in\n%s", tt.want, sql)
+			}
+		})
+	}
+}
+
+func TestParseListQueryRejectsUnknownParameters(t *testing.T) {
+	tests := []struct {
+		name   string
+		values url.Values
+		field  string
+	}{
+		{"unknown sort", url.Values{"sort": {"password"}}, "sort"},
+		{"unknown field", url.Values{"fields": {"id,password"}}, "fields"},
+		{"malformed uint", url.Values{"author_id": {"-1"}}, "author_id"},
+		{"malformed time", url.Values{"created_after": {"yesterday"}}, "created_after"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseListQuery(tt.values, testListSpec)
+			appErr, ok := err.(*AppError)
+			if !ok || appErr.Code != "invalid_list_query" {
+				t.Fatalf("expected invalid_list_query, got %v", err)
+			}
+			if len(appErr.Fields) != 1 || appErr.Fields[0].Field != tt.field {
+				t.Fatalf("expected a field error on %q, got %+v", tt.field, appErr.Fields)
+			}
+		})
+	}
+
+	// 白名单之外的参数直接忽略
+	q, err := ParseListQuery(url.Values{"postId": {"1"}, "fields": {"title,id,title"}}, testListSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(q.Fields, ",") != "title,id" {
+		t.Fatalf("unexpected fields %v", q.Fields)
+	}
+}
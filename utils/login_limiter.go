@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// LoginLimiter 按客户端IP统计登录失败次数（内存实现），
+// 用户维度的失败次数和锁定状态持久化在 model.User 上
+type LoginLimiter struct {
+	mu          sync.Mutex
+	maxAttempts int
+	lockFor     time.Duration
+	entries     map[string]*loginEntry
+	lastPrune   time.Time
+}
+
+type loginEntry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+func NewLoginLimiter(maxAttempts int, lockFor time.Duration) *LoginLimiter {
+	return &LoginLimiter{
+		maxAttempts: maxAttempts,
+		lockFor:     lockFor,
+		entries:     make(map[string]*loginEntry),
+	}
+}
+
+// Locked 返回该IP是否处于锁定期，以及锁定截止时间
+func (l *LoginLimiter) Locked(ip string) (bool, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[ip]
+	if !ok {
+		return false, time.Time{}
+	}
+	now := time.Now()
+	if now.Before(e.lockedUntil) {
+		return true, e.lockedUntil
+	}
+	if l.expired(e, now) {
+		delete(l.entries, ip)
+	}
+	return false, time.Time{}
+}
+
+// Fail 记录一次失败，达到上限后锁定该IP
+func (l *LoginLimiter) Fail(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+	e, ok := l.entries[ip]
+	if !ok {
+		e = &loginEntry{}
+		l.entries[ip] = e
+	}
+	// 距上次失败已超过一个锁定窗口，重新计数
+	if now.Sub(e.lastFailure) > l.lockFor {
+		e.failures = 0
+	}
+	e.lastFailure = now
+	e.failures++
+	if l.maxAttempts > 0 && e.failures >= l.maxAttempts {
+		e.failures = 0
+		e.lockedUntil = now.Add(l.lockFor)
+	}
+}
+
+// Reset 登录成功后清除该IP的失败记录
+func (l *LoginLimiter) Reset(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, ip)
+}
+
+// expired 锁定已结束且距上次失败超过一个窗口的记录不再影响任何判断
+func (l *LoginLimiter) expired(e *loginEntry, now time.Time) bool {
+	return !now.Before(e.lockedUntil) && now.Sub(e.lastFailure) > l.lockFor
+}
+
+// prune 清理过期的记录，避免大量不同 IP 的失败记录让 map 无限增长。
+// 每个窗口最多全量扫描一次，调用方需持有锁
+func (l *LoginLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < l.lockFor {
+		return
+	}
+	l.lastPrune = now
+	for ip, e := range l.entries {
+		if l.expired(e, now) {
+			delete(l.entries, ip)
+		}
+	}
+}
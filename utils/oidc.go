@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+	"golang.org/x/oauth2"
+)
+
+// OIDCStateCookie 保存登录状态的 Cookie，只在 /api/v1/auth/oidc 下发送
+const OIDCStateCookie = "oidc_state"
+
+// OIDCProviderConfig 一个 OpenID Connect 身份提供方的配置，
+// 由 OIDC_PROVIDERS 列出名称，每个提供方的配置项为 OIDC_<NAME>_ISSUER 等
+type OIDCProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// OIDCProviders 读取已配置的身份提供方，缺少 ISSUER 或 CLIENT_ID 的提供方被忽略
+func OIDCProviders() []OIDCProviderConfig {
+
+	var providers []OIDCProviderConfig
+	for _, name := range strings.Split(viper.GetString("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		p := OIDCProviderConfig{
+			Name:         name,
+			Issuer:       viper.GetString(prefix + "ISSUER"),
+			ClientID:     viper.GetString(prefix + "CLIENT_ID"),
+			ClientSecret: viper.GetString(prefix + "CLIENT_SECRET"),
+			RedirectURL:  viper.GetString(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(strings.ReplaceAll(viper.GetString(prefix+"SCOPES"), ",", " ")),
+		}
+		if p.Issuer == "" || p.ClientID == "" {
+			continue
+		}
+		// 默认回调地址为本服务的 /api/v1/auth/oidc/<name>/callback
+		if p.RedirectURL == "" {
+			p.RedirectURL = strings.TrimRight(viper.GetString("OIDC_REDIRECT_BASE_URL"), "/") +
+				"/api/v1/auth/oidc/" + name + "/callback"
+		}
+		if len(p.Scopes) == 0 {
+			p.Scopes = []string{"openid", "email", "profile"}
+		}
+		providers = append(providers, p)
+	}
+	return providers
+}
+
+// OIDCStateTTL 跳转到身份提供方后完成登录的时限，默认10分钟
+func OIDCStateTTL() time.Duration {
+	minutes := viper.GetInt("OIDC_STATE_TTL_MINUTES")
+	if minutes <= 0 {
+		minutes = 10
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// OIDCStateClaims 发起登录时生成的 state、nonce 和 PKCE verifier，签名后保存在浏览器的 Cookie 中，
+// 回调时与请求参数比对，不需要服务端存储
+type OIDCStateClaims struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.RegisteredClaims
+}
+
+// ErrInvalidOIDCState Cookie 缺失、签名错误、已过期或与回调参数不符
+var ErrInvalidOIDCState = errors.New("invalid oidc state")
+
+// GenerateOIDCState 为一次登录生成随机的 state、nonce 和 PKCE verifier，返回签名后的 Cookie 值
+func GenerateOIDCState(provider string) (string, *OIDCStateClaims, error) {
+
+	state, err := RandomToken(16)
+	if err != nil {
+		return "", nil, err
+	}
+	nonce, err := RandomToken(16)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	claims := &OIDCStateClaims{
+		Provider: provider,
+		State:    state,
+		Nonce:    nonce,
+		Verifier: oauth2.GenerateVerifier(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(OIDCStateTTL())),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	cookie, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(derivedKey("oidc-state"))
+	if err != nil {
+		return "", nil, err
+	}
+	return cookie, claims, nil
+}
+
+// ParseOIDCState 校验 Cookie 的签名和有效期，并确认与回调的提供方和 state 参数一致
+func ParseOIDCState(cookie, provider, state string) (*OIDCStateClaims, error) {
+
+	claims := &OIDCStateClaims{}
+	_, err := jwt.ParseWithClaims(cookie, claims, func(t *jwt.Token) (interface{}, error) {
+		return derivedKey("oidc-state"), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, ErrInvalidOIDCState
+	}
+	if claims.Provider != provider || claims.State == "" || claims.State != state {
+		return nil, ErrInvalidOIDCState
+	}
+	return claims, nil
+}
@@ -1,20 +1,54 @@
 package utils
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
 )
 
-type Response struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
+// RequestIDKey 请求ID在 gin.Context 中的键，由 middleware.RequestIDMiddleware 写入
+const RequestIDKey = "request_id"
+
+// Response 成功响应的统一结构。data 总是输出，空列表为 []，不能因为 T 是具体类型而被省略
+type Response[T any] struct {
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	Data      T      `json:"data"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ErrorResponse 错误响应的统一结构，不含 data
+type ErrorResponse struct {
+	Code      int          `json:"code"`
+	ErrorCode string       `json:"error_code,omitempty"` // 机器可读的错误码
+	Message   string       `json:"message"`
+	Errors    []FieldError `json:"errors,omitempty"` // 字段级校验错误
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func write[T any](c *gin.Context, status int, resp Response[T]) {
+	resp.RequestID = c.GetString(RequestIDKey)
+	c.JSON(status, resp)
+}
+
+func writeError(c *gin.Context, status int, resp ErrorResponse) {
+	resp.RequestID = c.GetString(RequestIDKey)
+	c.JSON(status, resp)
 }
 
-func Success(c *gin.Context, data any) {
+func Success[T any](c *gin.Context, data T) {
 
-	c.JSON(http.StatusOK, Response{
+	write(c, http.StatusOK, Response[T]{
 		Code:    200,
 		Message: "success",
 		Data:    data,
@@ -22,12 +56,42 @@ func Success(c *gin.Context, data any) {
 
 }
 
+// Created 201，新建资源成功
+func Created[T any](c *gin.Context, data T) {
+
+	write(c, http.StatusCreated, Response[T]{
+		Code:    http.StatusCreated,
+		Message: "created",
+		Data:    data,
+	})
+}
+
 func Error(c *gin.Context, code int, errMesage string) {
-	c.JSON(
-		code, Response{
-			Code:    code,
-			Message: errMesage,
-		})
+	writeError(c, code, ErrorResponse{
+		Code:    code,
+		Message: errMesage,
+	})
+}
+
+// BindError 处理请求参数绑定失败：validator 的校验错误按 Accept-Language 翻译后逐字段返回，
+// 其余（如JSON格式错误）直接返回错误信息
+func BindError(c *gin.Context, err error) {
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		BadRequest(c, err.Error())
+		return
+	}
+	message := "invalid request parameters"
+	if requestTranslator(c).Locale() == "zh" {
+		message = "请求参数校验失败"
+	}
+	writeError(c, http.StatusBadRequest, ErrorResponse{
+		Code:      http.StatusBadRequest,
+		ErrorCode: "validation_failed",
+		Message:   message,
+		Errors:    translateValidationErrors(c, verrs),
+	})
 }
 
 func BadRequest(c *gin.Context, message string) {
@@ -59,3 +123,78 @@ func InternalServerError(c *gin.Context, message string) {
 func UserNotExsit(c *gin.Context) {
 	Error(c, 600, "user no exist")
 }
+
+// 错误类别到 HTTP 状态码的映射
+var kindStatus = []struct {
+	kind   error
+	status int
+	code   string
+}{
+	{ErrValidation, http.StatusBadRequest, "validation_failed"},
+	{ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+	{ErrForbidden, http.StatusForbidden, "forbidden"},
+	{ErrNotFound, http.StatusNotFound, "not_found"},
+	{ErrConflict, http.StatusConflict, "conflict"},
+	{ErrPreconditionFailed, http.StatusPreconditionFailed, "precondition_failed"},
+	{ErrPreconditionRequired, http.StatusPreconditionRequired, "precondition_required"},
+	{ErrUnprocessable, http.StatusUnprocessableEntity, "unprocessable_entity"},
+}
+
+// 登录接口历史遗留的自定义状态码，登录客户端已经依赖，保持不变；其他接口一律使用标准状态码
+var legacyLoginStatus = map[string]int{
+	"account_locked":      601,
+	"invalid_credentials": 602,
+}
+
+// RespondError 把 service 层返回的错误统一转换成响应：
+// AppError 按类别映射状态码并返回稳定的 error_code，其余错误一律按 500 处理且不暴露细节
+func RespondError(c *gin.Context, err error) {
+	respondError(c, err, false)
+}
+
+// RespondLoginError 登录接口专用，账号锁定和密码错误使用历史遗留的 601、602 状态码
+func RespondLoginError(c *gin.Context, err error) {
+	respondError(c, err, true)
+}
+
+func respondError(c *gin.Context, err error, legacy bool) {
+
+	var appErr *AppError
+	if !errors.As(err, &appErr) {
+		logrus.WithFields(logrus.Fields{
+			"error":  err.Error(),
+			"path":   c.Request.URL.Path,
+			"method": c.Request.Method,
+		}).Error("unhandled error")
+		writeError(c, http.StatusInternalServerError, ErrorResponse{
+			Code:      http.StatusInternalServerError,
+			ErrorCode: "internal_error",
+			Message:   "Internal server error",
+		})
+		return
+	}
+
+	status, code := http.StatusInternalServerError, "internal_error"
+	for _, ks := range kindStatus {
+		if errors.Is(appErr.Kind, ks.kind) {
+			status, code = ks.status, ks.code
+			break
+		}
+	}
+	if appErr.Code != "" {
+		code = appErr.Code
+	}
+	if legacyCode, ok := legacyLoginStatus[code]; ok && legacy {
+		status = legacyCode
+	}
+	if appErr.Err != nil {
+		logrus.WithField("code", code).Warn(appErr.Error())
+	}
+
+	writeError(c, status, ErrorResponse{
+		Code:      status,
+		ErrorCode: code,
+		Message:   appErr.Message,
+		Errors:    appErr.Fields,
+	})
+}
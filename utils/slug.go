@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+	"golang.org/x/text/unicode/norm"
+)
+
+// SlugMaxLength slug 的最大长度（不含冲突时追加的序号）
+const SlugMaxLength = 80
+
+var pinyinArgs = pinyin.NewArgs()
+
+// Slugify 根据标题生成 URL 友好的 slug：字母转小写，中文转为拼音，带重音的字母去掉重音，
+// 其他字符作为分隔符。无法转换出任何字符时（例如标题全是表情或日文假名）使用标题的哈希值
+func Slugify(title string) string {
+
+	var words []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+
+	// NFKD 分解后丢弃重音符号，é -> e，全角字母 -> 半角
+	for _, r := range norm.NFKD.String(title) {
+		switch {
+		case r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			word.WriteRune(unicode.ToLower(r))
+		case unicode.Is(unicode.Mn, r):
+			// 重音符号
+		case unicode.Is(unicode.Han, r):
+			flush()
+			words = append(words, pinyin.SinglePinyin(r, pinyinArgs)...)
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	slug := ""
+	for _, w := range words {
+		if w == "" {
+			continue
+		}
+		if len(slug)+len(w)+1 > SlugMaxLength && slug != "" {
+			break
+		}
+		if slug != "" {
+			slug += "-"
+		}
+		slug += w
+	}
+	if len(slug) > SlugMaxLength {
+		slug = slug[:SlugMaxLength]
+	}
+	if slug == "" {
+		sum := sha1.Sum([]byte(title))
+		slug = "post-" + hex.EncodeToString(sum[:])[:10]
+	}
+	return slug
+}
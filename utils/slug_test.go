@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		want  string
+	}{
+		{"ascii", "Hello, World!", "hello-world"},
+		{"leading and trailing separators", "  --Hello--  ", "hello"},
+		{"chinese to pinyin", "Go 语言入门", "go-yu-yan-ru-men"},
+		{"chinese punctuation", "你好，世界！", "ni-hao-shi-jie"},
+		{"accents removed", "Café Crème brûlée", "cafe-creme-brulee"},
+		{"full-width", "ＧＯ　１．２４ 发布", "go-1-24-fa-bu"},
+		// 截断在单词边界，不超过 SlugMaxLength
+		{"truncated at a word boundary", strings.Repeat("abcdefghij ", 10),
+			"abcdefghij-abcdefghij-abcdefghij-abcdefghij-abcdefghij-abcdefghij-abcdefghij"},
+		{"long chinese title", strings.Repeat("中文标题", 10),
+			"zhong-wen-biao-ti-zhong-wen-biao-ti-zhong-wen-biao-ti-zhong-wen-biao-ti-zhong"},
+		// 单个单词超过上限时直接截断
+		{"single long word", strings.Repeat("a", 100), strings.Repeat("a", SlugMaxLength)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Slugify(tt.title)
+			if got != tt.want {
+				t.Fatalf("Slugify(%q) = %q, want %q", tt.title, got, tt.want)
+			}
+			if len(got) > SlugMaxLength {
+				t.Fatalf("slug %q is longer than %d bytes", got, SlugMaxLength)
+			}
+		})
+	}
+}
+
+// 无法转换出任何字符的标题使用哈希值，相同标题得到相同的 slug
+func TestSlugifyHashFallback(t *testing.T) {
+	for _, title := range []string{"😀🎉", "こんにちは", "！！！"} {
+		got := Slugify(title)
+		if !strings.HasPrefix(got, "post-") || len(got) != len("post-")+10 {
+			t.Fatalf("Slugify(%q) = %q, want post-<hash>", title, got)
+		}
+		if again := Slugify(title); again != got {
+			t.Fatalf("Slugify(%q) is not stable: %q != %q", title, got, again)
+		}
+	}
+	if Slugify("😀") == Slugify("🎉") {
+		t.Fatal("different titles must get different fallback slugs")
+	}
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base32"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"github.com/spf13/viper"
+)
+
+// TOTP 参数与主流验证器应用（Google Authenticator 等）的默认值一致
+const (
+	totpPeriod = 30
+	totpSkew   = 1 // 允许前后各一个周期的时钟误差
+)
+
+var totpOpts = totp.ValidateOpts{
+	Period:    totpPeriod,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+// MFAPendingTTL 密码校验通过后提交 TOTP 验证码的时限，默认5分钟
+func MFAPendingTTL() time.Duration {
+	minutes := viper.GetInt("MFA_PENDING_TTL_MINUTES")
+	if minutes <= 0 {
+		minutes = 5
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// GenerateTOTPKey 生成新的 TOTP 密钥，account 显示在验证器应用中
+func GenerateTOTPKey(account string) (*otp.Key, error) {
+	return totp.Generate(totp.GenerateOpts{
+		Issuer:      viper.GetString("MFA_ISSUER"),
+		AccountName: account,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+}
+
+// TOTPQRCode 把 otpauth:// 地址编码成 PNG 格式的二维码
+func TOTPQRCode(key *otp.Key, size int) ([]byte, error) {
+
+	img, err := key.Image(size, size)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MatchTOTP 校验验证码，通过时返回验证码所在的时间步（unix 时间 / 30秒）。
+// 调用方需保证同一个时间步只能使用一次，防止验证码被重放
+func MatchTOTP(secret, code string, now time.Time) (int64, bool) {
+
+	code = strings.TrimSpace(code)
+	for offset := -totpSkew; offset <= totpSkew; offset++ {
+		t := now.Add(time.Duration(offset*totpPeriod) * time.Second)
+		expected, err := totp.GenerateCodeCustom(secret, t, totpOpts)
+		if err != nil {
+			return 0, false
+		}
+		if expected == code {
+			return t.Unix() / totpPeriod, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateRecoveryCodes 生成 n 个一次性恢复码，格式为 xxxxx-xxxxx（base32 小写，50位随机数）
+func GenerateRecoveryCodes(n int) ([]string, error) {
+
+	codes := make([]string, 0, n)
+	for range n {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		s := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+		codes = append(codes, s[:5]+"-"+s[5:])
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode 忽略大小写、空格和连字符后做 sha256，数据库中只保存哈希
+func HashRecoveryCode(code string) string {
+	code = strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+	return HashToken(code)
+}
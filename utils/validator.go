@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+	"golang.org/x/text/language"
+)
+
+// 支持的语言，第一个为默认语言
+var (
+	supportedLocales = []language.Tag{language.English, language.Chinese}
+	localeMatcher    = language.NewMatcher(supportedLocales)
+)
+
+var (
+	validatorOnce sync.Once
+	validate      *validator.Validate
+	translator    *ut.UniversalTranslator
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// setupValidator 接管 gin 默认的 validator：字段名使用 json tag，注册中英文翻译和内置的自定义规则
+func setupValidator() {
+
+	validatorOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			panic("gin validator engine is not go-playground/validator")
+		}
+		validate = v
+
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+
+		enLocale := en.New()
+		translator = ut.New(enLocale, enLocale, zh.New())
+		enTrans, _ := translator.GetTranslator("en")
+		zhTrans, _ := translator.GetTranslator("zh")
+		if err := enTranslations.RegisterDefaultTranslations(validate, enTrans); err != nil {
+			panic(err)
+		}
+		if err := zhTranslations.RegisterDefaultTranslations(validate, zhTrans); err != nil {
+			panic(err)
+		}
+	})
+}
+
+// InitValidator 注册内置的自定义校验规则，启动时调用一次
+func InitValidator() {
+
+	setupValidator()
+	mustRegisterRule("username", func(fl validator.FieldLevel) bool {
+		return usernamePattern.MatchString(fl.Field().String())
+	}, map[string]string{
+		"en": "{0} may only contain letters, digits and underscores",
+		"zh": "{0}只能包含字母、数字和下划线",
+	})
+	mustRegisterRule("password_strength", func(fl validator.FieldLevel) bool {
+		var hasLetter, hasDigit bool
+		for _, r := range fl.Field().String() {
+			switch {
+			case unicode.IsLetter(r):
+				hasLetter = true
+			case unicode.IsDigit(r):
+				hasDigit = true
+			}
+		}
+		return hasLetter && hasDigit
+	}, map[string]string{
+		"en": "{0} must contain at least one letter and one digit",
+		"zh": "{0}必须同时包含字母和数字",
+	})
+}
+
+// RegisterRule 注册自定义校验规则及各语言的提示信息，提示中的 {0} 会被替换为字段名
+func RegisterRule(tag string, fn validator.Func, messages map[string]string) error {
+
+	setupValidator()
+	if err := validate.RegisterValidation(tag, fn); err != nil {
+		return err
+	}
+	for locale, message := range messages {
+		trans, found := translator.GetTranslator(locale)
+		if !found {
+			continue
+		}
+		err := validate.RegisterTranslation(tag, trans,
+			func(t ut.Translator) error {
+				return t.Add(tag, message, true)
+			},
+			func(t ut.Translator, fe validator.FieldError) string {
+				msg, err := t.T(fe.Tag(), fe.Field())
+				if err != nil {
+					return fe.Error()
+				}
+				return msg
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mustRegisterRule(tag string, fn validator.Func, messages map[string]string) {
+	if err := RegisterRule(tag, fn, messages); err != nil {
+		panic(err)
+	}
+}
+
+// requestTranslator 根据 Accept-Language 选择翻译器，默认英文
+func requestTranslator(c *gin.Context) ut.Translator {
+
+	setupValidator()
+	tags, _, _ := language.ParseAcceptLanguage(c.GetHeader("Accept-Language"))
+	_, index, _ := localeMatcher.Match(tags...)
+	base, _ := supportedLocales[index].Base()
+	trans, _ := translator.GetTranslator(base.String())
+	return trans
+}
+
+// translateValidationErrors 把 validator 的错误转换成 {field, rule, message} 列表
+func translateValidationErrors(c *gin.Context, verrs validator.ValidationErrors) []FieldError {
+
+	trans := requestTranslator(c)
+	fields := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: fe.Translate(trans),
+		})
+	}
+	return fields
+}